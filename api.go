@@ -0,0 +1,785 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"go-charts/internal/airsigmets"
+	"go-charts/internal/alerts"
+	"go-charts/internal/geo"
+	"go-charts/internal/metars"
+	"go-charts/internal/notams"
+	"go-charts/internal/pireps"
+	"go-charts/internal/stations"
+	"go-charts/internal/tafs"
+	"go-charts/internal/tfrs"
+	"go-charts/internal/weather"
+	"go-charts/internal/wxstore"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// parseFilter builds a store filter from the query parameters
+//
+//	bbox=minLon,minLat,maxLon,maxLat
+//	ids=KDEN,KBOS
+//	lat=..&lon=..&radius=nm
+//	maxage=minutes
+func parseFilter(q url.Values) (wxstore.Filter, error) {
+	var f wxstore.Filter
+	if s := q.Get("bbox"); s != "" {
+		v, err := parseFloats(s, 4)
+		if err != nil {
+			return f, fmt.Errorf("bad bbox: %s", err)
+		}
+		f.BBox = &geo.BBox{MinLon: v[0], MinLat: v[1], MaxLon: v[2], MaxLat: v[3]}
+	}
+	if s := q.Get("ids"); s != "" {
+		f.IDs = make(map[string]bool)
+		for _, id := range strings.Split(s, ",") {
+			if id = strings.TrimSpace(id); id != "" {
+				f.IDs[strings.ToUpper(id)] = true
+			}
+		}
+	}
+	if s := q.Get("radius"); s != "" {
+		var err error
+		f.RadiusNm, err = strconv.ParseFloat(s, 64)
+		if err != nil || f.RadiusNm <= 0 {
+			return f, fmt.Errorf("bad radius: %s", s)
+		}
+		f.CenterLat, err = strconv.ParseFloat(q.Get("lat"), 64)
+		if err != nil {
+			return f, fmt.Errorf("radius requires lat and lon")
+		}
+		f.CenterLon, err = strconv.ParseFloat(q.Get("lon"), 64)
+		if err != nil {
+			return f, fmt.Errorf("radius requires lat and lon")
+		}
+	}
+	if s := q.Get("maxage"); s != "" {
+		minutes, err := strconv.ParseFloat(s, 64)
+		if err != nil || minutes <= 0 {
+			return f, fmt.Errorf("bad maxage: %s", s)
+		}
+		f.MaxAge = time.Duration(minutes * float64(time.Minute))
+		f.Now = weatherNow()
+	}
+	return f, nil
+}
+
+func parseFloats(s string, n int) ([]float64, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != n {
+		return nil, fmt.Errorf("expected %d values, got %d", n, len(parts))
+	}
+	v := make([]float64, n)
+	for i, p := range parts {
+		var err error
+		v[i], err = strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
+
+// writeProduct writes a filtered product in the same shape as the websocket
+// payload, or as a GeoJSON FeatureCollection when format=geojson
+func writeProduct(w http.ResponseWriter, r *http.Request, name string, p weather.Product) {
+	writeReports(w, r, name, p, wxStore.FetchTime(name), wxStore.Stale(name))
+}
+
+// writeReports writes reports as writeProduct does, tagged with the given
+// fetch time and staleness. A zero fetch time leaves both tags out.
+func writeReports(w http.ResponseWriter, r *http.Request, name string, p weather.Product, fetched time.Time, stale bool) {
+	if strings.EqualFold(r.URL.Query().Get("format"), "geojson") {
+		gp, ok := p.(weather.GeoJsonProduct)
+		if !ok {
+			http.Error(w, name+" has no GeoJSON encoding", 400)
+			return
+		}
+		js, err := gp.ToGeoJson()
+		if err != nil {
+			log.Println(err)
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		setJSONHeaders(w)
+		w.Header().Set("Content-Type", "application/geo+json")
+		setNoCache(w)
+		fmt.Fprint(w, js)
+		return
+	}
+	js, err := p.ToJson()
+	if err != nil {
+		log.Println(err)
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	setJSONHeaders(w)
+	setNoCache(w)
+	if fetched.IsZero() {
+		fmt.Fprint(w, "{ \""+name+"\": "+js+"}")
+		return
+	}
+	fmt.Fprintf(w, "{ \"%s\": %s, \"fetchtime\": \"%s\", \"stale\": %t}", name, js, fetched.Format(time.RFC3339), stale)
+}
+
+// handleApiMetars returns the METARs matching the query filter
+func handleApiMetars(w http.ResponseWriter, r *http.Request) {
+	f, err := parseFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	var resp metars.Response
+	resp.Data.Metars = wxStore.FilterMetars(f)
+	resp.Data.NumResults = int32(len(resp.Data.Metars))
+	writeProduct(w, r, "metars", &resp)
+}
+
+// handleApiTafs returns the TAFs matching the query filter
+func handleApiTafs(w http.ResponseWriter, r *http.Request) {
+	f, err := parseFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	var resp tafs.Response
+	resp.Data.Tafs = wxStore.FilterTafs(f)
+	resp.Data.NumResults = int32(len(resp.Data.Tafs))
+	writeProduct(w, r, "tafs", &resp)
+}
+
+// handleApiPireps returns the PIREPs matching the query filter
+func handleApiPireps(w http.ResponseWriter, r *http.Request) {
+	f, err := parseFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	var resp pireps.Response
+	resp.Data.Pireps = wxStore.FilterPireps(f)
+	resp.Data.NumResults = int32(len(resp.Data.Pireps))
+	writeProduct(w, r, "pireps", &resp)
+}
+
+// handleApiMetarStation serves /api/metars/{station}/history?hours=N, the
+// archived METARs for one station over the last N hours, oldest first
+func handleApiMetarStation(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/metars/"), "/"), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] != "history" {
+		http.NotFound(w, r)
+		return
+	}
+	if metarArchive == nil {
+		http.Error(w, "METAR history is not enabled", 503)
+		return
+	}
+	hours := 24
+	if s := r.URL.Query().Get("hours"); s != "" {
+		var err error
+		hours, err = strconv.Atoi(s)
+		if err != nil || hours <= 0 {
+			http.Error(w, "bad hours: "+s, 400)
+			return
+		}
+	}
+	since := time.Now().UTC().Add(-time.Duration(hours) * time.Hour)
+	series, err := metarArchive.Series(parts[0], since)
+	if err != nil {
+		log.Println(err)
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	var resp metars.Response
+	resp.Data.Metars = series
+	resp.Data.NumResults = int32(len(series))
+	// the series comes from the archive, not the live snapshot, so it
+	// carries none of the snapshot's fetch time or staleness
+	writeReports(w, r, "metars", &resp, time.Time{}, false)
+}
+
+// handleApiTafStation serves /api/tafs/{station}/at?time=T, the conditions
+// the station's current TAF forecasts for time T (default now). T is
+// RFC 3339 or unix seconds.
+func handleApiTafStation(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/tafs/"), "/"), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] != "at" {
+		http.NotFound(w, r)
+		return
+	}
+	at := weatherNow()
+	if s := r.URL.Query().Get("time"); s != "" {
+		var err error
+		at, err = weather.ParseTime(s)
+		if err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+	}
+	station := strings.ToUpper(parts[0])
+	taf, ok := wxStore.Taf(station)
+	if !ok {
+		http.Error(w, "no TAF for "+station, 404)
+		return
+	}
+	conditions, err := tafs.ConditionsAt(taf, at)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	js, err := json.Marshal(conditions)
+	if err != nil {
+		log.Println(err)
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	setJSONHeaders(w)
+	setNoCache(w)
+	w.Write(js)
+}
+
+// defaultGeoJson makes GeoJSON the format of an advisory endpoint unless
+// the request asks for another
+func defaultGeoJson(r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("format") == "" {
+		q.Set("format", "geojson")
+		r.URL.RawQuery = q.Encode()
+	}
+}
+
+// handleApiAirSigmets returns the current AIRMETs, SIGMETs and Convective
+// SIGMETs, as GeoJSON polygons unless format=json
+func handleApiAirSigmets(w http.ResponseWriter, r *http.Request) {
+	defaultGeoJson(r)
+	var resp airsigmets.Response
+	resp.Data.AirSigmets = wxStore.AirSigmets()
+	resp.Data.NumResults = int32(len(resp.Data.AirSigmets))
+	writeProduct(w, r, "airsigmets", &resp)
+}
+
+// handleApiGAirmets returns the current G-AIRMET snapshots, as GeoJSON
+// unless format=json
+func handleApiGAirmets(w http.ResponseWriter, r *http.Request) {
+	defaultGeoJson(r)
+	var resp airsigmets.GAirmetResponse
+	resp.Data.GAirmets = wxStore.GAirmets()
+	resp.Data.NumResults = int32(len(resp.Data.GAirmets))
+	writeProduct(w, r, "gairmets", &resp)
+}
+
+// handleApiWindsAloft returns the winds aloft forecast. With lat, lon and
+// alt (feet MSL) it returns the wind and temperature interpolated there.
+func handleApiWindsAloft(w http.ResponseWriter, r *http.Request) {
+	forecast := wxStore.WindsAloft()
+	if forecast == nil {
+		http.Error(w, "no winds aloft forecast available yet", 503)
+		return
+	}
+	forecast = forecast.Locate(stationLocator{})
+	q := r.URL.Query()
+	if q.Get("lat") == "" && q.Get("lon") == "" && q.Get("alt") == "" {
+		writeProduct(w, r, "windsaloft", forecast)
+		return
+	}
+	var v [3]float64
+	for i, name := range []string{"lat", "lon", "alt"} {
+		var err error
+		v[i], err = strconv.ParseFloat(q.Get(name), 64)
+		if err != nil {
+			http.Error(w, "lat, lon and alt are required", 400)
+			return
+		}
+	}
+	wind, err := forecast.At(v[0], v[1], v[2])
+	if err != nil {
+		http.Error(w, err.Error(), 404)
+		return
+	}
+	js, err := json.Marshal(wind)
+	if err != nil {
+		log.Println(err)
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	setJSONHeaders(w)
+	setNoCache(w)
+	w.Write(js)
+}
+
+// handleApiStations returns the stations matching the query filter. The
+// site parameter, such as site=TAF, keeps only stations reporting it.
+// maxage does not apply to stations.
+func handleApiStations(w http.ResponseWriter, r *http.Request) {
+	if stationTable == nil {
+		http.Error(w, "station list is not configured", 503)
+		return
+	}
+	f, err := parseFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	f.MaxAge = 0
+	site := strings.ToUpper(r.URL.Query().Get("site"))
+	var resp stations.Response
+	resp.Data.Stations = make([]stations.Station, 0)
+	for _, s := range stationTable.Stations() {
+		if site != "" && !s.Has(site) {
+			continue
+		}
+		if f.Match(s.StationId, s.Latitude, s.Longitude, time.Time{}) {
+			resp.Data.Stations = append(resp.Data.Stations, s)
+		}
+	}
+	sort.Slice(resp.Data.Stations, func(i, j int) bool {
+		return resp.Data.Stations[i].StationId < resp.Data.Stations[j].StationId
+	})
+	resp.Data.NumResults = int32(len(resp.Data.Stations))
+	writeProduct(w, r, "stations", &resp)
+}
+
+// handleApiStation serves /api/stations/{station}, the details of one station
+func handleApiStation(w http.ResponseWriter, r *http.Request) {
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/stations/"), "/")
+	if id == "" || strings.Contains(id, "/") {
+		http.Error(w, "expected /api/stations/{station}", 404)
+		return
+	}
+	s, ok := stationTable.Lookup(id)
+	if !ok {
+		http.Error(w, "unknown station "+strings.ToUpper(id), 404)
+		return
+	}
+	js, err := json.Marshal(s)
+	if err != nil {
+		log.Println(err)
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	setJSONHeaders(w)
+	setNoCache(w)
+	w.Write(js)
+}
+
+// handleApiNotams returns the NOTAMs matching the query filter. ids
+// selects airports, and NOTAMs that have not started yet are included
+// only with all=true.
+func handleApiNotams(w http.ResponseWriter, r *http.Request) {
+	f, err := parseFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	all := r.URL.Query().Get("all") == "true"
+	now := weatherNow()
+	// NOTAMs match ids by location and are not filtered by age
+	area := f
+	area.IDs = nil
+	area.MaxAge = 0
+	var resp notams.Response
+	resp.Notams = make([]notams.Notam, 0)
+	for _, n := range wxStore.Notams() {
+		if !all && !n.Active(now) {
+			continue
+		}
+		if len(f.IDs) > 0 && !notamForAny(&n, f.IDs) {
+			continue
+		}
+		if (f.BBox != nil || f.RadiusNm > 0) && (!n.Located || !area.Match(n.Id, n.Latitude, n.Longitude, now)) {
+			continue
+		}
+		resp.Notams = append(resp.Notams, n)
+	}
+	writeProduct(w, r, "notams", &resp)
+}
+
+// notamForAny reports whether the NOTAM applies to any of the airports
+func notamForAny(n *notams.Notam, ids map[string]bool) bool {
+	for id := range ids {
+		if n.AppliesTo(id) {
+			return true
+		}
+	}
+	return false
+}
+
+// handleApiAirportNotams serves /api/notams/{airport}, the NOTAMs in effect
+// at one airport
+func handleApiAirportNotams(w http.ResponseWriter, r *http.Request) {
+	airport := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/notams/"), "/")
+	if airport == "" || strings.Contains(airport, "/") {
+		http.Error(w, "expected /api/notams/{airport}", 404)
+		return
+	}
+	resp := notams.Response{Notams: wxStore.NotamsFor(airport, weatherNow())}
+	writeProduct(w, r, "notams", &resp)
+}
+
+// handleApiTfrs returns the TFRs as GeoJSON polygons, or in the websocket
+// shape with format=json. TFRs that have not started yet are included
+// only with all=true.
+func handleApiTfrs(w http.ResponseWriter, r *http.Request) {
+	defaultGeoJson(r)
+	all := r.URL.Query().Get("all") == "true"
+	now := weatherNow()
+	var resp tfrs.Response
+	resp.Tfrs = make([]tfrs.Tfr, 0)
+	for _, t := range wxStore.Tfrs() {
+		if all || t.Active(now) {
+			resp.Tfrs = append(resp.Tfrs, t)
+		}
+	}
+	writeProduct(w, r, "tfrs", &resp)
+}
+
+// writeJSON writes v as an uncached JSON response
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	js, err := json.Marshal(v)
+	if err != nil {
+		log.Println(err)
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	setJSONHeaders(w)
+	setNoCache(w)
+	w.WriteHeader(code)
+	w.Write(js)
+}
+
+// handleApiAlerts returns the most recent alerts, oldest first
+func handleApiAlerts(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, 200, alertEngine.Recent())
+}
+
+// handleApiAlertRules lists the alert rules on GET and adds one on POST.
+// A cid query parameter subscribes that websocket client to the new rule.
+func handleApiAlertRules(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, 200, alertEngine.Rules())
+	case http.MethodPost:
+		var rule alerts.Rule
+		err := json.NewDecoder(r.Body).Decode(&rule)
+		if err != nil {
+			http.Error(w, "bad rule: "+err.Error(), 400)
+			return
+		}
+		rule, err = alertEngine.Add(rule)
+		if err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+		if cid := r.URL.Query().Get("cid"); cid != "" {
+			subscribeAlerts(rule.Id, cid)
+		}
+		writeJSON(w, 201, rule)
+	default:
+		http.Error(w, "method not allowed", 405)
+	}
+}
+
+// handleApiAlertRule serves /api/alerts/rules/{id}, which returns or
+// deletes a rule, and /api/alerts/rules/{id}/subscribe?cid=, which
+// subscribes a websocket client on POST and unsubscribes it on DELETE
+func handleApiAlertRule(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/alerts/rules/"), "/"), "/")
+	id := parts[0]
+	rule, ok := alertEngine.Rule(id)
+	if !ok {
+		http.Error(w, "unknown rule "+id, 404)
+		return
+	}
+	switch {
+	case len(parts) == 1 && r.Method == http.MethodGet:
+		writeJSON(w, 200, rule)
+	case len(parts) == 1 && r.Method == http.MethodDelete:
+		alertEngine.Remove(id)
+		unsubscribeAlerts(id, "")
+		w.WriteHeader(204)
+	case len(parts) == 2 && parts[1] == "subscribe":
+		cid := r.URL.Query().Get("cid")
+		if cid == "" {
+			http.Error(w, "cid is required", 400)
+			return
+		}
+		switch r.Method {
+		case http.MethodPost:
+			subscribeAlerts(id, cid)
+		case http.MethodDelete:
+			unsubscribeAlerts(id, cid)
+		default:
+			http.Error(w, "method not allowed", 405)
+			return
+		}
+		w.WriteHeader(204)
+	default:
+		http.Error(w, "not found", 404)
+	}
+}
+
+// handleApiSources lists each weather source with its last and next download
+func handleApiSources(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, 200, wxScheduler.Status())
+}
+
+// handleApiSourceRefresh serves POST /api/sources/{name}/refresh, which
+// downloads that source now, and POST /api/sources/refresh for every source
+func handleApiSourceRefresh(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/sources/"), "/"), "/")
+	name := ""
+	switch {
+	case len(parts) == 1 && parts[0] == "refresh":
+	case len(parts) == 2 && parts[1] == "refresh":
+		name = parts[0]
+	default:
+		http.Error(w, "not found", 404)
+		return
+	}
+	if !wxScheduler.RefreshNow(name) {
+		http.Error(w, "unknown source "+name, 404)
+		return
+	}
+	w.WriteHeader(202)
+}
+
+// sourceStatus is a weather source in /api/status
+type sourceStatus struct {
+	weather.Status
+	FetchTime time.Time `json:"fetchtime"`
+	Records   int       `json:"records"`
+	Stale     bool      `json:"stale"`
+}
+
+// tilesetStatus is an MBTiles chart set in /api/status. Cycle is the
+// cycle or version recorded in the tileset metadata, if any.
+type tilesetStatus struct {
+	File    string `json:"file"`
+	Name    string `json:"name"`
+	Format  string `json:"format"`
+	Bounds  string `json:"bounds"`
+	MinZoom string `json:"minzoom"`
+	MaxZoom string `json:"maxzoom"`
+	Cycle   string `json:"cycle"`
+}
+
+// clientStatus is a connected websocket client in /api/status
+type clientStatus struct {
+	Id        string    `json:"id"`
+	Address   string    `json:"address"`
+	Connected time.Time `json:"connected"`
+}
+
+// gpsStatus reports where ownship positions come from and when the
+// server last received one. Checked is when Stratux was last probed.
+type gpsStatus struct {
+	Source       string           `json:"source"`
+	StratuxURL   string           `json:"stratuxurl,omitempty"`
+	Reachable    bool             `json:"reachable"`
+	Error        string           `json:"error,omitempty"`
+	FixQuality   int              `json:"fixquality"`
+	Satellites   int              `json:"satellites"`
+	LastPosition *positionHistory `json:"lastposition,omitempty"`
+	LastReceived time.Time        `json:"lastreceived"`
+	Checked      time.Time        `json:"checked"`
+}
+
+// historyStatus describes the position history database
+type historyStatus struct {
+	Enabled   bool      `json:"enabled"`
+	Path      string    `json:"path"`
+	SizeBytes int64     `json:"sizebytes"`
+	Modified  time.Time `json:"modified"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// serverStatus is the body of /api/status
+type serverStatus struct {
+	Time          time.Time       `json:"time"`
+	Started       time.Time       `json:"started"`
+	UptimeSeconds int64           `json:"uptimeseconds"`
+	Uptime        string          `json:"uptime"`
+	ReplayTime    *time.Time      `json:"replaytime,omitempty"`
+	Weather       []sourceStatus  `json:"weather"`
+	Stations      int             `json:"stations"`
+	StationsTime  time.Time       `json:"stationsupdated"`
+	Tilesets      []tilesetStatus `json:"tilesets"`
+	TilesetError  string          `json:"tileseterror,omitempty"`
+	Clients       []clientStatus  `json:"clients"`
+	Gps           gpsStatus       `json:"gps"`
+	History       historyStatus   `json:"positionhistory"`
+}
+
+// handleApiStatus reports the health of every part of the server in one place
+func handleApiStatus(w http.ResponseWriter, r *http.Request) {
+	now := time.Now().UTC()
+	uptime := now.Sub(startTime).Truncate(time.Second)
+	st := serverStatus{
+		Time:          now,
+		Started:       startTime,
+		UptimeSeconds: int64(uptime.Seconds()),
+		Uptime:        uptime.String(),
+		Weather:       []sourceStatus{},
+		Stations:      stationTable.Count(),
+		StationsTime:  stationTable.Updated(),
+		Tilesets:      []tilesetStatus{},
+		Clients:       []clientStatus{},
+		Gps:           readGpsStatus(),
+		History:       readHistoryStatus(),
+	}
+	if wxPlayer != nil {
+		t := wxPlayer.Now()
+		st.ReplayTime = &t
+	}
+	for _, s := range wxScheduler.Status() {
+		st.Weather = append(st.Weather, sourceStatus{
+			Status:    s,
+			FetchTime: wxStore.FetchTime(s.Name),
+			Records:   wxStore.Count(s.Name),
+			Stale:     wxStore.Stale(s.Name),
+		})
+	}
+
+	tilesets, err := readTilesets()
+	if err != nil {
+		st.TilesetError = err.Error()
+	}
+	for file, meta := range tilesets {
+		cycle := meta["cycle"]
+		if cycle == "" {
+			cycle = meta["version"]
+		}
+		st.Tilesets = append(st.Tilesets, tilesetStatus{File: file, Name: meta["name"], Format: meta["format"],
+			Bounds: meta["bounds"], MinZoom: meta["minzoom"], MaxZoom: meta["maxzoom"], Cycle: cycle})
+	}
+	sort.Slice(st.Tilesets, func(i, j int) bool { return st.Tilesets[i].File < st.Tilesets[j].File })
+
+	clientsMutex.Lock()
+	for conn, connected := range clients {
+		st.Clients = append(st.Clients, clientStatus{Id: conn.ID, Address: conn.RemoteAddr().String(), Connected: connected})
+	}
+	clientsMutex.Unlock()
+	sort.Slice(st.Clients, func(i, j int) bool { return st.Clients[i].Connected.Before(st.Clients[j].Connected) })
+
+	writeJSON(w, 200, st)
+}
+
+// readGpsStatus reports the last posted position and, when Stratux is the
+// GPS source, the result of the latest background probe of it
+func readGpsStatus() gpsStatus {
+	g := gpsStatus{Source: "none"}
+	lastPositionMutex.Lock()
+	if !lastPositionTime.IsZero() {
+		p := lastPosition
+		g.LastPosition = &p
+		g.LastReceived = lastPositionTime
+	}
+	lastPositionMutex.Unlock()
+	if !config.Getgpsfromstratux {
+		return g
+	}
+	g.Source = "stratux"
+	g.StratuxURL = config.Stratuxurl
+	stratuxMutex.Lock()
+	probe := stratuxLastProbe
+	stratuxMutex.Unlock()
+	g.Reachable = probe.Reachable
+	g.Error = probe.Error
+	g.FixQuality = probe.FixQuality
+	g.Satellites = probe.Satellites
+	g.Checked = probe.Checked
+	if probe.Checked.IsZero() {
+		g.Error = "not checked yet"
+	}
+	return g
+}
+
+// stratuxProbe is the outcome of one request to the Stratux situation URL
+type stratuxProbe struct {
+	Reachable  bool
+	Error      string
+	FixQuality int
+	Satellites int
+	Checked    time.Time
+}
+
+var stratuxLastProbe stratuxProbe
+var stratuxMutex = sync.Mutex{}
+
+// stratuxProbeInterval is how often the Stratux GPS is checked for /api/status
+const stratuxProbeInterval = 30 * time.Second
+
+// timedStratuxProbe checks the Stratux GPS in the background, so a slow or
+// missing Stratux doesn't hold up /api/status
+func timedStratuxProbe() {
+	for {
+		p := probeStratux()
+		stratuxMutex.Lock()
+		stratuxLastProbe = p
+		stratuxMutex.Unlock()
+		time.Sleep(stratuxProbeInterval)
+	}
+}
+
+// probeStratux asks Stratux for its situation and reports whether it
+// answered and what fix it has
+func probeStratux() stratuxProbe {
+	p := stratuxProbe{Checked: time.Now().UTC()}
+	client := http.Client{Timeout: 2 * time.Second}
+	resp, err := client.Get(config.Stratuxurl)
+	if err != nil {
+		p.Error = err.Error()
+		return p
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		p.Error = resp.Status
+		return p
+	}
+	p.Reachable = true
+	var situation struct {
+		GPSFixQuality int
+		GPSSatellites int
+	}
+	err = json.NewDecoder(resp.Body).Decode(&situation)
+	if err != nil {
+		p.Error = err.Error()
+		return p
+	}
+	p.FixQuality = situation.GPSFixQuality
+	p.Satellites = situation.GPSSatellites
+	return p
+}
+
+// readHistoryStatus reports the size of the position history database
+func readHistoryStatus() historyStatus {
+	h := historyStatus{Enabled: config.Savepositionhistory, Path: positionHistoryDb}
+	fi, err := os.Stat(positionHistoryDb)
+	if err != nil {
+		h.Error = err.Error()
+		return h
+	}
+	h.SizeBytes = fi.Size()
+	h.Modified = fi.ModTime().UTC()
+	return h
+}
+
+// handleMetrics writes the server metrics in the Prometheus text format
+func handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	setNoCache(w)
+	metricsRegistry.Write(w)
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"encoding/json"
+	"go-charts/internal/alerts"
 	"os"
 	"log"
 )
@@ -25,6 +26,30 @@ type Configuration struct {
 	MetarsURL             string `json:"metarsurl"`
 	TafsURL               string `json:"tafsurl"`
 	PirepsURL             string `json:"pirepsurl"`
+	AirsigmetsURL         string `json:"airsigmetsurl"`
+	GairmetsURL           string `json:"gairmetsurl"`
+	WindsaloftURL         string `json:"windsalofturl"`
+	NotamsURL             string `json:"notamsurl"`
+	TfrsURL               string `json:"tfrsurl"`
+	Tfralertminutes       int    `json:"tfralertminutes"`
+	MetarsFormat          string `json:"metarsformat"`
+	TafsFormat            string `json:"tafsformat"`
+	PirepsFormat          string `json:"pirepsformat"`
+	AirsigmetsFormat      string `json:"airsigmetsformat"`
+	GairmetsFormat        string `json:"gairmetsformat"`
+	Persistweather        bool   `json:"persistweather"`
+	Weatherworkdir        string `json:"weatherworkdir"`
+	Staleminutes          int    `json:"staleminutes"`
+	Archivedir            string `json:"archivedir"`
+	Archivedays           int    `json:"archivedays"`
+	Replaystart           string `json:"replaystart"`
+	Replayspeed           int    `json:"replayspeed"`
+	Metarhistorydb        string `json:"metarhistorydb"`
+	Metarhistoryhours     int    `json:"metarhistoryhours"`
+	StationsURL           string `json:"stationsurl"`
+	StationsFormat        string `json:"stationsformat"`
+	Stationsdb            string `json:"stationsdb"`
+	Stationsrefreshhours  int    `json:"stationsrefreshhours"`
 	Lockownshiptocenter   bool   `json:"lockownshiptocenter"`
 	Ownshipimage          string `json:"ownshipimage"`
 	Usemetricunits        bool   `json:"usemetricunits"`
@@ -33,7 +58,9 @@ type Configuration struct {
 		Nauticalmiles string `json:"nauticalmiles"`
 		Statutemiles  string `json:"statutemiles"`
 	} `json:"distanceunits"`
-	Messagetypes struct {
+	Alertrules       []alerts.Rule              `json:"alertrules"`
+	Refreshschedules map[string]refreshSchedule `json:"refreshschedules"`
+	Messagetypes     struct {
 		Metars struct {
 			Type  string `json:"type"`
 			Token string `json:"token"`
@@ -46,13 +73,52 @@ type Configuration struct {
 			Type  string `json:"type"`
 			Token string `json:"token"`
 		} `json:"pireps"`
+		Airsigmets struct {
+			Type  string `json:"type"`
+			Token string `json:"token"`
+		} `json:"airsigmets"`
+		Gairmets struct {
+			Type  string `json:"type"`
+			Token string `json:"token"`
+		} `json:"gairmets"`
+		Windsaloft struct {
+			Type  string `json:"type"`
+			Token string `json:"token"`
+		} `json:"windsaloft"`
+		Notams struct {
+			Type  string `json:"type"`
+			Token string `json:"token"`
+		} `json:"notams"`
+		Tfrs struct {
+			Type  string `json:"type"`
+			Token string `json:"token"`
+		} `json:"tfrs"`
+		Tfralert struct {
+			Type  string `json:"type"`
+			Token string `json:"token"`
+		} `json:"tfralert"`
+		Alert struct {
+			Type  string `json:"type"`
+			Token string `json:"token"`
+		} `json:"alert"`
 		Airports struct {
 			Type  string `json:"type"`
 			Token string `json:"token"`
 		} `json:"airports"`
+		Delta struct {
+			Type  string `json:"type"`
+			Token string `json:"token"`
+		} `json:"delta"`
 	} `json:"messagetypes"`
 }
 
+// refreshSchedule is the download schedule of one weather product
+type refreshSchedule struct {
+	Intervalseconds   int `json:"intervalseconds"`
+	Jitterseconds     int `json:"jitterseconds"`
+	Maxbackoffseconds int `json:"maxbackoffseconds"`
+}
+
 var config Configuration
 
 // GetConfigAsString returns configuration data as a json string for client use
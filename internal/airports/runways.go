@@ -0,0 +1,130 @@
+package airports
+
+import (
+	"bytes"
+	"encoding/json"
+	"go-charts/internal/geo"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Runway is one usable end of a runway
+type Runway struct {
+	Ident       string
+	HeadingTrue float64
+	LengthFt    int
+	Surface     string
+}
+
+// RunwayIndex holds the runway ends of every airport, keyed by airport ident
+type RunwayIndex struct {
+	byAirport map[string][]Runway
+}
+
+// airportFile is the airports.json file the map loads its airports from.
+// Runways use the OurAirports column names the file is built from.
+type airportFile struct {
+	Airports []struct {
+		Ident   string `json:"ident"`
+		Runways []struct {
+			LengthFt number `json:"length_ft"`
+			Surface  string `json:"surface"`
+			Closed   number `json:"closed"`
+			LeIdent  string `json:"le_ident"`
+			LeLat    number `json:"le_latitude_deg"`
+			LeLon    number `json:"le_longitude_deg"`
+			LeHdg    number `json:"le_heading_degT"`
+			HeIdent  string `json:"he_ident"`
+			HeLat    number `json:"he_latitude_deg"`
+			HeLon    number `json:"he_longitude_deg"`
+			HeHdg    number `json:"he_heading_degT"`
+		} `json:"runways"`
+	} `json:"airports"`
+}
+
+// number is a JSON number that may also be written as a string. An
+// empty string or null leaves it unset.
+type number struct {
+	value float64
+	ok    bool
+}
+
+// UnmarshalJSON accepts 12, "12", "" and null
+func (n *number) UnmarshalJSON(b []byte) error {
+	s := string(bytes.Trim(b, `"`))
+	if s == "" || s == "null" {
+		return nil
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return err
+	}
+	n.value, n.ok = v, true
+	return nil
+}
+
+// LoadRunways reads the runways of the airports in an airports.json file.
+// Closed runways are skipped. A runway end without a true heading takes
+// the bearing between the runway ends; one without either is skipped,
+// since its number only gives the magnetic heading.
+func LoadRunways(path string) (*RunwayIndex, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f airportFile
+	err = json.Unmarshal(data, &f)
+	if err != nil {
+		return nil, err
+	}
+
+	idx := &RunwayIndex{byAirport: make(map[string][]Runway)}
+	for _, a := range f.Airports {
+		airport := strings.ToUpper(a.Ident)
+		for _, r := range a.Runways {
+			if r.Closed.value == 1 {
+				continue
+			}
+			ends := []struct {
+				ident string
+				hdg   float64
+				ok    bool
+			}{{ident: r.LeIdent}, {ident: r.HeIdent}}
+			ends[0].hdg, ends[0].ok = trueHeading(r.LeHdg, r.LeLat, r.LeLon, r.HeLat, r.HeLon)
+			ends[1].hdg, ends[1].ok = trueHeading(r.HeHdg, r.HeLat, r.HeLon, r.LeLat, r.LeLon)
+			for _, end := range ends {
+				if end.ident == "" || !end.ok {
+					continue
+				}
+				idx.byAirport[airport] = append(idx.byAirport[airport], Runway{
+					Ident:       end.ident,
+					HeadingTrue: end.hdg,
+					LengthFt:    int(r.LengthFt.value),
+					Surface:     r.Surface,
+				})
+			}
+		}
+	}
+	return idx, nil
+}
+
+// Runways returns the runway ends at an airport. A nil index has none.
+func (idx *RunwayIndex) Runways(airport string) []Runway {
+	if idx == nil {
+		return nil
+	}
+	return idx.byAirport[strings.ToUpper(airport)]
+}
+
+// trueHeading returns the given true heading of a runway end, or else the
+// bearing from it to the opposite end
+func trueHeading(hdg, lat, lon, toLat, toLon number) (float64, bool) {
+	if hdg.ok {
+		return hdg.value, true
+	}
+	if !lat.ok || !lon.ok || !toLat.ok || !toLon.ok {
+		return 0, false
+	}
+	return geo.BearingDeg(lat.value, lon.value, toLat.value, toLon.value), true
+}
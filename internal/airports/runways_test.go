@@ -0,0 +1,49 @@
+package airports
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testAirports = `{"airports": [
+	{"ident": "KDEN", "name": "Denver Intl", "runways": [
+		{"le_ident": "16L", "le_heading_degT": 172.5, "he_ident": "34R", "he_heading_degT": "352.5", "length_ft": "12000", "surface": "CON", "closed": 0},
+		{"le_ident": "08", "le_latitude_deg": 39.8, "le_longitude_deg": -104.7, "he_ident": "26", "he_latitude_deg": 39.8, "he_longitude_deg": -104.6, "length_ft": 12000},
+		{"le_ident": "07", "he_ident": "25", "length_ft": 16000},
+		{"le_ident": "17R", "le_heading_degT": 172.5, "he_ident": "35L", "he_heading_degT": 352.5, "closed": "1"}
+	]},
+	{"ident": "00A", "name": "Total Rf Heliport"}
+]}`
+
+func TestLoadRunways(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "airports.json")
+	if err := os.WriteFile(path, []byte(testAirports), 0644); err != nil {
+		t.Fatal(err)
+	}
+	idx, err := LoadRunways(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	runways := idx.Runways("kden")
+	// 07/25 has no true heading and 17R/35L is closed
+	if len(runways) != 4 {
+		t.Fatalf("runways %+v", runways)
+	}
+	want := []struct {
+		ident   string
+		heading float64
+	}{{"16L", 172.5}, {"34R", 352.5}, {"08", 90}, {"26", 270}}
+	for i, w := range want {
+		r := runways[i]
+		if r.Ident != w.ident || r.HeadingTrue < w.heading-0.1 || r.HeadingTrue > w.heading+0.1 {
+			t.Errorf("runway %d %+v, want %s at %v", i, r, w.ident, w.heading)
+		}
+	}
+	if runways[0].LengthFt != 12000 || runways[0].Surface != "CON" {
+		t.Errorf("runway 16L %+v", runways[0])
+	}
+	if len(idx.Runways("00A")) != 0 {
+		t.Error("heliport has runways")
+	}
+}
@@ -0,0 +1,120 @@
+package airsigmets
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"go-charts/internal/weather"
+	"time"
+)
+
+type Response struct {
+	XMLName      xml.Name   `xml:"response" json:"-"`
+	Version      string     `xml:"version,attr"`
+	RequestIndex int32      `xml:"request_index"`
+	Errors       []string   `xml:"errors>error"`
+	Warnings     []string   `xml:"warnings>warning"`
+	TimeTakenMs  int32      `xml:"time_taken_ms"`
+	DataSource   DataSource `xml:"data_source"`
+	Request      Request    `xml:"request"`
+	Data         Data       `xml:"data"`
+}
+
+type Request struct {
+	XMLName xml.Name `xml:"request" json:"-"`
+	Type    string   `xml:"type,attr"`
+}
+
+type DataSource struct {
+	XMLName xml.Name `xml:"data_source" json:"-"`
+	Name    string   `xml:"name,attr"`
+}
+
+type Data struct {
+	XMLName    xml.Name    `xml:"data" json:"-"`
+	NumResults int32       `xml:"num_results,attr"`
+	AirSigmets []AirSigmet `xml:"AIRSIGMET"`
+}
+
+// Altitude is the band an advisory covers. G-AIRMET freezing level lines
+// give a single level instead.
+type Altitude struct {
+	XMLName    xml.Name `xml:"altitude" json:"-"`
+	MinFtMsl   int32    `xml:"min_ft_msl,attr"`
+	MaxFtMsl   int32    `xml:"max_ft_msl,attr"`
+	LevelFtMsl int32    `xml:"level_ft_msl,attr" json:",omitempty"`
+}
+
+type Hazard struct {
+	XMLName  xml.Name `xml:"hazard" json:"-"`
+	Type     string   `xml:"type,attr"`
+	Severity string   `xml:"severity,attr"`
+}
+
+type Point struct {
+	XMLName   xml.Name `xml:"point" json:"-"`
+	Longitude float64  `xml:"longitude"`
+	Latitude  float64  `xml:"latitude"`
+}
+
+type Area struct {
+	XMLName   xml.Name `xml:"area" json:"-"`
+	NumPoints int32    `xml:"num_points,attr"`
+	Points    []Point  `xml:"point"`
+}
+
+// AirSigmet is an AIRMET, SIGMET, Convective SIGMET or convective outlook.
+// ADDS reports Convective SIGMETs as type SIGMET with a CONVECTIVE hazard.
+type AirSigmet struct {
+	XMLName            xml.Name  `xml:"AIRSIGMET" json:"-"`
+	RawText            string    `xml:"raw_text"`
+	ValidTimeFrom      time.Time `xml:"valid_time_from"`
+	ValidTimeTo        time.Time `xml:"valid_time_to"`
+	Altitude           Altitude  `xml:"altitude"`
+	MovementDirDegrees int32     `xml:"movement_dir_degrees"`
+	MovementSpeedKt    int32     `xml:"movement_speed_kt"`
+	Hazard             Hazard    `xml:"hazard"`
+	AirSigmetType      string    `xml:"airsigmet_type"`
+	Area               Area      `xml:"area"`
+}
+
+// IsConvective reports whether the advisory is a Convective SIGMET or outlook
+func (a *AirSigmet) IsConvective() bool {
+	return a.Hazard.Type == "CONVECTIVE"
+}
+
+// Count returns the number of advisories in the response
+func (r *Response) Count() int {
+	return len(r.Data.AirSigmets)
+}
+
+func (r *Response) ToJson() (s string, err error) {
+	bytes, err := json.Marshal(r.Data.AirSigmets)
+	if err != nil {
+		return "", err
+	}
+	s = string(bytes)
+	return
+}
+
+// FromJson replaces the advisories with those encoded by ToJson
+func (r *Response) FromJson(data []byte) error {
+	return json.Unmarshal(data, &r.Data.AirSigmets)
+}
+
+// Records returns the advisories keyed by validity start and raw text
+func (r *Response) Records() []weather.Record {
+	records := make([]weather.Record, 0, len(r.Data.AirSigmets))
+	for _, a := range r.Data.AirSigmets {
+		records = append(records, weather.Record{Key: a.ValidTimeFrom.Format(time.RFC3339Nano) + " " + a.RawText, Data: a})
+	}
+	return records
+}
+
+func (r *Response) ToJsonIndented() (s string, err error) {
+	bytes, err := json.MarshalIndent(r.Data.AirSigmets, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	s = string(bytes)
+	return
+}
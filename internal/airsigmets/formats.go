@@ -0,0 +1,167 @@
+package airsigmets
+
+import (
+	"go-charts/internal/geojson"
+	"go-charts/internal/weather"
+	"strconv"
+)
+
+// apiCoord is a vertex of a Data API advisory area
+type apiCoord struct {
+	Lat weather.Number `json:"lat"`
+	Lon weather.Number `json:"lon"`
+}
+
+// apiAirSigmet is an AIRMET or SIGMET as returned by the aviationweather.gov
+// Data API in JSON format
+type apiAirSigmet struct {
+	ValidTimeFrom weather.Time   `json:"validTimeFrom"`
+	ValidTimeTo   weather.Time   `json:"validTimeTo"`
+	AirSigmetType string         `json:"airSigmetType"`
+	Hazard        string         `json:"hazard"`
+	Severity      weather.Number `json:"severity"`
+	AltitudeLow1  weather.Number `json:"altitudeLow1"`
+	AltitudeHi1   weather.Number `json:"altitudeHi1"`
+	MovementDir   weather.Number `json:"movementDir"`
+	MovementSpd   weather.Number `json:"movementSpd"`
+	RawAirSigmet  string         `json:"rawAirSigmet"`
+	Coords        []apiCoord     `json:"coords"`
+}
+
+// apiGAirmet is a G-AIRMET as returned by the Data API in JSON format
+type apiGAirmet struct {
+	ReceiptTime  weather.Time   `json:"receiptTime"`
+	IssueTime    weather.Time   `json:"issueTime"`
+	ExpireTime   weather.Time   `json:"expireTime"`
+	ValidTime    weather.Time   `json:"validTime"`
+	Product      string         `json:"product"`
+	Tag          string         `json:"tag"`
+	ForecastHour weather.Number `json:"forecast"`
+	Hazard       string         `json:"hazard"`
+	Severity     string         `json:"severity"`
+	GeometryType string         `json:"geometryType"`
+	DueTo        string         `json:"dueTo"`
+	Base         weather.Number `json:"base"`
+	Top          weather.Number `json:"top"`
+	Level        weather.Number `json:"level"`
+	Coords       []apiCoord     `json:"coords"`
+}
+
+func apiArea(coords []apiCoord) Area {
+	a := Area{NumPoints: int32(len(coords))}
+	for _, c := range coords {
+		a.Points = append(a.Points, Point{Longitude: float64(c.Lon), Latitude: float64(c.Lat)})
+	}
+	return a
+}
+
+// decodeJSON maps a Data API JSON response onto a Response
+func decodeJSON(data []byte) (*Response, error) {
+	var list []apiAirSigmet
+	err := weather.DecodeJSONArray(data, &list)
+	if err != nil {
+		return nil, err
+	}
+	r := &Response{}
+	for _, a := range list {
+		severity := ""
+		if a.Severity != 0 {
+			severity = strconv.Itoa(int(a.Severity))
+		}
+		r.Data.AirSigmets = append(r.Data.AirSigmets, AirSigmet{
+			RawText:            a.RawAirSigmet,
+			ValidTimeFrom:      a.ValidTimeFrom.Time,
+			ValidTimeTo:        a.ValidTimeTo.Time,
+			Altitude:           Altitude{MinFtMsl: int32(a.AltitudeLow1), MaxFtMsl: int32(a.AltitudeHi1)},
+			MovementDirDegrees: int32(a.MovementDir),
+			MovementSpeedKt:    int32(a.MovementSpd),
+			Hazard:             Hazard{Type: a.Hazard, Severity: severity},
+			AirSigmetType:      a.AirSigmetType,
+			Area:               apiArea(a.Coords),
+		})
+	}
+	r.Data.NumResults = int32(len(r.Data.AirSigmets))
+	return r, nil
+}
+
+// decodeGAirmetJSON maps a Data API JSON response onto a GAirmetResponse.
+// Bases, tops and levels are in hundreds of feet; SFC and FZL decode as zero.
+func decodeGAirmetJSON(data []byte) (*GAirmetResponse, error) {
+	var list []apiGAirmet
+	err := weather.DecodeJSONArray(data, &list)
+	if err != nil {
+		return nil, err
+	}
+	r := &GAirmetResponse{}
+	for _, a := range list {
+		r.Data.GAirmets = append(r.Data.GAirmets, GAirmet{
+			ReceiptTime:  a.ReceiptTime.Time,
+			IssueTime:    a.IssueTime.Time,
+			ExpireTime:   a.ExpireTime.Time,
+			ValidTime:    a.ValidTime.Time,
+			Product:      a.Product,
+			Tag:          a.Tag,
+			ForecastHour: int32(a.ForecastHour),
+			Hazard:       Hazard{Type: a.Hazard, Severity: a.Severity},
+			GeometryType: a.GeometryType,
+			DueTo:        a.DueTo,
+			Altitude: Altitude{
+				MinFtMsl:   int32(a.Base) * 100,
+				MaxFtMsl:   int32(a.Top) * 100,
+				LevelFtMsl: int32(a.Level) * 100,
+			},
+			Area: apiArea(a.Coords),
+		})
+	}
+	r.Data.NumResults = int32(len(r.Data.GAirmets))
+	return r, nil
+}
+
+// positions returns the area vertices as lon/lat pairs
+func (a *Area) positions() [][2]float64 {
+	pos := make([][2]float64, 0, len(a.Points))
+	for _, p := range a.Points {
+		pos = append(pos, [2]float64{p.Longitude, p.Latitude})
+	}
+	return pos
+}
+
+// geometry returns the area as a Polygon, or nil when it has too few points
+func (a *Area) geometry() *geojson.Geometry {
+	if len(a.Points) < 3 {
+		return nil
+	}
+	return geojson.NewPolygon(a.positions())
+}
+
+// ToGeoJson encodes the advisories as a GeoJSON FeatureCollection of
+// polygons with the decoded fields as properties
+func (r *Response) ToGeoJson() (string, error) {
+	fc := geojson.NewFeatureCollection()
+	for _, a := range r.Data.AirSigmets {
+		f, err := geojson.NewFeature(nil, a.Area.geometry(), a)
+		if err != nil {
+			return "", err
+		}
+		fc.Features = append(fc.Features, f)
+	}
+	return fc.Encode()
+}
+
+// ToGeoJson encodes the G-AIRMETs as a GeoJSON FeatureCollection. AREA
+// snapshots become polygons and LINE snapshots line strings.
+func (r *GAirmetResponse) ToGeoJson() (string, error) {
+	fc := geojson.NewFeatureCollection()
+	for _, g := range r.Data.GAirmets {
+		geom := g.Area.geometry()
+		if g.GeometryType == "LINE" && len(g.Area.Points) >= 2 {
+			geom = geojson.NewLineString(g.Area.positions())
+		}
+		f, err := geojson.NewFeature(nil, geom, g)
+		if err != nil {
+			return "", err
+		}
+		fc.Features = append(fc.Features, f)
+	}
+	return fc.Encode()
+}
@@ -0,0 +1,83 @@
+package airsigmets
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"go-charts/internal/weather"
+	"time"
+)
+
+type GAirmetResponse struct {
+	XMLName      xml.Name    `xml:"response" json:"-"`
+	Version      string      `xml:"version,attr"`
+	RequestIndex int32       `xml:"request_index"`
+	Errors       []string    `xml:"errors>error"`
+	Warnings     []string    `xml:"warnings>warning"`
+	TimeTakenMs  int32       `xml:"time_taken_ms"`
+	DataSource   DataSource  `xml:"data_source"`
+	Request      Request     `xml:"request"`
+	Data         GAirmetData `xml:"data"`
+}
+
+type GAirmetData struct {
+	XMLName    xml.Name  `xml:"data" json:"-"`
+	NumResults int32     `xml:"num_results,attr"`
+	GAirmets   []GAirmet `xml:"GAIRMET"`
+}
+
+// GAirmet is one forecast snapshot of a graphical AIRMET. Product is
+// SIERRA, TANGO or ZULU and GeometryType is AREA or LINE; freezing levels
+// are drawn as lines.
+type GAirmet struct {
+	XMLName      xml.Name  `xml:"GAIRMET" json:"-"`
+	ReceiptTime  time.Time `xml:"receipt_time"`
+	IssueTime    time.Time `xml:"issue_time"`
+	ExpireTime   time.Time `xml:"expire_time"`
+	ValidTime    time.Time `xml:"valid_time"`
+	Product      string    `xml:"product"`
+	Tag          string    `xml:"tag"`
+	ForecastHour int32     `xml:"forecast_hour"`
+	Hazard       Hazard    `xml:"hazard"`
+	GeometryType string    `xml:"geometry_type"`
+	DueTo        string    `xml:"due_to"`
+	Altitude     Altitude  `xml:"altitude"`
+	Area         Area      `xml:"area"`
+}
+
+// Count returns the number of G-AIRMETs in the response
+func (r *GAirmetResponse) Count() int {
+	return len(r.Data.GAirmets)
+}
+
+func (r *GAirmetResponse) ToJson() (s string, err error) {
+	bytes, err := json.Marshal(r.Data.GAirmets)
+	if err != nil {
+		return "", err
+	}
+	s = string(bytes)
+	return
+}
+
+// FromJson replaces the G-AIRMETs with those encoded by ToJson
+func (r *GAirmetResponse) FromJson(data []byte) error {
+	return json.Unmarshal(data, &r.Data.GAirmets)
+}
+
+// Records returns the G-AIRMETs keyed by product, tag, hazard and valid time
+func (r *GAirmetResponse) Records() []weather.Record {
+	records := make([]weather.Record, 0, len(r.Data.GAirmets))
+	for _, g := range r.Data.GAirmets {
+		key := g.Product + " " + g.Tag + " " + g.Hazard.Type + " " + g.ValidTime.Format(time.RFC3339Nano)
+		records = append(records, weather.Record{Key: key, Data: g})
+	}
+	return records
+}
+
+func (r *GAirmetResponse) ToJsonIndented() (s string, err error) {
+	bytes, err := json.MarshalIndent(r.Data.GAirmets, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	s = string(bytes)
+	return
+}
@@ -0,0 +1,106 @@
+package airsigmets
+
+import (
+	"encoding/xml"
+	"fmt"
+	"go-charts/internal/weather"
+)
+
+// Source downloads and decodes AIRMETs, SIGMETs and Convective SIGMETs
+type Source struct {
+	url    string
+	format string
+}
+
+// NewSource returns an AIRMET/SIGMET source reading from url. format
+// selects the decoder: xml (ADDS or compressed cache XML) or json.
+// An empty format means xml.
+func NewSource(url, format string) *Source {
+	if format == "" {
+		format = weather.FormatXML
+	}
+	return &Source{url: url, format: format}
+}
+
+// Name returns the product name used for messages and files
+func (s *Source) Name() string {
+	return "airsigmets"
+}
+
+// URL returns the download location
+func (s *Source) URL() string {
+	return s.url
+}
+
+// Empty returns a Response with no reports
+func (s *Source) Empty() weather.Product {
+	return &Response{}
+}
+
+// Decode converts downloaded data in the configured format into a Response
+func (s *Source) Decode(data []byte) (weather.Product, error) {
+	var r *Response
+	var err error
+	switch s.format {
+	case weather.FormatXML:
+		r = &Response{}
+		err = xml.Unmarshal(data, r)
+	case weather.FormatJSON:
+		r, err = decodeJSON(data)
+	default:
+		err = fmt.Errorf("unsupported %s format %q", s.Name(), s.format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GAirmetSource downloads and decodes graphical AIRMETs
+type GAirmetSource struct {
+	url    string
+	format string
+}
+
+// NewGAirmetSource returns a G-AIRMET source reading from url. format is
+// xml or json; an empty format means xml.
+func NewGAirmetSource(url, format string) *GAirmetSource {
+	if format == "" {
+		format = weather.FormatXML
+	}
+	return &GAirmetSource{url: url, format: format}
+}
+
+// Name returns the product name used for messages and files
+func (s *GAirmetSource) Name() string {
+	return "gairmets"
+}
+
+// URL returns the download location
+func (s *GAirmetSource) URL() string {
+	return s.url
+}
+
+// Empty returns a GAirmetResponse with no reports
+func (s *GAirmetSource) Empty() weather.Product {
+	return &GAirmetResponse{}
+}
+
+// Decode converts downloaded data in the configured format into a GAirmetResponse
+func (s *GAirmetSource) Decode(data []byte) (weather.Product, error) {
+	var r *GAirmetResponse
+	var err error
+	switch s.format {
+	case weather.FormatXML:
+		r = &GAirmetResponse{}
+		err = xml.Unmarshal(data, r)
+	case weather.FormatJSON:
+		r, err = decodeGAirmetJSON(data)
+	default:
+		err = fmt.Errorf("unsupported %s format %q", s.Name(), s.format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
@@ -0,0 +1,312 @@
+package alerts
+
+import (
+	"fmt"
+	"go-charts/internal/flightrules"
+	"go-charts/internal/geo"
+	"go-charts/internal/metars"
+	"go-charts/internal/pireps"
+	"go-charts/internal/tafs"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxRecent is how many fired alerts are kept for the API
+const maxRecent = 100
+
+// Data is the weather the rules are evaluated against
+type Data interface {
+	Metar(station string) (metars.Metar, bool)
+	Taf(station string) (tafs.Taf, bool)
+	Pireps() []pireps.Pirep
+	Locate(ident string) (lat, lon float64, ok bool)
+}
+
+// Engine holds the alert rules and what each has already alerted on
+type Engine struct {
+	mu     sync.Mutex
+	rules  []*ruleState
+	nextId int
+	recent []Alert
+}
+
+type ruleState struct {
+	rule Rule
+	// evaluated is false until the first evaluation with data, which
+	// records the current PIREPs and TAF without alerting on them. Products
+	// download independently, so an evaluation before the first PIREP or
+	// TAF download must not count.
+	evaluated bool
+	// active is the last state of a category, gust or wind condition
+	active bool
+	// seen holds the PIREPs or TAF issue times already alerted on
+	seen map[string]bool
+}
+
+// NewEngine returns an Engine with no rules
+func NewEngine() *Engine {
+	return &Engine{}
+}
+
+// Add validates a rule and adds it, assigning an id when it has none
+func (e *Engine) Add(r Rule) (Rule, error) {
+	r.normalize()
+	err := r.Validate()
+	if err != nil {
+		return r, err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if r.Id == "" {
+		for r.Id == "" || e.exists(r.Id) {
+			e.nextId++
+			r.Id = "rule" + strconv.Itoa(e.nextId)
+		}
+	} else if e.exists(r.Id) {
+		return r, fmt.Errorf("rule %s already exists", r.Id)
+	}
+	e.rules = append(e.rules, &ruleState{rule: r, seen: make(map[string]bool)})
+	return r, nil
+}
+
+// exists reports whether a rule id is taken. The caller holds mu.
+func (e *Engine) exists(id string) bool {
+	for _, s := range e.rules {
+		if s.rule.Id == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Remove deletes a rule and reports whether it existed
+func (e *Engine) Remove(id string) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for i, s := range e.rules {
+		if s.rule.Id == id {
+			e.rules = append(e.rules[:i], e.rules[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Rule returns the rule with the given id
+func (e *Engine) Rule(id string) (Rule, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, s := range e.rules {
+		if s.rule.Id == id {
+			return s.rule, true
+		}
+	}
+	return Rule{}, false
+}
+
+// Rules returns every rule in the order they were added
+func (e *Engine) Rules() []Rule {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	list := make([]Rule, 0, len(e.rules))
+	for _, s := range e.rules {
+		list = append(list, s.rule)
+	}
+	return list
+}
+
+// Recent returns the most recent alerts, oldest first
+func (e *Engine) Recent() []Alert {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]Alert{}, e.recent...)
+}
+
+// Evaluate checks every rule against the current weather. Station
+// conditions alert when they start and again when they clear; PIREP and
+// TAF rules alert once for each new report.
+func (e *Engine) Evaluate(d Data, now time.Time) []Alert {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var fired []Alert
+	for _, s := range e.rules {
+		var alerts []Alert
+		switch s.rule.Kind {
+		case KindCategory, KindGust, KindWind:
+			alerts = s.evaluateStation(d)
+		case KindPirep:
+			alerts = s.evaluatePireps(d)
+		case KindTafAmended:
+			alerts = s.evaluateTaf(d)
+		}
+		for i := range alerts {
+			alerts[i].RuleId = s.rule.Id
+			alerts[i].Kind = s.rule.Kind
+			alerts[i].Time = now
+		}
+		fired = append(fired, alerts...)
+	}
+	e.recent = append(e.recent, fired...)
+	if len(e.recent) > maxRecent {
+		e.recent = append([]Alert{}, e.recent[len(e.recent)-maxRecent:]...)
+	}
+	return fired
+}
+
+// evaluateStation alerts on a change of a METAR condition
+func (s *ruleState) evaluateStation(d Data) []Alert {
+	r := &s.rule
+	m, ok := d.Metar(r.Station)
+	if !ok {
+		return nil
+	}
+	var active bool
+	var message, cleared string
+	switch r.Kind {
+	case KindCategory:
+		active = flightrules.Worse(m.FlightCategory, r.Category)
+		message = fmt.Sprintf("%s is %s, below %s", r.Station, m.FlightCategory, r.Category)
+		cleared = fmt.Sprintf("%s is back to %s", r.Station, m.FlightCategory)
+	case KindGust:
+		active = float64(m.WindGustKt) > r.Threshold
+		message = fmt.Sprintf("%s gusting %d kt, above %.0f kt", r.Station, m.WindGustKt, r.Threshold)
+		cleared = fmt.Sprintf("%s gusts are %.0f kt or less", r.Station, r.Threshold)
+	case KindWind:
+		active = float64(m.WindSpeedKt) > r.Threshold
+		message = fmt.Sprintf("%s wind %d kt, above %.0f kt", r.Station, m.WindSpeedKt, r.Threshold)
+		cleared = fmt.Sprintf("%s wind is %.0f kt or less", r.Station, r.Threshold)
+	}
+	if active == s.active {
+		return nil
+	}
+	s.active = active
+	a := Alert{Station: r.Station, Message: message, RawText: m.RawText}
+	if !active {
+		a.Message = cleared
+		a.Cleared = true
+	}
+	return []Alert{a}
+}
+
+// evaluatePireps alerts on each PIREP not seen before that matches the rule
+func (s *ruleState) evaluatePireps(d Data) []Alert {
+	r := &s.rule
+	var centerLat, centerLon float64
+	if r.RadiusNm > 0 {
+		centerLat, centerLon = r.Latitude, r.Longitude
+		if r.Station != "" {
+			var ok bool
+			centerLat, centerLon, ok = d.Locate(r.Station)
+			if !ok {
+				return nil
+			}
+		}
+	}
+	list := d.Pireps()
+	if len(list) == 0 {
+		return nil
+	}
+	var alerts []Alert
+	current := make(map[string]bool)
+	for _, p := range list {
+		key := p.ObservationTime.Format(time.RFC3339Nano) + " " + p.RawText
+		current[key] = true
+		if s.seen[key] {
+			continue
+		}
+		if p.QualityControlFlags.BadLocation == "TRUE" && r.RadiusNm > 0 {
+			continue
+		}
+		hazard, intensity, ok := r.matchPirep(&p)
+		if !ok {
+			continue
+		}
+		dist := 0.0
+		if r.RadiusNm > 0 {
+			dist = geo.DistanceNm(centerLat, centerLon, p.Latitude, p.Longitude)
+			if dist > r.RadiusNm {
+				continue
+			}
+		}
+		if s.evaluated {
+			message := fmt.Sprintf("%s %s PIREP at %.0f ft", intensity, hazard, p.AltitudeFtMsl)
+			if r.RadiusNm > 0 {
+				where := r.Station
+				if where == "" {
+					where = fmt.Sprintf("%.2f, %.2f", r.Latitude, r.Longitude)
+				}
+				message += fmt.Sprintf(", %.0f nm from %s", dist, where)
+			}
+			alerts = append(alerts, Alert{Station: r.Station, Message: message, RawText: p.RawText})
+		}
+	}
+	// only PIREPs still in the snapshot need remembering
+	s.seen = current
+	s.evaluated = true
+	return alerts
+}
+
+// matchPirep returns the worst turbulence or icing report of the PIREP
+// that the rule watches for
+func (r *Rule) matchPirep(p *pireps.Pirep) (string, string, bool) {
+	min := intensityRank(r.Intensity)
+	if r.Intensity == "" {
+		min = intensityRank("LGT")
+	}
+	hazard, intensity, best := "", "", -1
+	if r.Hazard == "" || r.Hazard == HazardTurbulence {
+		for _, t := range p.TurbulenceCondition {
+			if rank := intensityRank(t.TurbulenceIntensity); rank >= min && rank > best {
+				hazard, intensity, best = HazardTurbulence, t.TurbulenceIntensity, rank
+			}
+		}
+	}
+	if r.Hazard == "" || r.Hazard == HazardIcing {
+		for _, i := range p.IcingCondition {
+			if rank := intensityRank(i.IcingIntensity); rank >= min && rank > best {
+				hazard, intensity, best = HazardIcing, i.IcingIntensity, rank
+			}
+		}
+	}
+	return hazard, intensity, best >= 0
+}
+
+// evaluateTaf alerts when the station's latest TAF is a new amendment
+func (s *ruleState) evaluateTaf(d Data) []Alert {
+	r := &s.rule
+	t, ok := d.Taf(r.Station)
+	if !ok {
+		return nil
+	}
+	key := t.IssueTime.Format(time.RFC3339Nano)
+	if s.seen[key] {
+		return nil
+	}
+	s.seen = map[string]bool{key: true}
+	first := !s.evaluated
+	s.evaluated = true
+	if first || !isAmended(t.RawText) {
+		return nil
+	}
+	return []Alert{{
+		Station: r.Station,
+		Message: fmt.Sprintf("%s TAF amended at %s", r.Station, t.IssueTime.UTC().Format("021504Z")),
+		RawText: t.RawText,
+	}}
+}
+
+// isAmended reports whether a raw TAF is an amendment, TAF AMD
+func isAmended(raw string) bool {
+	fields := strings.Fields(raw)
+	for i, f := range fields {
+		if f == "AMD" {
+			return true
+		}
+		if i >= 1 {
+			break
+		}
+	}
+	return false
+}
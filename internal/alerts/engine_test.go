@@ -0,0 +1,223 @@
+package alerts
+
+import (
+	"go-charts/internal/metars"
+	"go-charts/internal/pireps"
+	"go-charts/internal/tafs"
+	"testing"
+	"time"
+)
+
+// testData is the weather for one evaluation. A nil map means the product
+// has not downloaded yet.
+type testData struct {
+	metars map[string]metars.Metar
+	tafs   map[string]tafs.Taf
+	pireps []pireps.Pirep
+}
+
+func (d testData) Metar(station string) (metars.Metar, bool) {
+	m, ok := d.metars[station]
+	return m, ok
+}
+
+func (d testData) Taf(station string) (tafs.Taf, bool) {
+	t, ok := d.tafs[station]
+	return t, ok
+}
+
+func (d testData) Pireps() []pireps.Pirep {
+	return d.pireps
+}
+
+func (d testData) Locate(ident string) (float64, float64, bool) {
+	if ident == "KDEN" {
+		return 39.86, -104.67, true
+	}
+	return 0, 0, false
+}
+
+func metar(category string, windKt, gustKt int32) testData {
+	return testData{metars: map[string]metars.Metar{
+		"KDEN": {StationId: "KDEN", FlightCategory: category, WindSpeedKt: windKt, WindGustKt: gustKt},
+	}}
+}
+
+func taf(raw string, issued int) testData {
+	return testData{tafs: map[string]tafs.Taf{
+		"KDEN": {StationId: "KDEN", RawText: raw, IssueTime: time.Date(2026, 10, 18, issued, 0, 0, 0, time.UTC)},
+	}}
+}
+
+func pirep(minute int, turbulence string, lat, lon float64) pireps.Pirep {
+	p := pireps.Pirep{
+		RawText:         "DEN UA /TB " + turbulence,
+		ObservationTime: time.Date(2026, 10, 18, 17, minute, 0, 0, time.UTC),
+		Latitude:        lat,
+		Longitude:       lon,
+		AltitudeFtMsl:   12000,
+	}
+	p.TurbulenceCondition = []pireps.TurbulenceCondition{{TurbulenceIntensity: turbulence}}
+	return p
+}
+
+func pirepData(list ...pireps.Pirep) testData {
+	return testData{pireps: list}
+}
+
+func TestEngineEvaluate(t *testing.T) {
+	// each step is one evaluation; want lists whether each alert fired or cleared
+	type step struct {
+		data testData
+		want []string
+	}
+	tests := []struct {
+		name  string
+		rule  Rule
+		steps []step
+	}{
+		{
+			name: "category start and clear",
+			rule: Rule{Kind: KindCategory, Station: "KDEN", Category: "MVFR"},
+			steps: []step{
+				{metar("VFR", 5, 0), nil},
+				{metar("IFR", 5, 0), []string{"fired"}},
+				{metar("LIFR", 5, 0), nil},
+				{metar("MVFR", 5, 0), []string{"cleared"}},
+				{metar("MVFR", 5, 0), nil},
+			},
+		},
+		{
+			name: "category already active at the first evaluation",
+			rule: Rule{Kind: KindCategory, Station: "KDEN", Category: "VFR"},
+			steps: []step{
+				{metar("IFR", 5, 0), []string{"fired"}},
+				{metar("IFR", 5, 0), nil},
+			},
+		},
+		{
+			name: "station missing from the snapshot",
+			rule: Rule{Kind: KindCategory, Station: "KDEN", Category: "VFR"},
+			steps: []step{
+				{testData{}, nil},
+				{metar("MVFR", 5, 0), []string{"fired"}},
+				{testData{}, nil},
+				{metar("VFR", 5, 0), []string{"cleared"}},
+			},
+		},
+		{
+			name: "gust above threshold",
+			rule: Rule{Kind: KindGust, Station: "KDEN", Threshold: 30},
+			steps: []step{
+				{metar("VFR", 15, 30), nil},
+				{metar("VFR", 15, 31), []string{"fired"}},
+				{metar("VFR", 15, 0), []string{"cleared"}},
+			},
+		},
+		{
+			name: "wind above threshold",
+			rule: Rule{Kind: KindWind, Station: "KDEN", Threshold: 20},
+			steps: []step{
+				{metar("VFR", 25, 35), []string{"fired"}},
+				{metar("VFR", 20, 35), []string{"cleared"}},
+			},
+		},
+		{
+			name: "PIREPs at the first evaluation are the baseline",
+			rule: Rule{Kind: KindPirep, Hazard: HazardTurbulence, Intensity: "MOD"},
+			steps: []step{
+				{pirepData(pirep(0, "SEV", 39.9, -104.7)), nil},
+				{pirepData(pirep(0, "SEV", 39.9, -104.7), pirep(5, "MOD", 39.9, -104.7)), []string{"fired"}},
+				{pirepData(pirep(5, "MOD", 39.9, -104.7), pirep(10, "LGT", 39.9, -104.7)), nil},
+			},
+		},
+		{
+			name: "no PIREP download is not the baseline",
+			rule: Rule{Kind: KindPirep, Intensity: "MOD"},
+			steps: []step{
+				{testData{}, nil},
+				{pirepData(pirep(0, "SEV", 39.9, -104.7)), nil},
+				{pirepData(pirep(0, "SEV", 39.9, -104.7), pirep(5, "MOD-SEV", 39.9, -104.7)), []string{"fired"}},
+			},
+		},
+		{
+			name: "PIREPs outside the radius",
+			rule: Rule{Kind: KindPirep, Station: "KDEN", RadiusNm: 50},
+			steps: []step{
+				{pirepData(pirep(0, "LGT", 39.9, -104.7)), nil},
+				{pirepData(pirep(5, "MOD", 39.5, -104.7), pirep(6, "MOD", 41.1, -104.8)), []string{"fired"}},
+			},
+		},
+		{
+			name: "TAF at the first evaluation is the baseline",
+			rule: Rule{Kind: KindTafAmended, Station: "KDEN"},
+			steps: []step{
+				{taf("TAF AMD KDEN 181000Z 1810/1912 27012KT P6SM SKC", 10), nil},
+				{taf("TAF AMD KDEN 181000Z 1810/1912 27012KT P6SM SKC", 10), nil},
+				{taf("TAF KDEN 181120Z 1812/1918 27012KT P6SM SKC", 11), nil},
+				{taf("TAF AMD KDEN 181300Z 1813/1918 27012KT P6SM SKC", 13), []string{"fired"}},
+				{taf("TAF AMD KDEN 181300Z 1813/1918 27012KT P6SM SKC", 13), nil},
+			},
+		},
+		{
+			name: "no TAF download is not the baseline",
+			rule: Rule{Kind: KindTafAmended, Station: "KDEN"},
+			steps: []step{
+				{testData{}, nil},
+				{taf("TAF AMD KDEN 181000Z 1810/1912 27012KT P6SM SKC", 10), nil},
+			},
+		},
+	}
+	now := time.Date(2026, 10, 18, 18, 0, 0, 0, time.UTC)
+	for _, tt := range tests {
+		e := NewEngine()
+		rule, err := e.Add(tt.rule)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		for i, st := range tt.steps {
+			fired := e.Evaluate(st.data, now)
+			got := make([]string, len(fired))
+			for j, a := range fired {
+				got[j] = "fired"
+				if a.Cleared {
+					got[j] = "cleared"
+				}
+				if a.RuleId != rule.Id || a.Kind != rule.Kind || !a.Time.Equal(now) {
+					t.Errorf("%s: step %d alert %+v not stamped with the rule and time", tt.name, i, a)
+				}
+			}
+			if len(got) != len(st.want) {
+				t.Errorf("%s: step %d alerts %q, want %q", tt.name, i, got, st.want)
+				continue
+			}
+			for j := range got {
+				if got[j] != st.want[j] {
+					t.Errorf("%s: step %d alerts %q, want %q", tt.name, i, got, st.want)
+					break
+				}
+			}
+		}
+	}
+}
+
+func TestEngineRecent(t *testing.T) {
+	e := NewEngine()
+	_, err := e.Add(Rule{Kind: KindWind, Station: "KDEN", Threshold: 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, 10, 18, 18, 0, 0, 0, time.UTC)
+	for i := 0; i < maxRecent+10; i++ {
+		e.Evaluate(metar("VFR", int32(10+20*(i%2)), 0), now.Add(time.Duration(i)*time.Minute))
+	}
+	recent := e.Recent()
+	// the first evaluation is below the threshold, so every later one alerts
+	if len(recent) != maxRecent {
+		t.Fatalf("%d recent alerts, want %d", len(recent), maxRecent)
+	}
+	if want := now.Add(time.Duration(maxRecent+9) * time.Minute); !recent[len(recent)-1].Time.Equal(want) {
+		t.Errorf("newest alert at %v, want %v", recent[len(recent)-1].Time, want)
+	}
+}
@@ -0,0 +1,133 @@
+package alerts
+
+import (
+	"fmt"
+	"go-charts/internal/flightrules"
+	"strings"
+	"time"
+)
+
+// Rule kinds
+const (
+	// KindCategory alerts when a station's flight category is worse than Category
+	KindCategory = "category"
+	// KindGust alerts when a station reports gusts above Threshold knots
+	KindGust = "gust"
+	// KindWind alerts when a station's sustained wind is above Threshold knots
+	KindWind = "wind"
+	// KindPirep alerts on each new PIREP of Hazard at or above Intensity,
+	// within RadiusNm of Station or Latitude/Longitude when a radius is given
+	KindPirep = "pirep"
+	// KindTafAmended alerts when an amended TAF is issued for a station
+	KindTafAmended = "tafamended"
+)
+
+// PIREP hazards a rule can watch for
+const (
+	HazardTurbulence = "turbulence"
+	HazardIcing      = "icing"
+)
+
+// Rule is a condition to watch for. Rules from the configuration file and
+// the API share this shape. Broadcast rules, which include every rule
+// from the configuration file, alert every client; other rules alert only
+// the clients subscribed to them.
+type Rule struct {
+	Id        string  `json:"id"`
+	Kind      string  `json:"kind"`
+	Station   string  `json:"station,omitempty"`
+	Category  string  `json:"category,omitempty"`
+	Threshold float64 `json:"threshold,omitempty"`
+	Hazard    string  `json:"hazard,omitempty"`
+	Intensity string  `json:"intensity,omitempty"`
+	Latitude  float64 `json:"latitude,omitempty"`
+	Longitude float64 `json:"longitude,omitempty"`
+	RadiusNm  float64 `json:"radiusnm,omitempty"`
+	Broadcast bool    `json:"broadcast,omitempty"`
+}
+
+// Alert is a rule firing, or clearing when the watched condition no
+// longer holds
+type Alert struct {
+	RuleId  string
+	Kind    string
+	Station string `json:",omitempty"`
+	Message string
+	Cleared bool
+	Time    time.Time
+	RawText string `json:",omitempty"`
+}
+
+// normalize upper-cases identifiers and categories and lower-cases the
+// kind and hazard so rules match however they were typed
+func (r *Rule) normalize() {
+	r.Kind = strings.ToLower(strings.TrimSpace(r.Kind))
+	r.Station = strings.ToUpper(strings.TrimSpace(r.Station))
+	r.Category = strings.ToUpper(strings.TrimSpace(r.Category))
+	r.Hazard = strings.ToLower(strings.TrimSpace(r.Hazard))
+	r.Intensity = strings.ToUpper(strings.TrimSpace(r.Intensity))
+}
+
+// Validate checks that the rule has what its kind needs
+func (r *Rule) Validate() error {
+	switch r.Kind {
+	case KindCategory:
+		if r.Station == "" {
+			return fmt.Errorf("%s rule needs a station", r.Kind)
+		}
+		if !flightrules.Worse(r.Category, "") || r.Category == flightrules.LIFR {
+			return fmt.Errorf("%s rule needs a category of VFR, MVFR or IFR", r.Kind)
+		}
+	case KindGust, KindWind:
+		if r.Station == "" {
+			return fmt.Errorf("%s rule needs a station", r.Kind)
+		}
+		if r.Threshold <= 0 {
+			return fmt.Errorf("%s rule needs a threshold in knots", r.Kind)
+		}
+	case KindPirep:
+		if r.Hazard != "" && r.Hazard != HazardTurbulence && r.Hazard != HazardIcing {
+			return fmt.Errorf("%s rule hazard must be %s or %s", r.Kind, HazardTurbulence, HazardIcing)
+		}
+		if r.Intensity != "" && intensityRank(r.Intensity) < 0 {
+			return fmt.Errorf("unknown intensity %q", r.Intensity)
+		}
+		if r.RadiusNm > 0 && r.Station == "" && r.Latitude == 0 && r.Longitude == 0 {
+			return fmt.Errorf("%s rule radius needs a station or latitude and longitude", r.Kind)
+		}
+	case KindTafAmended:
+		if r.Station == "" {
+			return fmt.Errorf("%s rule needs a station", r.Kind)
+		}
+	default:
+		return fmt.Errorf("unknown rule kind %q", r.Kind)
+	}
+	return nil
+}
+
+// intensityRank orders turbulence and icing intensities. A range such as
+// MOD-SEV ranks as its upper end; unknown intensities rank -1.
+func intensityRank(intensity string) int {
+	best := -1
+	for _, part := range strings.Split(strings.ToUpper(intensity), "-") {
+		r := -1
+		switch strings.TrimSpace(part) {
+		case "NEG", "NEGCLR", "SMTH":
+			r = 0
+		case "TRC":
+			r = 1
+		case "LGT":
+			r = 2
+		case "MOD":
+			r = 3
+		case "SEV", "HVY":
+			r = 4
+		case "EXTM", "EXTRM":
+			r = 5
+		}
+		if r > best {
+			best = r
+		}
+	}
+	return best
+}
@@ -0,0 +1,82 @@
+package flightrules
+
+// Flight categories as reported by ADDS
+const (
+	VFR  = "VFR"
+	MVFR = "MVFR"
+	IFR  = "IFR"
+	LIFR = "LIFR"
+)
+
+// Unknown marks a ceiling or visibility that was not reported
+const Unknown = -1
+
+// NoCeiling is the ceiling when the sky was reported but no layer forms one
+const NoCeiling = 99999
+
+// IsCeiling reports whether a sky cover forms a ceiling. OVX is the layer
+// ADDS uses for an indefinite ceiling (vertical visibility).
+func IsCeiling(cover string) bool {
+	return cover == "BKN" || cover == "OVC" || cover == "OVX" || cover == "VV"
+}
+
+// Category returns the flight category for a ceiling in feet AGL and a
+// visibility in statute miles, using the lower of the two. Pass Unknown
+// for an element that was not reported; an empty string is returned when
+// neither was.
+func Category(ceilingFt int, visSm float64) string {
+	cat := ""
+	if ceilingFt >= 0 {
+		switch {
+		case ceilingFt < 500:
+			cat = LIFR
+		case ceilingFt < 1000:
+			cat = IFR
+		case ceilingFt <= 3000:
+			cat = MVFR
+		default:
+			cat = VFR
+		}
+	}
+	if visSm >= 0 {
+		vcat := VFR
+		switch {
+		case visSm < 1:
+			vcat = LIFR
+		case visSm < 3:
+			vcat = IFR
+		case visSm <= 5:
+			vcat = MVFR
+		}
+		cat = Worst(cat, vcat)
+	}
+	return cat
+}
+
+// Worst returns the more restrictive of two categories. An empty string
+// loses to any category.
+func Worst(a, b string) string {
+	if rank(b) > rank(a) {
+		return b
+	}
+	return a
+}
+
+// Worse reports whether category a is more restrictive than category b
+func Worse(a, b string) bool {
+	return rank(a) > rank(b)
+}
+
+func rank(cat string) int {
+	switch cat {
+	case VFR:
+		return 1
+	case MVFR:
+		return 2
+	case IFR:
+		return 3
+	case LIFR:
+		return 4
+	}
+	return 0
+}
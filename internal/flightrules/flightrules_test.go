@@ -0,0 +1,62 @@
+package flightrules
+
+import "testing"
+
+func TestCategory(t *testing.T) {
+	tests := []struct {
+		ceilingFt int
+		visSm     float64
+		want      string
+	}{
+		{Unknown, Unknown, ""},
+		{NoCeiling, 10, VFR},
+		{NoCeiling, Unknown, VFR},
+		{Unknown, 10, VFR},
+		{3100, 6, VFR},
+		{3000, 6, MVFR},
+		{1000, 6, MVFR},
+		{999, 6, IFR},
+		{500, 6, IFR},
+		{499, 6, LIFR},
+		{0, 6, LIFR},
+		{NoCeiling, 5.01, VFR},
+		{NoCeiling, 5, MVFR},
+		{NoCeiling, 3, MVFR},
+		{NoCeiling, 2.99, IFR},
+		{NoCeiling, 1, IFR},
+		{NoCeiling, 0.75, LIFR},
+		{NoCeiling, 0, LIFR},
+		// the lower of the two wins
+		{800, 4, IFR},
+		{2500, 0.5, LIFR},
+		{Unknown, 2, IFR},
+		{400, Unknown, LIFR},
+	}
+	for _, tt := range tests {
+		if got := Category(tt.ceilingFt, tt.visSm); got != tt.want {
+			t.Errorf("Category(%d, %v) = %q, want %q", tt.ceilingFt, tt.visSm, got, tt.want)
+		}
+	}
+}
+
+func TestWorst(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want string
+	}{
+		{VFR, MVFR, MVFR},
+		{IFR, MVFR, IFR},
+		{LIFR, IFR, LIFR},
+		{"", VFR, VFR},
+		{VFR, "", VFR},
+		{"", "", ""},
+	}
+	for _, tt := range tests {
+		if got := Worst(tt.a, tt.b); got != tt.want {
+			t.Errorf("Worst(%q, %q) = %q, want %q", tt.a, tt.b, got, tt.want)
+		}
+		if got := Worse(tt.a, tt.b); got != (tt.want != tt.b) {
+			t.Errorf("Worse(%q, %q) = %t", tt.a, tt.b, got)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package geo
+
+import "math"
+
+// EarthRadiusNm is the mean radius of the earth in nautical miles
+const EarthRadiusNm = 3440.065
+
+func toRadians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+func toDegrees(rad float64) float64 {
+	return rad * 180 / math.Pi
+}
+
+// DistanceNm returns the great circle distance between two points in nautical miles
+func DistanceNm(lat1, lon1, lat2, lon2 float64) float64 {
+	dlat := toRadians(lat2 - lat1)
+	dlon := toRadians(lon2 - lon1)
+	a := math.Sin(dlat/2)*math.Sin(dlat/2) +
+		math.Cos(toRadians(lat1))*math.Cos(toRadians(lat2))*math.Sin(dlon/2)*math.Sin(dlon/2)
+	return 2 * EarthRadiusNm * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// BBox is a longitude/latitude bounding box
+type BBox struct {
+	MinLon, MinLat, MaxLon, MaxLat float64
+}
+
+// Contains reports whether a point lies inside the box. A box whose
+// MinLon is greater than its MaxLon crosses the antimeridian.
+func (b BBox) Contains(lat, lon float64) bool {
+	if lat < b.MinLat || lat > b.MaxLat {
+		return false
+	}
+	if b.MinLon <= b.MaxLon {
+		return lon >= b.MinLon && lon <= b.MaxLon
+	}
+	return lon >= b.MinLon || lon <= b.MaxLon
+}
+
+// BearingDeg returns the initial true course from the first point to the second
+func BearingDeg(lat1, lon1, lat2, lon2 float64) float64 {
+	phi1, phi2 := toRadians(lat1), toRadians(lat2)
+	dlon := toRadians(lon2 - lon1)
+	y := math.Sin(dlon) * math.Cos(phi2)
+	x := math.Cos(phi1)*math.Sin(phi2) - math.Sin(phi1)*math.Cos(phi2)*math.Cos(dlon)
+	return math.Mod(toDegrees(math.Atan2(y, x))+360, 360)
+}
+
+// Destination returns the point reached by travelling distNm along a
+// great circle from a start point on the given true course
+func Destination(lat, lon, bearingDeg, distNm float64) (float64, float64) {
+	phi1, lambda1 := toRadians(lat), toRadians(lon)
+	theta := toRadians(bearingDeg)
+	delta := distNm / EarthRadiusNm
+	phi2 := math.Asin(math.Sin(phi1)*math.Cos(delta) + math.Cos(phi1)*math.Sin(delta)*math.Cos(theta))
+	lambda2 := lambda1 + math.Atan2(math.Sin(theta)*math.Sin(delta)*math.Cos(phi1),
+		math.Cos(delta)-math.Sin(phi1)*math.Sin(phi2))
+	return toDegrees(phi2), math.Mod(toDegrees(lambda2)+540, 360) - 180
+}
+
+// PolygonContains reports whether a point lies inside a ring of
+// [longitude, latitude] vertices, using the even-odd rule
+func PolygonContains(lat, lon float64, ring [][2]float64) bool {
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		xi, yi := ring[i][0], ring[i][1]
+		xj, yj := ring[j][0], ring[j][1]
+		if (yi > lat) != (yj > lat) && lon < (xj-xi)*(lat-yi)/(yj-yi)+xi {
+			inside = !inside
+		}
+	}
+	return inside
+}
@@ -0,0 +1,97 @@
+package geojson
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// FeatureCollection is a GeoJSON FeatureCollection
+type FeatureCollection struct {
+	Type     string    `json:"type"`
+	Features []Feature `json:"features"`
+}
+
+// Feature is a GeoJSON Feature. Properties are kept raw so that each
+// product can decode them into its own structure.
+type Feature struct {
+	Type       string          `json:"type"`
+	ID         interface{}     `json:"id,omitempty"`
+	Geometry   *Geometry       `json:"geometry"`
+	Properties json.RawMessage `json:"properties"`
+}
+
+// Geometry is a GeoJSON geometry with undecoded coordinates
+type Geometry struct {
+	Type        string          `json:"type"`
+	Coordinates json.RawMessage `json:"coordinates"`
+}
+
+// Decode unmarshals a FeatureCollection
+func Decode(data []byte) (*FeatureCollection, error) {
+	var fc FeatureCollection
+	err := json.Unmarshal(data, &fc)
+	if err != nil {
+		return nil, err
+	}
+	if fc.Type != "FeatureCollection" {
+		return nil, fmt.Errorf("expected a FeatureCollection, got %q", fc.Type)
+	}
+	return &fc, nil
+}
+
+// Point returns the longitude and latitude of a Point geometry
+func (g *Geometry) Point() (lon, lat float64, ok bool) {
+	if g == nil || g.Type != "Point" {
+		return 0, 0, false
+	}
+	var c []float64
+	if json.Unmarshal(g.Coordinates, &c) != nil || len(c) < 2 {
+		return 0, 0, false
+	}
+	return c[0], c[1], true
+}
+
+// NewPoint returns a Point geometry
+func NewPoint(lon, lat float64) *Geometry {
+	c, _ := json.Marshal([]float64{lon, lat})
+	return &Geometry{Type: "Point", Coordinates: c}
+}
+
+// NewFeature returns a Feature with the given geometry and properties
+func NewFeature(id interface{}, g *Geometry, props interface{}) (Feature, error) {
+	p, err := json.Marshal(props)
+	if err != nil {
+		return Feature{}, err
+	}
+	return Feature{Type: "Feature", ID: id, Geometry: g, Properties: p}, nil
+}
+
+// NewFeatureCollection returns an empty FeatureCollection
+func NewFeatureCollection() *FeatureCollection {
+	return &FeatureCollection{Type: "FeatureCollection", Features: []Feature{}}
+}
+
+// Encode marshals the collection to a string
+func (fc *FeatureCollection) Encode() (string, error) {
+	b, err := json.Marshal(fc)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// NewPolygon returns a Polygon geometry with one ring of lon/lat
+// positions, closing the ring if needed
+func NewPolygon(ring [][2]float64) *Geometry {
+	if len(ring) > 0 && ring[0] != ring[len(ring)-1] {
+		ring = append(ring, ring[0])
+	}
+	c, _ := json.Marshal([][][2]float64{ring})
+	return &Geometry{Type: "Polygon", Coordinates: c}
+}
+
+// NewLineString returns a LineString geometry of lon/lat positions
+func NewLineString(line [][2]float64) *Geometry {
+	c, _ := json.Marshal(line)
+	return &Geometry{Type: "LineString", Coordinates: c}
+}
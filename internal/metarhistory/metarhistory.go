@@ -0,0 +1,141 @@
+package metarhistory
+
+import (
+	"database/sql"
+	"encoding/json"
+	"go-charts/internal/metars"
+	"strings"
+	"sync"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const schema = `CREATE TABLE IF NOT EXISTS metar_history (
+	station_id       TEXT NOT NULL,
+	observation_time TEXT NOT NULL,
+	raw_text         TEXT NOT NULL,
+	flight_category  TEXT,
+	temp_c           REAL,
+	dewpoint_c       REAL,
+	wind_dir_degrees INTEGER,
+	wind_speed_kt    INTEGER,
+	wind_gust_kt     INTEGER,
+	visibility_sm    REAL,
+	altim_in_hg      REAL,
+	metar_json       TEXT NOT NULL,
+	UNIQUE (station_id, observation_time)
+);
+CREATE INDEX IF NOT EXISTS metar_history_time ON metar_history (observation_time);`
+
+// timeLayout sorts lexically in time order, so SQLite can compare the text column
+const timeLayout = "2006-01-02T15:04:05Z"
+
+// Archive keeps every downloaded METAR in a SQLite database
+type Archive struct {
+	db        *sql.DB
+	retention time.Duration
+	mu        sync.Mutex
+}
+
+// Open opens or creates the archive database. Observations older than
+// retention are pruned on every Add; zero keeps them forever.
+func Open(path string, retention time.Duration) (*Archive, error) {
+	db, err := sql.Open("sqlite3", "file:"+path+"?mode=rwc&_busy_timeout=5000")
+	if err != nil {
+		return nil, err
+	}
+	_, err = db.Exec(schema)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Archive{db: db, retention: retention}, nil
+}
+
+// Close closes the database
+func (a *Archive) Close() error {
+	return a.db.Close()
+}
+
+// Add stores the METARs, ignoring any station and observation time that
+// is already archived, then prunes expired rows. It returns the number of
+// new observations.
+func (a *Archive) Add(list []metars.Metar) (int, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	tx, err := a.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	stmt, err := tx.Prepare(`INSERT OR IGNORE INTO metar_history (station_id, observation_time, raw_text,
+		flight_category, temp_c, dewpoint_c, wind_dir_degrees, wind_speed_kt, wind_gust_kt,
+		visibility_sm, altim_in_hg, metar_json) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	defer stmt.Close()
+
+	var cutoff time.Time
+	if a.retention > 0 {
+		cutoff = time.Now().UTC().Add(-a.retention)
+	}
+	added := 0
+	for _, m := range list {
+		if m.StationId == "" || m.ObservationTime.IsZero() || m.ObservationTime.Before(cutoff) {
+			continue
+		}
+		js, err := json.Marshal(m)
+		if err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+		res, err := stmt.Exec(strings.ToUpper(m.StationId), m.ObservationTime.UTC().Format(timeLayout), m.RawText,
+			m.FlightCategory, m.TempC, m.DewpointC, m.WindDirDegrees, m.WindSpeedKt, m.WindGustKt,
+			m.VisibilityStatuteMi, m.AltimInHg, string(js))
+		if err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+		n, _ := res.RowsAffected()
+		added += int(n)
+	}
+	if a.retention > 0 {
+		_, err = tx.Exec("DELETE FROM metar_history WHERE observation_time < ?", cutoff.Format(timeLayout))
+		if err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+	}
+	return added, tx.Commit()
+}
+
+// Series returns the archived METARs for a station observed at or after
+// since, oldest first
+func (a *Archive) Series(station string, since time.Time) ([]metars.Metar, error) {
+	rows, err := a.db.Query(`SELECT metar_json FROM metar_history
+		WHERE station_id = ? AND observation_time >= ? ORDER BY observation_time`,
+		strings.ToUpper(station), since.UTC().Format(timeLayout))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	series := make([]metars.Metar, 0)
+	for rows.Next() {
+		var js string
+		err = rows.Scan(&js)
+		if err != nil {
+			return nil, err
+		}
+		var m metars.Metar
+		err = json.Unmarshal([]byte(js), &m)
+		if err != nil {
+			return nil, err
+		}
+		series = append(series, m)
+	}
+	return series, rows.Err()
+}
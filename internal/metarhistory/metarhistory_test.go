@@ -0,0 +1,151 @@
+package metarhistory
+
+import (
+	"go-charts/internal/metars"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestArchiveAddSeries(t *testing.T) {
+	// retention is measured from the wall clock
+	now := time.Now().UTC().Truncate(time.Minute)
+	obs := func(station string, ago time.Duration) metars.Metar {
+		return metars.Metar{StationId: station, ObservationTime: now.Add(-ago), RawText: station + " " + ago.String()}
+	}
+	tests := []struct {
+		name      string
+		retention time.Duration
+		batches   [][]metars.Metar
+		added     []int
+		station   string
+		since     time.Duration
+		want      []string
+	}{
+		{
+			name:    "oldest first",
+			batches: [][]metars.Metar{{obs("KDEN", 0), obs("KDEN", 2*time.Hour), obs("KDEN", time.Hour)}},
+			added:   []int{3},
+			station: "KDEN",
+			since:   24 * time.Hour,
+			want:    []string{"KDEN 2h0m0s", "KDEN 1h0m0s", "KDEN 0s"},
+		},
+		{
+			name: "duplicates ignored",
+			batches: [][]metars.Metar{
+				{obs("KDEN", time.Hour), obs("KDEN", time.Hour)},
+				{obs("KDEN", time.Hour), obs("KDEN", 0)},
+			},
+			added:   []int{1, 1},
+			station: "KDEN",
+			since:   24 * time.Hour,
+			want:    []string{"KDEN 1h0m0s", "KDEN 0s"},
+		},
+		{
+			name:    "station case folded and other stations left out",
+			batches: [][]metars.Metar{{obs("kden", 0), obs("KCOS", 0)}},
+			added:   []int{2},
+			station: "Kden",
+			since:   time.Hour,
+			want:    []string{"kden 0s"},
+		},
+		{
+			name:    "since bounds the series",
+			batches: [][]metars.Metar{{obs("KDEN", 3*time.Hour), obs("KDEN", 90*time.Minute), obs("KDEN", 0)}},
+			added:   []int{3},
+			station: "KDEN",
+			since:   2 * time.Hour,
+			want:    []string{"KDEN 1h30m0s", "KDEN 0s"},
+		},
+		{
+			name:    "incomplete reports skipped",
+			batches: [][]metars.Metar{{{StationId: "KDEN"}, {ObservationTime: now}, obs("KDEN", 0)}},
+			added:   []int{1},
+			station: "KDEN",
+			since:   time.Hour,
+			want:    []string{"KDEN 0s"},
+		},
+		{
+			name:      "expired reports not added",
+			retention: 6 * time.Hour,
+			batches:   [][]metars.Metar{{obs("KDEN", 7*time.Hour), obs("KDEN", 5*time.Hour)}},
+			added:     []int{1},
+			station:   "KDEN",
+			since:     24 * time.Hour,
+			want:      []string{"KDEN 5h0m0s"},
+		},
+		{
+			name:      "no retention keeps everything",
+			retention: 0,
+			batches:   [][]metars.Metar{{obs("KDEN", 30*24*time.Hour)}},
+			added:     []int{1},
+			station:   "KDEN",
+			since:     31 * 24 * time.Hour,
+			want:      []string{"KDEN 720h0m0s"},
+		},
+	}
+	for _, tt := range tests {
+		a, err := Open(filepath.Join(t.TempDir(), "history.db"), tt.retention)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, batch := range tt.batches {
+			n, err := a.Add(batch)
+			if err != nil {
+				t.Errorf("%s: batch %d: %v", tt.name, i, err)
+			} else if n != tt.added[i] {
+				t.Errorf("%s: batch %d added %d, want %d", tt.name, i, n, tt.added[i])
+			}
+		}
+		series, err := a.Series(tt.station, now.Add(-tt.since))
+		a.Close()
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		got := make([]string, len(series))
+		for i, m := range series {
+			got[i] = m.RawText
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: series %q, want %q", tt.name, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: series %q, want %q", tt.name, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestArchivePrunesExpired(t *testing.T) {
+	a, err := Open(filepath.Join(t.TempDir(), "history.db"), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	now := time.Now().UTC()
+	_, err = a.Add([]metars.Metar{{StationId: "KDEN", ObservationTime: now.Add(-59 * time.Minute)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// age the stored row past the retention period, then add again to prune it
+	_, err = a.db.Exec("UPDATE metar_history SET observation_time = ?", now.Add(-2*time.Hour).Format(timeLayout))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = a.Add([]metars.Metar{{StationId: "KCOS", ObservationTime: now}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	err = a.db.QueryRow("SELECT COUNT(*) FROM metar_history WHERE station_id = 'KDEN'").Scan(&n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Errorf("%d expired rows kept, want 0", n)
+	}
+}
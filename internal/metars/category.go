@@ -0,0 +1,60 @@
+package metars
+
+import (
+	"go-charts/internal/flightrules"
+	"strings"
+)
+
+// Ceiling returns the lowest BKN, OVC or OVX base, or the vertical
+// visibility, in feet AGL. It returns flightrules.NoCeiling when the sky
+// was reported without a ceiling and flightrules.Unknown when it was not
+// reported at all.
+func (m *Metar) Ceiling() int {
+	ceiling := flightrules.Unknown
+	if len(m.SkyCondition) > 0 {
+		ceiling = flightrules.NoCeiling
+	}
+	for _, sc := range m.SkyCondition {
+		// ADDS reports an indefinite ceiling as OVX at 0 with the height in VertVisFt
+		if sc.SkyCover == "OVX" && m.VertVisFt > 0 {
+			continue
+		}
+		if flightrules.IsCeiling(sc.SkyCover) && int(sc.CloudBaseFtAGL) < ceiling {
+			ceiling = int(sc.CloudBaseFtAGL)
+		}
+	}
+	if m.VertVisFt > 0 && int(m.VertVisFt) < ceiling {
+		ceiling = int(m.VertVisFt)
+	}
+	return ceiling
+}
+
+// visibility returns the reported visibility or flightrules.Unknown. ADDS
+// leaves the field empty when it is missing, so zero only counts when the
+// raw report actually says so.
+func (m *Metar) visibility() float64 {
+	if m.VisibilityStatuteMi > 0 {
+		return m.VisibilityStatuteMi
+	}
+	raw := " " + m.RawText + " "
+	if strings.Contains(raw, " 0SM ") || strings.Contains(raw, " 0000 ") || strings.Contains(raw, " M1/4SM ") {
+		return 0
+	}
+	return flightrules.Unknown
+}
+
+// ComputeFlightCategory derives VFR, MVFR, IFR or LIFR from the ceiling
+// and visibility
+func (m *Metar) ComputeFlightCategory() string {
+	return flightrules.Category(m.Ceiling(), m.visibility())
+}
+
+// fillFlightCategories sets the flight category of every METAR that the
+// source did not categorize
+func (r *Response) fillFlightCategories() {
+	for i := range r.Data.Metars {
+		if r.Data.Metars[i].FlightCategory == "" {
+			r.Data.Metars[i].FlightCategory = r.Data.Metars[i].ComputeFlightCategory()
+		}
+	}
+}
@@ -0,0 +1,405 @@
+package metars
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Observation is a METAR or SPECI decoded from its raw text
+type Observation struct {
+	RawText           string
+	MetarType         string
+	StationId         string
+	ObservationTime   time.Time
+	Auto              bool
+	Corrected         bool
+	Wind              Wind
+	Cavok             bool
+	Visibility        Visibility
+	RunwayVisualRange []RunwayVisualRange
+	Weather           []Weather
+	Clouds            []Cloud
+	VertVisFt         int32
+	VertVisMissing    bool
+	TempC             *float64
+	DewpointC         *float64
+	AltimInHg         float64
+	RemarksText       string
+	Remarks           Remarks
+}
+
+// Wind is the surface wind group, with any variable direction group that follows it
+type Wind struct {
+	DirDegrees     int32
+	Variable       bool
+	SpeedKt        int32
+	GustKt         int32
+	VarFromDegrees int32
+	VarToDegrees   int32
+	Missing        bool
+}
+
+// Visibility is the prevailing visibility. Meters is only set for metric reports.
+type Visibility struct {
+	StatuteMi   float64
+	Meters      int32
+	LessThan    bool
+	GreaterThan bool
+	Missing     bool
+}
+
+// RunwayVisualRange is a single Rxx/ group. Values are in the units of the report.
+type RunwayVisualRange struct {
+	Runway      string
+	Value       int32
+	ValuePrefix string
+	MaxValue    int32
+	MaxPrefix   string
+	Trend       string
+	UnitsFeet   bool
+	VariableRVR bool
+}
+
+// Weather is a present weather group, e.g. -SHRA or VCTS
+type Weather struct {
+	Raw        string
+	Intensity  string
+	Descriptor string
+	Phenomena  []string
+}
+
+// Cloud is a single cloud layer
+type Cloud struct {
+	SkyCover       string
+	CloudBaseFtAGL int32
+	CloudType      string
+	BaseMissing    bool
+}
+
+var (
+	reMetarTime  = regexp.MustCompile(`^(\d{2})(\d{2})(\d{2})Z$`)
+	reWind       = regexp.MustCompile(`^(\d{3}|VRB|///)(\d{2,3}|//)(?:G(\d{2,3}))?(KT|MPS|KMH)$`)
+	reWindVar    = regexp.MustCompile(`^(\d{3})V(\d{3})$`)
+	reVisSM      = regexp.MustCompile(`^([MP])?(\d{1,2}|\d/\d{1,2})SM$`)
+	reVisWhole   = regexp.MustCompile(`^\d$`)
+	reVisMeters  = regexp.MustCompile(`^(\d{4})(NDV)?$`)
+	reRVR        = regexp.MustCompile(`^R(\d{2}[LRC]?)/([PM])?(\d{4})(?:V([PM])?(\d{4}))?(FT)?/?([UDN])?$`)
+	reWeather    = regexp.MustCompile(`^(-|\+|VC)?(MI|PR|BC|DR|BL|SH|TS|FZ)?((?:DZ|RA|SN|SG|IC|PL|GR|GS|UP|BR|FG|FU|VA|DU|SA|HZ|PY|PO|SQ|FC|SS|DS)*)$`)
+	reCloud      = regexp.MustCompile(`^(FEW|SCT|BKN|OVC)(\d{3}|///)(CB|TCU|///)?$`)
+	reVertVis    = regexp.MustCompile(`^VV(\d{3}|///)$`)
+	reTemp       = regexp.MustCompile(`^(M?\d{2})/(M?\d{2})?$`)
+	reAltimeter  = regexp.MustCompile(`^([AQ])(\d{4})$`)
+	clearSkyCode = map[string]bool{"SKC": true, "CLR": true, "NSC": true, "NCD": true}
+)
+
+// Decode parses a raw METAR or SPECI string into an Observation.
+// A raw report carries only the day of month, so ref supplies the
+// year and month; it is normally the time the report was received.
+func Decode(raw string, ref time.Time) (*Observation, error) {
+	o := &Observation{RawText: strings.TrimSpace(raw), MetarType: "METAR"}
+	body := o.RawText
+	if i := strings.Index(" "+body+" ", " RMK "); i >= 0 {
+		o.RemarksText = strings.TrimSpace(body[i+3:])
+		body = strings.TrimSpace(body[:i])
+	}
+	tokens := strings.Fields(strings.TrimSuffix(body, "="))
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("empty METAR")
+	}
+
+	i := 0
+	if tokens[i] == "METAR" || tokens[i] == "SPECI" {
+		o.MetarType = tokens[i]
+		i++
+	}
+	if i < len(tokens) && tokens[i] == "COR" {
+		o.Corrected = true
+		i++
+	}
+	if i >= len(tokens) || len(tokens[i]) != 4 {
+		return nil, fmt.Errorf("station identifier not found in %q", raw)
+	}
+	o.StationId = tokens[i]
+	i++
+
+	if i >= len(tokens) {
+		return nil, fmt.Errorf("observation time not found in %q", raw)
+	}
+	t, err := decodeDayTime(tokens[i], ref)
+	if err != nil {
+		return nil, err
+	}
+	o.ObservationTime = t
+	o.Remarks = DecodeRemarks(o.RemarksText, t)
+	i++
+
+	for ; i < len(tokens); i++ {
+		tok := tokens[i]
+		switch {
+		case tok == "AUTO":
+			o.Auto = true
+		case tok == "COR":
+			o.Corrected = true
+		case tok == "NIL":
+			return o, nil
+		case tok == "NOSIG" || tok == "TEMPO" || tok == "BECMG":
+			// trend forecast, not part of the observation
+			return o, nil
+		case tok == "CAVOK":
+			o.Cavok = true
+			o.Visibility.Meters = 9999
+			o.Visibility.StatuteMi = 6
+			o.Visibility.GreaterThan = true
+		case reWind.MatchString(tok):
+			o.Wind = decodeWind(tok)
+		case reWindVar.MatchString(tok):
+			m := reWindVar.FindStringSubmatch(tok)
+			o.Wind.VarFromDegrees = atoi32(m[1])
+			o.Wind.VarToDegrees = atoi32(m[2])
+		case reVisWhole.MatchString(tok) && i+1 < len(tokens) && strings.HasSuffix(tokens[i+1], "SM"):
+			// whole and fractional miles, e.g. "1 1/2SM"
+			if v, ok := decodeVisibilitySM(tokens[i+1]); ok {
+				v.StatuteMi += float64(atoi32(tok))
+				o.Visibility = v
+				i++
+			}
+		case reVisSM.MatchString(tok):
+			o.Visibility, _ = decodeVisibilitySM(tok)
+		case tok == "////SM" || tok == "////":
+			o.Visibility.Missing = true
+		case reVisMeters.MatchString(tok) && o.Visibility.StatuteMi == 0 && !o.Visibility.Missing:
+			m := reVisMeters.FindStringSubmatch(tok)
+			o.Visibility.Meters = atoi32(m[1])
+			if o.Visibility.Meters == 9999 {
+				o.Visibility.GreaterThan = true
+			}
+			o.Visibility.StatuteMi = round(float64(o.Visibility.Meters)/1609.344, 2)
+		case reRVR.MatchString(tok):
+			o.RunwayVisualRange = append(o.RunwayVisualRange, decodeRVR(tok))
+		case reCloud.MatchString(tok):
+			m := reCloud.FindStringSubmatch(tok)
+			c := Cloud{SkyCover: m[1], CloudType: m[3]}
+			if m[3] == "///" {
+				c.CloudType = ""
+			}
+			if m[2] == "///" {
+				c.BaseMissing = true
+			} else {
+				c.CloudBaseFtAGL = atoi32(m[2]) * 100
+			}
+			o.Clouds = append(o.Clouds, c)
+		case clearSkyCode[tok]:
+			o.Clouds = append(o.Clouds, Cloud{SkyCover: tok})
+		case reVertVis.MatchString(tok):
+			m := reVertVis.FindStringSubmatch(tok)
+			if m[1] == "///" {
+				o.VertVisMissing = true
+			} else {
+				o.VertVisFt = atoi32(m[1]) * 100
+			}
+		case reTemp.MatchString(tok):
+			m := reTemp.FindStringSubmatch(tok)
+			o.TempC = signedTemp(m[1])
+			o.DewpointC = signedTemp(m[2])
+		case reAltimeter.MatchString(tok):
+			m := reAltimeter.FindStringSubmatch(tok)
+			v := float64(atoi32(m[2]))
+			if m[1] == "A" {
+				o.AltimInHg = v / 100
+			} else {
+				o.AltimInHg = round(v*0.0295300, 2)
+			}
+		case isWeather(tok):
+			o.Weather = append(o.Weather, decodeWeather(tok))
+		}
+	}
+	return o, nil
+}
+
+// ToMetar maps the observation onto the ADDS Metar structure
+func (o *Observation) ToMetar() Metar {
+	m := Metar{
+		RawText:             o.RawText,
+		StationId:           o.StationId,
+		ObservationTime:     o.ObservationTime,
+		WindDirDegrees:      o.Wind.DirDegrees,
+		WindSpeedKt:         o.Wind.SpeedKt,
+		WindGustKt:          o.Wind.GustKt,
+		VisibilityStatuteMi: o.Visibility.StatuteMi,
+		AltimInHg:           o.AltimInHg,
+		VertVisFt:           o.VertVisFt,
+		MetarType:           o.MetarType,
+	}
+	m.QualityControlFlags.AutoStation = o.Auto
+	if o.TempC != nil {
+		m.TempC = *o.TempC
+	}
+	if o.DewpointC != nil {
+		m.DewpointC = *o.DewpointC
+	}
+	// the T group in the remarks is more precise than the body temperature
+	if o.Remarks.PreciseTempC != nil {
+		m.TempC = *o.Remarks.PreciseTempC
+	}
+	if o.Remarks.PreciseDewpointC != nil {
+		m.DewpointC = *o.Remarks.PreciseDewpointC
+	}
+	m.SeaLevelPressureMb = o.Remarks.SeaLevelPressureMb
+	var wx []string
+	for _, w := range o.Weather {
+		wx = append(wx, w.Raw)
+	}
+	m.WxString = strings.Join(wx, " ")
+	for _, c := range o.Clouds {
+		m.SkyCondition = append(m.SkyCondition, SkyCondition{SkyCover: c.SkyCover, CloudBaseFtAGL: c.CloudBaseFtAGL})
+	}
+	if o.Cavok {
+		m.SkyCondition = append(m.SkyCondition, SkyCondition{SkyCover: "CAVOK"})
+	}
+	return m
+}
+
+// decodeDayTime resolves a ddhhmmZ group against a reference time,
+// stepping back a month when the day lies in the future
+func decodeDayTime(tok string, ref time.Time) (time.Time, error) {
+	m := reMetarTime.FindStringSubmatch(tok)
+	if m == nil {
+		return time.Time{}, fmt.Errorf("invalid observation time %q", tok)
+	}
+	return resolveDay(ref, int(atoi32(m[1])), int(atoi32(m[2])), int(atoi32(m[3]))), nil
+}
+
+// resolveDay builds a UTC time from day, hour and minute, choosing the month
+// (this, previous or next relative to ref) that puts the result closest to ref
+func resolveDay(ref time.Time, day, hour, minute int) time.Time {
+	ref = ref.UTC()
+	best := time.Time{}
+	for _, dm := range []int{0, -1, 1} {
+		first := time.Date(ref.Year(), ref.Month()+time.Month(dm), 1, 0, 0, 0, 0, time.UTC)
+		t := first.AddDate(0, 0, day-1).Add(time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute)
+		if t.Month() != first.Month() && !(hour == 24 && t.Day() == 1) {
+			continue
+		}
+		if best.IsZero() || absDuration(t.Sub(ref)) < absDuration(best.Sub(ref)) {
+			best = t
+		}
+	}
+	return best
+}
+
+func decodeWind(tok string) Wind {
+	var w Wind
+	m := reWind.FindStringSubmatch(tok)
+	if m[1] == "///" || m[2] == "//" {
+		w.Missing = true
+		return w
+	}
+	if m[1] == "VRB" {
+		w.Variable = true
+	} else {
+		w.DirDegrees = atoi32(m[1])
+	}
+	w.SpeedKt = windToKnots(atoi32(m[2]), m[4])
+	if m[3] != "" {
+		w.GustKt = windToKnots(atoi32(m[3]), m[4])
+	}
+	return w
+}
+
+func windToKnots(v int32, unit string) int32 {
+	switch unit {
+	case "MPS":
+		return int32(math.Round(float64(v) * 1.943844))
+	case "KMH":
+		return int32(math.Round(float64(v) * 0.539957))
+	}
+	return v
+}
+
+func decodeVisibilitySM(tok string) (Visibility, bool) {
+	var v Visibility
+	m := reVisSM.FindStringSubmatch(tok)
+	if m == nil {
+		return v, false
+	}
+	v.LessThan = m[1] == "M"
+	v.GreaterThan = m[1] == "P"
+	if parts := strings.Split(m[2], "/"); len(parts) == 2 {
+		num, den := atoi32(parts[0]), atoi32(parts[1])
+		if den == 0 {
+			return v, false
+		}
+		v.StatuteMi = float64(num) / float64(den)
+	} else {
+		v.StatuteMi = float64(atoi32(m[2]))
+	}
+	return v, true
+}
+
+func decodeRVR(tok string) RunwayVisualRange {
+	m := reRVR.FindStringSubmatch(tok)
+	r := RunwayVisualRange{
+		Runway:      m[1],
+		ValuePrefix: m[2],
+		Value:       atoi32(m[3]),
+		MaxPrefix:   m[4],
+		Trend:       m[7],
+		UnitsFeet:   m[6] == "FT",
+		VariableRVR: m[5] != "",
+	}
+	if r.VariableRVR {
+		r.MaxValue = atoi32(m[5])
+	}
+	return r
+}
+
+func isWeather(tok string) bool {
+	m := reWeather.FindStringSubmatch(tok)
+	if m == nil {
+		return false
+	}
+	// a bare intensity or an empty token is not weather
+	return m[2] != "" || m[3] != ""
+}
+
+func decodeWeather(tok string) Weather {
+	m := reWeather.FindStringSubmatch(tok)
+	w := Weather{Raw: tok, Intensity: m[1], Descriptor: m[2]}
+	for p := m[3]; len(p) >= 2; p = p[2:] {
+		w.Phenomena = append(w.Phenomena, p[:2])
+	}
+	return w
+}
+
+func signedTemp(s string) *float64 {
+	if s == "" {
+		return nil
+	}
+	neg := strings.HasPrefix(s, "M")
+	v := float64(atoi32(strings.TrimPrefix(s, "M")))
+	if neg {
+		v = -v
+	}
+	return &v
+}
+
+func atoi32(s string) int32 {
+	v, _ := strconv.Atoi(s)
+	return int32(v)
+}
+
+func round(v float64, places int) float64 {
+	p := math.Pow(10, float64(places))
+	return math.Round(v*p) / p
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
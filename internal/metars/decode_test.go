@@ -0,0 +1,164 @@
+package metars
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+var testRef = time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+func TestDecodeWind(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want Wind
+	}{
+		{"KDEN 181153Z 27015KT 10SM CLR 12/M01 A3012", Wind{DirDegrees: 270, SpeedKt: 15}},
+		{"KDEN 181153Z 31022G35KT 10SM CLR 12/M01 A3012", Wind{DirDegrees: 310, SpeedKt: 22, GustKt: 35}},
+		{"KDEN 181153Z VRB03KT 10SM CLR 12/M01 A3012", Wind{Variable: true, SpeedKt: 3}},
+		{"KDEN 181153Z 24008KT 200V280 10SM CLR 12/M01 A3012", Wind{DirDegrees: 240, SpeedKt: 8, VarFromDegrees: 200, VarToDegrees: 280}},
+		{"KDEN 181153Z 00000KT 10SM CLR 12/M01 A3012", Wind{}},
+		{"KDEN 181153Z 090105KT 10SM CLR 12/M01 A3012", Wind{DirDegrees: 90, SpeedKt: 105}},
+		{"UUEE 181200Z 18005MPS 9999 SCT020 08/05 Q1012", Wind{DirDegrees: 180, SpeedKt: 10}},
+		{"KDEN 181153Z /////KT 10SM CLR 12/M01 A3012", Wind{Missing: true}},
+	}
+	for _, tt := range tests {
+		o, err := Decode(tt.raw, testRef)
+		if err != nil {
+			t.Errorf("%s: %v", tt.raw, err)
+			continue
+		}
+		if o.Wind != tt.want {
+			t.Errorf("%s: wind %+v, want %+v", tt.raw, o.Wind, tt.want)
+		}
+	}
+}
+
+func TestDecodeVisibility(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want Visibility
+	}{
+		{"KDEN 181153Z 27015KT 10SM CLR 12/M01 A3012", Visibility{StatuteMi: 10}},
+		{"KDEN 181153Z 27015KT 1 1/2SM BR OVC004 12/11 A3012", Visibility{StatuteMi: 1.5}},
+		{"KDEN 181153Z 27015KT 3/4SM FG VV002 12/12 A3012", Visibility{StatuteMi: 0.75}},
+		{"KDEN 181153Z 27015KT M1/4SM FG VV001 12/12 A3012", Visibility{StatuteMi: 0.25, LessThan: true}},
+		{"KDEN 181153Z 27015KT P6SM SKC 12/M01 A3012", Visibility{StatuteMi: 6, GreaterThan: true}},
+		{"EGLL 181150Z 24010KT 9999 FEW030 14/09 Q1015", Visibility{StatuteMi: 6.21, Meters: 9999, GreaterThan: true}},
+		{"EGLL 181150Z 24010KT 0800 FG OVC002 09/09 Q1015", Visibility{StatuteMi: 0.5, Meters: 800}},
+		{"EGLL 181150Z 24010KT CAVOK 14/09 Q1015", Visibility{StatuteMi: 6, Meters: 9999, GreaterThan: true}},
+		{"KDEN 181153Z AUTO 27015KT ////SM CLR 12/M01 A3012", Visibility{Missing: true}},
+	}
+	for _, tt := range tests {
+		o, err := Decode(tt.raw, testRef)
+		if err != nil {
+			t.Errorf("%s: %v", tt.raw, err)
+			continue
+		}
+		if o.Visibility != tt.want {
+			t.Errorf("%s: visibility %+v, want %+v", tt.raw, o.Visibility, tt.want)
+		}
+	}
+}
+
+func TestDecodeRunwayVisualRange(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want []RunwayVisualRange
+	}{
+		{
+			"KSEA 181153Z 18004KT 1/4SM R16L/1200FT FG VV002 10/10 A3001",
+			[]RunwayVisualRange{{Runway: "16L", Value: 1200, UnitsFeet: true}},
+		},
+		{
+			"KSEA 181153Z 18004KT 1/4SM R34R/0600V1000FT FG VV002 10/10 A3001",
+			[]RunwayVisualRange{{Runway: "34R", Value: 600, MaxValue: 1000, UnitsFeet: true, VariableRVR: true}},
+		},
+		{
+			"KSEA 181153Z 18004KT 1/8SM R16C/M0600FT R16R/P6000FT FG VV001 10/10 A3001",
+			[]RunwayVisualRange{
+				{Runway: "16C", Value: 600, ValuePrefix: "M", UnitsFeet: true},
+				{Runway: "16R", Value: 6000, ValuePrefix: "P", UnitsFeet: true},
+			},
+		},
+		{
+			"EGLL 181150Z 24004KT 0300 R27L/0550U FG VV001 09/09 Q1015",
+			[]RunwayVisualRange{{Runway: "27L", Value: 550, Trend: "U"}},
+		},
+	}
+	for _, tt := range tests {
+		o, err := Decode(tt.raw, testRef)
+		if err != nil {
+			t.Errorf("%s: %v", tt.raw, err)
+			continue
+		}
+		if !reflect.DeepEqual(o.RunwayVisualRange, tt.want) {
+			t.Errorf("%s: RVR %+v, want %+v", tt.raw, o.RunwayVisualRange, tt.want)
+		}
+	}
+}
+
+func TestDecodeClouds(t *testing.T) {
+	tests := []struct {
+		raw       string
+		want      []Cloud
+		vertVisFt int32
+	}{
+		{"KDEN 181153Z 27015KT 10SM CLR 12/M01 A3012", []Cloud{{SkyCover: "CLR"}}, 0},
+		{
+			"KDEN 181153Z 27015KT 10SM FEW045 SCT080 BKN120 OVC250 12/M01 A3012",
+			[]Cloud{
+				{SkyCover: "FEW", CloudBaseFtAGL: 4500},
+				{SkyCover: "SCT", CloudBaseFtAGL: 8000},
+				{SkyCover: "BKN", CloudBaseFtAGL: 12000},
+				{SkyCover: "OVC", CloudBaseFtAGL: 25000},
+			},
+			0,
+		},
+		{
+			"KMIA 181153Z 09012KT 5SM TSRA BKN025CB OVC080 25/23 A2995",
+			[]Cloud{{SkyCover: "BKN", CloudBaseFtAGL: 2500, CloudType: "CB"}, {SkyCover: "OVC", CloudBaseFtAGL: 8000}},
+			0,
+		},
+		{
+			"EGLL 181150Z AUTO 24010KT 9999 BKN///TCU 14/09 Q1015",
+			[]Cloud{{SkyCover: "BKN", CloudType: "TCU", BaseMissing: true}},
+			0,
+		},
+		{"KSEA 181153Z 18004KT 1/4SM FG VV003 10/10 A3001", nil, 300},
+	}
+	for _, tt := range tests {
+		o, err := Decode(tt.raw, testRef)
+		if err != nil {
+			t.Errorf("%s: %v", tt.raw, err)
+			continue
+		}
+		if !reflect.DeepEqual(o.Clouds, tt.want) {
+			t.Errorf("%s: clouds %+v, want %+v", tt.raw, o.Clouds, tt.want)
+		}
+		if o.VertVisFt != tt.vertVisFt {
+			t.Errorf("%s: vertical visibility %d, want %d", tt.raw, o.VertVisFt, tt.vertVisFt)
+		}
+	}
+}
+
+func TestDecodeTimeAndTemperature(t *testing.T) {
+	ref := time.Date(2026, 10, 1, 0, 30, 0, 0, time.UTC)
+	o, err := Decode("SPECI KDEN 302353Z AUTO 27015KT 10SM CLR M05/M12 A3012 RMK AO2", ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// received just after midnight on the 1st, the 30th is last month's
+	if want := time.Date(2026, 9, 30, 23, 53, 0, 0, time.UTC); !o.ObservationTime.Equal(want) {
+		t.Errorf("observation time %v, want %v", o.ObservationTime, want)
+	}
+	if o.MetarType != "SPECI" || o.StationId != "KDEN" || !o.Auto {
+		t.Errorf("type %q station %q auto %v", o.MetarType, o.StationId, o.Auto)
+	}
+	if o.TempC == nil || *o.TempC != -5 || o.DewpointC == nil || *o.DewpointC != -12 {
+		t.Errorf("temperature %v dewpoint %v", o.TempC, o.DewpointC)
+	}
+	if o.AltimInHg != 30.12 {
+		t.Errorf("altimeter %v, want 30.12", o.AltimInHg)
+	}
+}
@@ -0,0 +1,116 @@
+package metars
+
+import (
+	"go-charts/internal/airports"
+	"math"
+	"strings"
+)
+
+// Derived are performance values computed from a METAR. A value is nil
+// when the report lacks the elements it needs.
+type Derived struct {
+	PressureAltitudeFt  *float64     `json:",omitempty"`
+	DensityAltitudeFt   *float64     `json:",omitempty"`
+	RelativeHumidityPct *float64     `json:",omitempty"`
+	TempDewpointSpreadC *float64     `json:",omitempty"`
+	EstCloudBaseFtAGL   *float64     `json:",omitempty"`
+	RunwayWinds         []RunwayWind `json:",omitempty"`
+}
+
+// RunwayWind is the wind component along and across one runway. A
+// positive crosswind is from the right, a negative headwind a tailwind.
+type RunwayWind struct {
+	Runway          string
+	HeadingTrue     float64
+	HeadwindKt      float64
+	CrosswindKt     float64
+	GustHeadwindKt  float64 `json:",omitempty"`
+	GustCrosswindKt float64 `json:",omitempty"`
+}
+
+const (
+	feetPerMeter = 3.28084
+	inHgToMb     = 33.8639
+)
+
+// Derive computes the performance values for a METAR and the runways at
+// its station
+func Derive(m *Metar, runways []airports.Runway) *Derived {
+	d := &Derived{}
+	hasTemp, hasDewpoint := m.hasTemperatures()
+
+	var stationMb float64
+	if m.AltimInHg > 0 {
+		// station pressure from the altimeter setting and field elevation
+		stationMb = m.AltimInHg * inHgToMb * math.Pow((288-0.0065*m.ElevationM)/288, 5.2561)
+		pa := round(145366.45*(1-math.Pow(stationMb/1013.25, 0.190284)), 0)
+		d.PressureAltitudeFt = &pa
+	}
+	if hasTemp && stationMb > 0 {
+		// density altitude from the virtual temperature, which accounts for humidity
+		tk := m.TempC + 273.15
+		if hasDewpoint {
+			tk = tk / (1 - vaporPressureMb(m.DewpointC)/stationMb*(1-0.622))
+		}
+		density := stationMb * 100 / (287.05 * tk)
+		da := round(145442.16*(1-math.Pow(density/1.225, 0.234969)), 0)
+		d.DensityAltitudeFt = &da
+	}
+	if hasTemp && hasDewpoint {
+		rh := round(100*vaporPressureMb(m.DewpointC)/vaporPressureMb(m.TempC), 1)
+		spread := round(m.TempC-m.DewpointC, 1)
+		// a lifted parcel closes the spread by about 2.5 °C per 1000 feet
+		base := round(math.Max(spread, 0)/2.5*1000, -2)
+		d.RelativeHumidityPct = &rh
+		d.TempDewpointSpreadC = &spread
+		d.EstCloudBaseFtAGL = &base
+	}
+	// a variable wind has no direction to resolve
+	if m.WindDirDegrees > 0 || m.WindSpeedKt == 0 {
+		for _, rwy := range runways {
+			rw := RunwayWind{Runway: rwy.Ident, HeadingTrue: rwy.HeadingTrue}
+			rw.HeadwindKt, rw.CrosswindKt = windComponents(float64(m.WindDirDegrees), float64(m.WindSpeedKt), rwy.HeadingTrue)
+			if m.WindGustKt > 0 {
+				rw.GustHeadwindKt, rw.GustCrosswindKt = windComponents(float64(m.WindDirDegrees), float64(m.WindGustKt), rwy.HeadingTrue)
+			}
+			d.RunwayWinds = append(d.RunwayWinds, rw)
+		}
+	}
+	return d
+}
+
+// FillDerived computes the derived values of every METAR. runways may be nil.
+func (r *Response) FillDerived(runways *airports.RunwayIndex) {
+	for i := range r.Data.Metars {
+		m := &r.Data.Metars[i]
+		m.Derived = Derive(m, runways.Runways(m.StationId))
+	}
+}
+
+// hasTemperatures reports whether the temperature and dewpoint were
+// reported, since zero is a valid value for both
+func (m *Metar) hasTemperatures() (temp, dewpoint bool) {
+	if m.RawText == "" {
+		return true, true
+	}
+	for _, tok := range strings.Fields(m.RawText) {
+		if tok == "RMK" {
+			break
+		}
+		if g := reTemp.FindStringSubmatch(tok); g != nil {
+			return true, g[2] != ""
+		}
+	}
+	return false, false
+}
+
+// vaporPressureMb is the saturation vapor pressure over water (Magnus)
+func vaporPressureMb(tempC float64) float64 {
+	return 6.1094 * math.Exp(17.625*tempC/(tempC+243.04))
+}
+
+// windComponents splits a wind into head and crosswind for a runway heading
+func windComponents(dir, speed, heading float64) (head, cross float64) {
+	angle := (dir - heading) * math.Pi / 180
+	return round(speed*math.Cos(angle), 1), round(speed*math.Sin(angle), 1)
+}
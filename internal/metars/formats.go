@@ -0,0 +1,244 @@
+package metars
+
+import (
+	"encoding/json"
+	"go-charts/internal/geojson"
+	"go-charts/internal/weather"
+	"strings"
+	"time"
+)
+
+// apiMetar is a METAR as returned by the aviationweather.gov Data API in JSON format
+type apiMetar struct {
+	IcaoId      string         `json:"icaoId"`
+	ReceiptTime weather.Time   `json:"receiptTime"`
+	ObsTime     weather.Time   `json:"obsTime"`
+	Temp        weather.Number `json:"temp"`
+	Dewp        weather.Number `json:"dewp"`
+	Wdir        weather.Number `json:"wdir"`
+	Wspd        weather.Number `json:"wspd"`
+	Wgst        weather.Number `json:"wgst"`
+	Visib       weather.Number `json:"visib"`
+	Altim       weather.Number `json:"altim"`
+	Slp         weather.Number `json:"slp"`
+	WxString    string         `json:"wxString"`
+	PresTend    weather.Number `json:"presTend"`
+	MaxT        weather.Number `json:"maxT"`
+	MinT        weather.Number `json:"minT"`
+	MaxT24      weather.Number `json:"maxT24"`
+	MinT24      weather.Number `json:"minT24"`
+	Precip      weather.Number `json:"precip"`
+	Pcp3hr      weather.Number `json:"pcp3hr"`
+	Pcp6hr      weather.Number `json:"pcp6hr"`
+	Pcp24hr     weather.Number `json:"pcp24hr"`
+	Snow        weather.Number `json:"snow"`
+	VertVis     weather.Number `json:"vertVis"`
+	MetarType   string         `json:"metarType"`
+	RawOb       string         `json:"rawOb"`
+	Lat         float64        `json:"lat"`
+	Lon         float64        `json:"lon"`
+	Elev        weather.Number `json:"elev"`
+	FltCat      string         `json:"fltCat"`
+	Clouds      []struct {
+		Cover string         `json:"cover"`
+		Base  weather.Number `json:"base"`
+	} `json:"clouds"`
+}
+
+// apiMetarProperties are the properties of a Data API GeoJSON METAR feature
+type apiMetarProperties struct {
+	Id      string         `json:"id"`
+	ObsTime weather.Time   `json:"obsTime"`
+	Temp    weather.Number `json:"temp"`
+	Dewp    weather.Number `json:"dewp"`
+	Wspd    weather.Number `json:"wspd"`
+	Wdir    weather.Number `json:"wdir"`
+	Wgst    weather.Number `json:"wgst"`
+	Visib   weather.Number `json:"visib"`
+	Altim   weather.Number `json:"altim"`
+	Slp     weather.Number `json:"slp"`
+	Fltcat  string         `json:"fltcat"`
+	RawOb   string         `json:"rawOb"`
+}
+
+// hPa to inches of mercury; the Data API reports the altimeter in hPa
+const hPaToInHg = 0.0295300
+
+// decodeJSON maps a Data API JSON response onto a Response
+func decodeJSON(data []byte) (*Response, error) {
+	var list []apiMetar
+	err := weather.DecodeJSONArray(data, &list)
+	if err != nil {
+		return nil, err
+	}
+	r := &Response{}
+	for _, a := range list {
+		m := Metar{
+			RawText:                   a.RawOb,
+			StationId:                 a.IcaoId,
+			ObservationTime:           a.ObsTime.Time,
+			Latitude:                  a.Lat,
+			Longitude:                 a.Lon,
+			TempC:                     float64(a.Temp),
+			DewpointC:                 float64(a.Dewp),
+			WindDirDegrees:            int32(a.Wdir),
+			WindSpeedKt:               int32(a.Wspd),
+			WindGustKt:                int32(a.Wgst),
+			VisibilityStatuteMi:       float64(a.Visib),
+			AltimInHg:                 round(float64(a.Altim)*hPaToInHg, 2),
+			SeaLevelPressureMb:        float64(a.Slp),
+			WxString:                  a.WxString,
+			FlightCategory:            a.FltCat,
+			ThreeHrPressureTendencyMb: float64(a.PresTend),
+			MaxTC:                     float64(a.MaxT),
+			MinTC:                     float64(a.MinT),
+			MaxT24hrC:                 float64(a.MaxT24),
+			MinT24hrC:                 float64(a.MinT24),
+			PrecipIn:                  float64(a.Precip),
+			Pcp3hrIn:                  float64(a.Pcp3hr),
+			Pcp6hrIn:                  float64(a.Pcp6hr),
+			Pcp24hrIn:                 float64(a.Pcp24hr),
+			SnowIn:                    float64(a.Snow),
+			VertVisFt:                 int32(a.VertVis),
+			MetarType:                 a.MetarType,
+			ElevationM:                float64(a.Elev),
+		}
+		m.QualityControlFlags.AutoStation = isAuto(a.RawOb)
+		for _, c := range a.Clouds {
+			m.SkyCondition = append(m.SkyCondition, SkyCondition{SkyCover: c.Cover, CloudBaseFtAGL: int32(c.Base)})
+		}
+		r.Data.Metars = append(r.Data.Metars, m)
+	}
+	r.Data.NumResults = int32(len(r.Data.Metars))
+	return r, nil
+}
+
+// decodeGeoJSON maps a Data API GeoJSON response onto a Response. The
+// features carry only summary properties, so the rest of each report
+// is decoded from its raw text.
+func decodeGeoJSON(data []byte) (*Response, error) {
+	fc, err := geojson.Decode(data)
+	if err != nil {
+		return nil, err
+	}
+	r := &Response{}
+	for _, f := range fc.Features {
+		var p apiMetarProperties
+		err = json.Unmarshal(f.Properties, &p)
+		if err != nil {
+			return nil, err
+		}
+		var m Metar
+		if o, err := Decode(p.RawOb, observationRef(p.ObsTime.Time)); err == nil {
+			m = o.ToMetar()
+		} else {
+			m = Metar{
+				RawText:             p.RawOb,
+				StationId:           p.Id,
+				TempC:               float64(p.Temp),
+				DewpointC:           float64(p.Dewp),
+				WindDirDegrees:      int32(p.Wdir),
+				WindSpeedKt:         int32(p.Wspd),
+				WindGustKt:          int32(p.Wgst),
+				VisibilityStatuteMi: float64(p.Visib),
+				AltimInHg:           round(float64(p.Altim)*hPaToInHg, 2),
+			}
+		}
+		if !p.ObsTime.IsZero() {
+			m.ObservationTime = p.ObsTime.Time
+		}
+		if p.Slp != 0 {
+			m.SeaLevelPressureMb = float64(p.Slp)
+		}
+		m.Longitude, m.Latitude, _ = f.Geometry.Point()
+		m.FlightCategory = p.Fltcat
+		r.Data.Metars = append(r.Data.Metars, m)
+	}
+	r.Data.NumResults = int32(len(r.Data.Metars))
+	return r, nil
+}
+
+// decodeCSV maps the metars.cache.csv file onto a Response
+func decodeCSV(data []byte) (*Response, error) {
+	records, err := weather.ReadCSV(data, "raw_text")
+	if err != nil {
+		return nil, err
+	}
+	r := &Response{}
+	for _, rec := range records {
+		m := Metar{
+			RawText:                   rec.Get("raw_text"),
+			StationId:                 rec.Get("station_id"),
+			ObservationTime:           rec.Time("observation_time"),
+			Latitude:                  rec.Float("latitude"),
+			Longitude:                 rec.Float("longitude"),
+			TempC:                     rec.Float("temp_c"),
+			DewpointC:                 rec.Float("dewpoint_c"),
+			WindDirDegrees:            int32(rec.Float("wind_dir_degrees")),
+			WindSpeedKt:               int32(rec.Float("wind_speed_kt")),
+			WindGustKt:                int32(rec.Float("wind_gust_kt")),
+			VisibilityStatuteMi:       rec.Float("visibility_statute_mi"),
+			AltimInHg:                 round(rec.Float("altim_in_hg"), 2),
+			SeaLevelPressureMb:        rec.Float("sea_level_pressure_mb"),
+			WxString:                  rec.Get("wx_string"),
+			FlightCategory:            rec.Get("flight_category"),
+			ThreeHrPressureTendencyMb: rec.Float("three_hr_pressure_tendency_mb"),
+			MaxTC:                     rec.Float("maxT_c"),
+			MinTC:                     rec.Float("minT_c"),
+			MaxT24hrC:                 rec.Float("maxT24hr_c"),
+			MinT24hrC:                 rec.Float("minT24hr_c"),
+			PrecipIn:                  rec.Float("precip_in"),
+			Pcp3hrIn:                  rec.Float("pcp3hr_in"),
+			Pcp6hrIn:                  rec.Float("pcp6hr_in"),
+			Pcp24hrIn:                 rec.Float("pcp24hr_in"),
+			SnowIn:                    rec.Float("snow_in"),
+			VertVisFt:                 int32(rec.Float("vert_vis_ft")),
+			MetarType:                 rec.Get("metar_type"),
+			ElevationM:                rec.Float("elevation_m"),
+		}
+		m.QualityControlFlags.AutoStation = strings.EqualFold(rec.Get("auto_station"), "TRUE")
+		bases := rec.All("cloud_base_ft_agl")
+		for i, cover := range rec.All("sky_cover") {
+			if cover == "" {
+				continue
+			}
+			sc := SkyCondition{SkyCover: cover}
+			if i < len(bases) {
+				sc.CloudBaseFtAGL = int32(weather.ParseNumber(bases[i]))
+			}
+			m.SkyCondition = append(m.SkyCondition, sc)
+		}
+		r.Data.Metars = append(r.Data.Metars, m)
+	}
+	r.Data.NumResults = int32(len(r.Data.Metars))
+	return r, nil
+}
+
+func isAuto(raw string) bool {
+	return strings.Contains(" "+raw+" ", " AUTO ")
+}
+
+// observationRef is the reference time used when only raw text is available
+func observationRef(t time.Time) time.Time {
+	if t.IsZero() {
+		return time.Now().UTC()
+	}
+	return t
+}
+
+// ToGeoJson encodes the METARs as a GeoJSON FeatureCollection of points
+// keyed by station and observation time, as Records keys them, since a
+// station can report more than once in a download. The decoded fields are
+// the properties.
+func (r *Response) ToGeoJson() (string, error) {
+	fc := geojson.NewFeatureCollection()
+	for _, m := range r.Data.Metars {
+		id := m.StationId + " " + m.ObservationTime.Format(time.RFC3339Nano)
+		f, err := geojson.NewFeature(id, geojson.NewPoint(m.Longitude, m.Latitude), m)
+		if err != nil {
+			return "", err
+		}
+		fc.Features = append(fc.Features, f)
+	}
+	return fc.Encode()
+}
@@ -0,0 +1,100 @@
+package metars
+
+import (
+	"fmt"
+	"go-charts/internal/weather"
+	"testing"
+	"time"
+)
+
+func TestSourceDecodeFormats(t *testing.T) {
+	type summary struct {
+		station  string
+		observed time.Time
+		lat, lon float64
+		tempC    float64
+		windKt   int32
+		visSM    float64
+		altim    float64
+		sky      string
+		category string
+	}
+	want := summary{
+		station:  "KDEN",
+		observed: time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC),
+		lat:      39.85,
+		lon:      -104.66,
+		tempC:    12,
+		windKt:   15,
+		visSM:    10,
+		altim:    30.11,
+		sky:      "BKN 2500",
+		category: "MVFR",
+	}
+	const raw = "KDEN 181200Z 27015KT 10SM BKN025 12/M01 A3011"
+	jsonReport := `{"icaoId":"KDEN","obsTime":1792324800,"temp":12,"dewp":-1,"wdir":270,"wspd":15,"visib":"10+",` +
+		`"altim":1019.6,"rawOb":"` + raw + `","lat":39.85,"lon":-104.66,"clouds":[{"cover":"BKN","base":2500}]}`
+	tests := []struct {
+		name   string
+		format string
+		data   string
+	}{
+		{"json array", weather.FormatJSON, "[" + jsonReport + "]"},
+		{"json wrapped", weather.FormatJSON, `{"data":[` + jsonReport + `]}`},
+		{"geojson", weather.FormatGeoJSON, `{"type":"FeatureCollection","features":[{"type":"Feature",` +
+			`"geometry":{"type":"Point","coordinates":[-104.66,39.85]},` +
+			`"properties":{"id":"KDEN","obsTime":"2026-10-18T12:00:00Z","rawOb":"` + raw + `"}}]}`},
+		{"csv", weather.FormatCSV, "No errors\nNo warnings\n5 ms\ndata source=metars\n1 results\n" +
+			"raw_text,station_id,observation_time,latitude,longitude,temp_c,dewpoint_c,wind_dir_degrees,wind_speed_kt," +
+			"visibility_statute_mi,altim_in_hg,sky_cover,cloud_base_ft_agl,sky_cover,cloud_base_ft_agl\n" +
+			raw + ",KDEN,2026-10-18T12:00:00Z,39.85,-104.66,12,-1,270,15,10.0,30.109,BKN,2500,,\n"},
+	}
+	for _, tt := range tests {
+		p, err := NewSource("", tt.format).Decode([]byte(tt.data))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		r := p.(*Response)
+		if len(r.Data.Metars) != 1 || r.Data.NumResults != 1 {
+			t.Errorf("%s: %d reports, NumResults %d, want 1", tt.name, len(r.Data.Metars), r.Data.NumResults)
+			continue
+		}
+		m := r.Data.Metars[0]
+		got := summary{
+			station:  m.StationId,
+			observed: m.ObservationTime,
+			lat:      m.Latitude,
+			lon:      m.Longitude,
+			tempC:    m.TempC,
+			windKt:   m.WindSpeedKt,
+			visSM:    m.VisibilityStatuteMi,
+			altim:    m.AltimInHg,
+			category: m.FlightCategory,
+		}
+		for _, sc := range m.SkyCondition {
+			got.sky += fmt.Sprintf("%s %d", sc.SkyCover, sc.CloudBaseFtAGL)
+		}
+		if got != want {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, want)
+		}
+	}
+}
+
+func TestSourceDecodeErrors(t *testing.T) {
+	tests := []struct {
+		format string
+		data   string
+	}{
+		{weather.FormatJSON, `{"data":`},
+		{weather.FormatGeoJSON, `[]`},
+		{weather.FormatCSV, "station_id,latitude\nKDEN,39.85\n"},
+		{"kml", `<kml/>`},
+	}
+	for _, tt := range tests {
+		_, err := NewSource("", tt.format).Decode([]byte(tt.data))
+		if err == nil {
+			t.Errorf("%s %q: no error", tt.format, tt.data)
+		}
+	}
+}
@@ -3,12 +3,8 @@ package metars
 import (
 	"encoding/json"
 	"encoding/xml"
-	"fmt"
-	"io/ioutil"
-	"log"
-	"net"
-	"net/http"
-	"os"
+	"go-charts/internal/stations"
+	"go-charts/internal/weather"
 	"time"
 )
 
@@ -83,57 +79,13 @@ type Metar struct {
 	VertVisFt                 int32               `xml:"vert_vis_ft"`
 	MetarType                 string              `xml:"metar_type"`
 	ElevationM                float64             `xml:"elevation_m"`
+	Derived                   *Derived            `xml:"-" json:",omitempty"`
+	Station                   *stations.Info      `xml:"-" json:",omitempty"`
 }
 
-// SaveAsJSONFile downloads xml file from ADDS weather server and converts to a JSON string
-func (r *Response) SaveAsJSONFile(url string) (err error) {
-	
-	t := &http.Transport{
-		Dial: (&net.Dialer{
-				Timeout: 60 * time.Second,
-				KeepAlive: 30 * time.Second,
-		}).Dial,
-		TLSHandshakeTimeout: 60 * time.Second,
-	}
-	c := &http.Client{
-		Transport: t,
-	}
-	resp, err := c.Get(url)
-	if err != nil {
-		return err
-	} else if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("Error status code received: %v", resp.StatusCode)
-	}
-
-	defer resp.Body.Close()
-	data, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return err
-	}
-	
-	err = xml.Unmarshal(data, &r)
-	if err != nil {
-		return err
-	}
-	
-	var s string
-	s, err = r.ToJson()
-	if err != nil {
-		return err
-	}
-	
-	jsonfile := "./workfiles/metars.json"
-	err = os.Remove(jsonfile)
-	if err != nil {
-		log.Println(err)
-	}
-
-	nstr := []byte("{ \"metars\": " + s + "}")
-	err = os.WriteFile(jsonfile, nstr, 0644)
-	if (err != nil) {
-		return err
-	}
-	return nil
+// Count returns the number of reports in the response
+func (r *Response) Count() int {
+	return len(r.Data.Metars)
 }
 
 func (r *Response) ToRawTextOnly() (s []string) {
@@ -152,6 +104,25 @@ func (r *Response) ToJson() (s string, err error) {
 	return
 }
 
+// FromJson replaces the reports with those encoded by ToJson
+func (r *Response) FromJson(data []byte) error {
+	return json.Unmarshal(data, &r.Data.Metars)
+}
+
+// Records returns the reports keyed by station and observation time
+func (r *Response) Records() []weather.Record {
+	records := make([]weather.Record, 0, len(r.Data.Metars))
+	for _, m := range r.Data.Metars {
+		records = append(records, weather.Record{Key: m.StationId + " " + m.ObservationTime.Format(time.RFC3339Nano), Data: m})
+	}
+	return records
+}
+
+// StationReport returns the station and observation time of a report
+func (r *Response) StationReport(i int) (string, time.Time) {
+	return r.Data.Metars[i].StationId, r.Data.Metars[i].ObservationTime
+}
+
 func (r *Response) ToJsonIndented() (s string, err error) {
 	bytes, err := json.MarshalIndent(r.Data.Metars, "", "  ")
 	if err != nil {
@@ -0,0 +1,241 @@
+package metars
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Remarks is the decoded RMK section of a METAR
+type Remarks struct {
+	AutoType           string
+	SeaLevelPressureMb float64
+	SeaLevelPressureNo bool
+	PreciseTempC       *float64
+	PreciseDewpointC   *float64
+	PeakWind           *PeakWind
+	WindShift          *WindShift
+	Lightning          []Lightning
+	Convection         []Convection
+	PrecipEvents       []PrecipEvent
+	Maintenance        bool
+	Other              []string
+}
+
+// PeakWind is the PK WND dddff(f)/(hh)mm group
+type PeakWind struct {
+	DirDegrees int32
+	SpeedKt    int32
+	Time       time.Time
+}
+
+// WindShift is the WSHFT (hh)mm [FROPA] group
+type WindShift struct {
+	Time  time.Time
+	Fropa bool
+}
+
+// Lightning is a LTG remark, e.g. FRQ LTGICCG DSNT NW-N
+type Lightning struct {
+	Frequency  string
+	Types      []string
+	Location   string
+	Directions []string
+}
+
+// Convection is a TS, CB or TCU remark with its location and movement,
+// e.g. TS OHD MOV E or CB DSNT SW MOV NE
+type Convection struct {
+	Phenomenon string
+	Location   string
+	Directions []string
+	Movement   string
+}
+
+// PrecipEvent is one weather type from a begin/end group such as RAB15E30
+type PrecipEvent struct {
+	Weather string
+	Begin   []time.Time
+	End     []time.Time
+}
+
+var (
+	reRmkSLP       = regexp.MustCompile(`^SLP(\d{3})$`)
+	reRmkTemp      = regexp.MustCompile(`^T([01])(\d{3})(?:([01])(\d{3}))?$`)
+	reRmkPeakWind  = regexp.MustCompile(`^(\d{3})(\d{2,3})/(\d{2}|\d{4})$`)
+	reRmkTime      = regexp.MustCompile(`^(\d{2}|\d{4})$`)
+	reRmkLtg       = regexp.MustCompile(`^LTG((?:IC|CG|CC|CA)*)$`)
+	reRmkDirection = regexp.MustCompile(`^(N|NE|E|SE|S|SW|W|NW)(?:-(N|NE|E|SE|S|SW|W|NW))*$`)
+	reRmkPrecip    = regexp.MustCompile(`([A-Z]{2,6}?)((?:[BE](?:\d{4}|\d{2}))+)`)
+	reRmkBeginEnd  = regexp.MustCompile(`([BE])(\d{4}|\d{2})`)
+	rmkLocations   = map[string]bool{"OHD": true, "VC": true, "DSNT": true, "ALQDS": true}
+	rmkConvection  = map[string]bool{"TS": true, "CB": true, "TCU": true, "CBMAM": true, "ACC": true}
+)
+
+// DecodeRemarks parses the text following RMK. Times in the remarks carry
+// only minutes, or hours and minutes, so they are resolved against obsTime.
+func DecodeRemarks(rmk string, obsTime time.Time) Remarks {
+	var r Remarks
+	tokens := strings.Fields(strings.TrimSuffix(strings.TrimSpace(rmk), "="))
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+		switch {
+		case tok == "AO1" || tok == "AO2" || tok == "AO1A" || tok == "AO2A":
+			r.AutoType = tok
+		case tok == "SLPNO":
+			r.SeaLevelPressureNo = true
+		case reRmkSLP.MatchString(tok):
+			v := float64(atoi32(tok[3:])) / 10
+			if v < 50 {
+				r.SeaLevelPressureMb = round(1000+v, 1)
+			} else {
+				r.SeaLevelPressureMb = round(900+v, 1)
+			}
+		case reRmkTemp.MatchString(tok):
+			m := reRmkTemp.FindStringSubmatch(tok)
+			r.PreciseTempC = tenthsTemp(m[1], m[2])
+			if m[3] != "" {
+				r.PreciseDewpointC = tenthsTemp(m[3], m[4])
+			}
+		case tok == "PK" && i+2 < len(tokens) && tokens[i+1] == "WND" && reRmkPeakWind.MatchString(tokens[i+2]):
+			m := reRmkPeakWind.FindStringSubmatch(tokens[i+2])
+			r.PeakWind = &PeakWind{
+				DirDegrees: atoi32(m[1]),
+				SpeedKt:    atoi32(m[2]),
+				Time:       remarkTime(m[3], obsTime),
+			}
+			i += 2
+		case tok == "WSHFT" && i+1 < len(tokens) && reRmkTime.MatchString(tokens[i+1]):
+			r.WindShift = &WindShift{Time: remarkTime(tokens[i+1], obsTime)}
+			i++
+			if i+1 < len(tokens) && tokens[i+1] == "FROPA" {
+				r.WindShift.Fropa = true
+				i++
+			}
+		case (tok == "OCNL" || tok == "FRQ" || tok == "CONS") && i+1 < len(tokens) && reRmkLtg.MatchString(tokens[i+1]):
+			var l Lightning
+			l.Frequency = tok
+			i = decodeLightning(tokens, i+1, &l)
+			r.Lightning = append(r.Lightning, l)
+		case reRmkLtg.MatchString(tok):
+			var l Lightning
+			i = decodeLightning(tokens, i, &l)
+			r.Lightning = append(r.Lightning, l)
+		case rmkConvection[tok]:
+			c := Convection{Phenomenon: tok}
+			i = decodeConvection(tokens, i, &c)
+			r.Convection = append(r.Convection, c)
+		case isPrecipEvents(tok):
+			r.PrecipEvents = append(r.PrecipEvents, decodePrecipEvents(tok, obsTime)...)
+		case tok == "$":
+			r.Maintenance = true
+		default:
+			r.Other = append(r.Other, tok)
+		}
+	}
+	return r
+}
+
+// decodeLightning reads a LTGxx token at tokens[i] and the location and
+// direction tokens that follow it, returning the index of the last token used
+func decodeLightning(tokens []string, i int, l *Lightning) int {
+	m := reRmkLtg.FindStringSubmatch(tokens[i])
+	for t := m[1]; len(t) >= 2; t = t[2:] {
+		l.Types = append(l.Types, t[:2])
+	}
+	i, l.Location, l.Directions = decodeLocation(tokens, i)
+	return i
+}
+
+// decodeConvection reads the location and optional MOV group that follow
+// a TS, CB or TCU token at tokens[i]
+func decodeConvection(tokens []string, i int, c *Convection) int {
+	i, c.Location, c.Directions = decodeLocation(tokens, i)
+	if i+1 < len(tokens) && tokens[i+1] == "MOV" && i+2 < len(tokens) {
+		c.Movement = tokens[i+2]
+		i += 2
+	} else if i+1 < len(tokens) && tokens[i+1] == "STNRY" {
+		c.Movement = "STNRY"
+		i++
+	}
+	return i
+}
+
+// decodeLocation collects OHD/VC/DSNT/ALQDS and compass direction tokens
+// after tokens[i]
+func decodeLocation(tokens []string, i int) (int, string, []string) {
+	var location string
+	var dirs []string
+	for i+1 < len(tokens) {
+		next := tokens[i+1]
+		switch {
+		case rmkLocations[next]:
+			location = next
+		case reRmkDirection.MatchString(next):
+			dirs = append(dirs, next)
+		case next == "AND" && len(dirs) > 0:
+		default:
+			return i, location, dirs
+		}
+		i++
+	}
+	return i, location, dirs
+}
+
+func isPrecipEvents(tok string) bool {
+	n := 0
+	for _, m := range reRmkPrecip.FindAllStringIndex(tok, -1) {
+		if m[0] != n {
+			return false
+		}
+		n = m[1]
+	}
+	return n > 0 && n == len(tok)
+}
+
+func decodePrecipEvents(tok string, obsTime time.Time) []PrecipEvent {
+	var events []PrecipEvent
+	for _, m := range reRmkPrecip.FindAllStringSubmatch(tok, -1) {
+		e := PrecipEvent{Weather: m[1]}
+		for _, be := range reRmkBeginEnd.FindAllStringSubmatch(m[2], -1) {
+			t := remarkTime(be[2], obsTime)
+			if be[1] == "B" {
+				e.Begin = append(e.Begin, t)
+			} else {
+				e.End = append(e.End, t)
+			}
+		}
+		events = append(events, e)
+	}
+	return events
+}
+
+// remarkTime resolves a mm or hhmm remark time to the latest matching
+// time that is not after the observation
+func remarkTime(s string, obsTime time.Time) time.Time {
+	if obsTime.IsZero() {
+		return time.Time{}
+	}
+	obsTime = obsTime.UTC()
+	var t time.Time
+	if len(s) == 4 {
+		t = time.Date(obsTime.Year(), obsTime.Month(), obsTime.Day(), int(atoi32(s[:2])), int(atoi32(s[2:])), 0, 0, time.UTC)
+		if t.After(obsTime) {
+			t = t.AddDate(0, 0, -1)
+		}
+		return t
+	}
+	t = time.Date(obsTime.Year(), obsTime.Month(), obsTime.Day(), obsTime.Hour(), int(atoi32(s)), 0, 0, time.UTC)
+	if t.After(obsTime) {
+		t = t.Add(-time.Hour)
+	}
+	return t
+}
+
+func tenthsTemp(sign, digits string) *float64 {
+	v := float64(atoi32(digits)) / 10
+	if sign == "1" {
+		v = -v
+	}
+	return &v
+}
@@ -0,0 +1,85 @@
+package metars
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDecodeRemarksSeaLevelPressure(t *testing.T) {
+	tests := []struct {
+		rmk  string
+		mb   float64
+		none bool
+	}{
+		{"AO2 SLP132", 1013.2, false},
+		{"AO2 SLP998", 999.8, false},
+		{"AO2 SLP500", 950.0, false},
+		{"AO2 SLP001", 1000.1, false},
+		{"AO2 SLPNO", 0, true},
+	}
+	for _, tt := range tests {
+		r := DecodeRemarks(tt.rmk, testRef)
+		if r.SeaLevelPressureMb != tt.mb || r.SeaLevelPressureNo != tt.none {
+			t.Errorf("%s: SLP %v (no %v), want %v (no %v)", tt.rmk, r.SeaLevelPressureMb, r.SeaLevelPressureNo, tt.mb, tt.none)
+		}
+		if r.AutoType != "AO2" {
+			t.Errorf("%s: auto type %q, want AO2", tt.rmk, r.AutoType)
+		}
+	}
+}
+
+func TestDecodeRemarksTemperature(t *testing.T) {
+	tests := []struct {
+		rmk      string
+		temp     float64
+		dewpoint *float64
+	}{
+		{"AO2 T01560083", 15.6, float64p(8.3)},
+		{"AO2 T10221067", -2.2, float64p(-6.7)},
+		{"AO2 T00061011", 0.6, float64p(-1.1)},
+		{"AO2 T1033", -3.3, nil},
+	}
+	for _, tt := range tests {
+		r := DecodeRemarks(tt.rmk, testRef)
+		if r.PreciseTempC == nil || *r.PreciseTempC != tt.temp {
+			t.Errorf("%s: temperature %v, want %v", tt.rmk, r.PreciseTempC, tt.temp)
+		}
+		switch {
+		case tt.dewpoint == nil && r.PreciseDewpointC != nil:
+			t.Errorf("%s: dewpoint %v, want none", tt.rmk, *r.PreciseDewpointC)
+		case tt.dewpoint != nil && (r.PreciseDewpointC == nil || *r.PreciseDewpointC != *tt.dewpoint):
+			t.Errorf("%s: dewpoint %v, want %v", tt.rmk, r.PreciseDewpointC, *tt.dewpoint)
+		}
+	}
+}
+
+func TestDecodeRemarksPeakWind(t *testing.T) {
+	obs := time.Date(2026, 10, 18, 11, 53, 0, 0, time.UTC)
+	tests := []struct {
+		rmk  string
+		want *PeakWind
+	}{
+		{"AO2 PK WND 28045/1130 SLP132", &PeakWind{DirDegrees: 280, SpeedKt: 45, Time: time.Date(2026, 10, 18, 11, 30, 0, 0, time.UTC)}},
+		{"AO2 PK WND 31032/47", &PeakWind{DirDegrees: 310, SpeedKt: 32, Time: time.Date(2026, 10, 18, 11, 47, 0, 0, time.UTC)}},
+		// a minute after the observation minute belongs to the previous hour
+		{"AO2 PK WND 090105/58", &PeakWind{DirDegrees: 90, SpeedKt: 105, Time: time.Date(2026, 10, 18, 10, 58, 0, 0, time.UTC)}},
+		// an hour and minute after the observation belong to the previous day
+		{"AO2 PK WND 18030/2350", &PeakWind{DirDegrees: 180, SpeedKt: 30, Time: time.Date(2026, 10, 17, 23, 50, 0, 0, time.UTC)}},
+		{"AO2 PK WND", nil},
+	}
+	for _, tt := range tests {
+		r := DecodeRemarks(tt.rmk, obs)
+		switch {
+		case tt.want == nil && r.PeakWind != nil:
+			t.Errorf("%s: peak wind %+v, want none", tt.rmk, *r.PeakWind)
+		case tt.want != nil && r.PeakWind == nil:
+			t.Errorf("%s: no peak wind, want %+v", tt.rmk, *tt.want)
+		case tt.want != nil && (r.PeakWind.DirDegrees != tt.want.DirDegrees || r.PeakWind.SpeedKt != tt.want.SpeedKt || !r.PeakWind.Time.Equal(tt.want.Time)):
+			t.Errorf("%s: peak wind %+v, want %+v", tt.rmk, *r.PeakWind, *tt.want)
+		}
+	}
+}
+
+func float64p(v float64) *float64 {
+	return &v
+}
@@ -0,0 +1,13 @@
+package metars
+
+import "go-charts/internal/stations"
+
+// FillStations joins each report to its station's name and site types.
+// table may be nil, and reports from unknown stations are left without one.
+func (r *Response) FillStations(table *stations.Table) {
+	for i := range r.Data.Metars {
+		if s, ok := table.Lookup(r.Data.Metars[i].StationId); ok {
+			r.Data.Metars[i].Station = s.Info()
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Registry holds metrics and writes them in the Prometheus text
+// exposition format
+type Registry struct {
+	mu      sync.Mutex
+	metrics []metric
+}
+
+type metric interface {
+	name() string
+	write(w io.Writer)
+}
+
+// NewRegistry returns an empty Registry
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (r *Registry) register(m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.metrics = append(r.metrics, m)
+}
+
+// Write writes every metric, ordered by name
+func (r *Registry) Write(w io.Writer) {
+	r.mu.Lock()
+	list := append([]metric(nil), r.metrics...)
+	r.mu.Unlock()
+	sort.Slice(list, func(i, j int) bool { return list[i].name() < list[j].name() })
+	for _, m := range list {
+		m.write(w)
+	}
+}
+
+// header writes the HELP and TYPE lines of a metric
+func header(w io.Writer, name, help, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, strings.NewReplacer("\\", `\\`, "\n", `\n`).Replace(help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, kind)
+}
+
+// labelString formats label pairs as {a="x",b="y"}, with extra pairs
+// such as a histogram's le appended
+func labelString(names, values []string, extra ...string) string {
+	if len(names) == 0 && len(extra) == 0 {
+		return ""
+	}
+	escape := strings.NewReplacer("\\", `\\`, "\"", `\"`, "\n", `\n`)
+	pairs := make([]string, 0, len(names)+len(extra)/2)
+	for i, n := range names {
+		pairs = append(pairs, n+"=\""+escape.Replace(values[i])+"\"")
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, extra[i]+"=\""+escape.Replace(extra[i+1])+"\"")
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// series keeps the label values of each child of a vector in the order
+// they were first seen, keyed by the joined values
+type series struct {
+	labels []string
+	keys   []string
+	values map[string][]string
+}
+
+func newSeries(labels []string) series {
+	return series{labels: labels, values: make(map[string][]string)}
+}
+
+// key returns the key of a set of label values, adding it if it is new
+func (s *series) key(values []string) string {
+	if len(values) != len(s.labels) {
+		panic(fmt.Sprintf("metrics: %d label values for %d labels", len(values), len(s.labels)))
+	}
+	k := strings.Join(values, "\xff")
+	if _, ok := s.values[k]; !ok {
+		s.keys = append(s.keys, k)
+		s.values[k] = append([]string(nil), values...)
+	}
+	return k
+}
+
+// Counter is a monotonically increasing value for each set of label values
+type Counter struct {
+	metricName string
+	help       string
+
+	mu     sync.Mutex
+	series series
+	counts map[string]float64
+}
+
+// NewCounter registers a counter with the given label names
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{metricName: name, help: help, series: newSeries(labels), counts: make(map[string]float64)}
+	r.register(c)
+	return c
+}
+
+// Inc adds one to the counter for the label values
+func (c *Counter) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Add adds v, which must not be negative, to the counter for the label values
+func (c *Counter) Add(v float64, values ...string) {
+	if v < 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counts[c.series.key(values)] += v
+}
+
+func (c *Counter) name() string { return c.metricName }
+
+func (c *Counter) write(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	header(w, c.metricName, c.help, "counter")
+	if len(c.series.labels) == 0 && len(c.counts) == 0 {
+		fmt.Fprintf(w, "%s 0\n", c.metricName)
+		return
+	}
+	for _, k := range c.series.keys {
+		fmt.Fprintf(w, "%s%s %s\n", c.metricName, labelString(c.series.labels, c.series.values[k]), formatValue(c.counts[k]))
+	}
+}
+
+// Histogram counts observations into cumulative buckets for each set of
+// label values
+type Histogram struct {
+	metricName string
+	help       string
+	buckets    []float64
+
+	mu     sync.Mutex
+	series series
+	counts map[string][]uint64
+	sums   map[string]float64
+	totals map[string]uint64
+}
+
+// NewHistogram registers a histogram with the given bucket upper bounds,
+// which must be sorted, and label names
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	h := &Histogram{
+		metricName: name,
+		help:       help,
+		buckets:    buckets,
+		series:     newSeries(labels),
+		counts:     make(map[string][]uint64),
+		sums:       make(map[string]float64),
+		totals:     make(map[string]uint64),
+	}
+	r.register(h)
+	return h
+}
+
+// Observe records a value for the label values
+func (h *Histogram) Observe(v float64, values ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	k := h.series.key(values)
+	counts, ok := h.counts[k]
+	if !ok {
+		counts = make([]uint64, len(h.buckets))
+		h.counts[k] = counts
+	}
+	for i, le := range h.buckets {
+		if v <= le {
+			counts[i]++
+		}
+	}
+	h.sums[k] += v
+	h.totals[k]++
+}
+
+func (h *Histogram) name() string { return h.metricName }
+
+func (h *Histogram) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	header(w, h.metricName, h.help, "histogram")
+	for _, k := range h.series.keys {
+		values := h.series.values[k]
+		for i, le := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.metricName, labelString(h.series.labels, values, "le", formatValue(le)), h.counts[k][i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.metricName, labelString(h.series.labels, values, "le", "+Inf"), h.totals[k])
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.metricName, labelString(h.series.labels, values), formatValue(h.sums[k]))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.metricName, labelString(h.series.labels, values), h.totals[k])
+	}
+}
+
+// Sample is one value of a gauge with its label values
+type Sample struct {
+	Labels []string
+	Value  float64
+}
+
+// Gauge is a value read when the metrics are written
+type Gauge struct {
+	metricName string
+	help       string
+	labels     []string
+	read       func() []Sample
+}
+
+// NewGauge registers a gauge whose samples are returned by read at each scrape
+func (r *Registry) NewGauge(name, help string, read func() []Sample, labels ...string) *Gauge {
+	g := &Gauge{metricName: name, help: help, labels: labels, read: read}
+	r.register(g)
+	return g
+}
+
+func (g *Gauge) name() string { return g.metricName }
+
+func (g *Gauge) write(w io.Writer) {
+	header(w, g.metricName, g.help, "gauge")
+	for _, s := range g.read() {
+		if len(s.Labels) != len(g.labels) {
+			continue
+		}
+		fmt.Fprintf(w, "%s%s %s\n", g.metricName, labelString(g.labels, s.Labels), formatValue(s.Value))
+	}
+}
@@ -0,0 +1,136 @@
+package metrics
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(r *Registry)
+		want  string
+	}{
+		{
+			name: "counter without labels starts at zero",
+			setup: func(r *Registry) {
+				r.NewCounter("requests_total", "Requests.")
+			},
+			want: "# HELP requests_total Requests.\n# TYPE requests_total counter\nrequests_total 0\n",
+		},
+		{
+			name: "counter with labels in first-seen order",
+			setup: func(r *Registry) {
+				c := r.NewCounter("tiles_total", "Tiles.", "result")
+				c.Inc("ok")
+				c.Inc("notfound")
+				c.Add(2.5, "ok")
+				c.Add(-1, "ok")
+			},
+			want: "# HELP tiles_total Tiles.\n# TYPE tiles_total counter\n" +
+				"tiles_total{result=\"ok\"} 3.5\ntiles_total{result=\"notfound\"} 1\n",
+		},
+		{
+			name: "labelled counter with no samples",
+			setup: func(r *Registry) {
+				r.NewCounter("tiles_total", "Tiles.", "result")
+			},
+			want: "# HELP tiles_total Tiles.\n# TYPE tiles_total counter\n",
+		},
+		{
+			name: "escaping",
+			setup: func(r *Registry) {
+				c := r.NewCounter("errors_total", "Errors by message,\nwith a \\ in the help.", "message")
+				c.Inc("bad \"value\"\nat C:\\x")
+			},
+			want: "# HELP errors_total Errors by message,\\nwith a \\\\ in the help.\n# TYPE errors_total counter\n" +
+				"errors_total{message=\"bad \\\"value\\\"\\nat C:\\\\x\"} 1\n",
+		},
+		{
+			name: "histogram buckets are cumulative",
+			setup: func(r *Registry) {
+				h := r.NewHistogram("took_seconds", "Took.", []float64{0.1, 1}, "product")
+				h.Observe(0.05, "metars")
+				h.Observe(0.5, "metars")
+				h.Observe(3, "metars")
+			},
+			want: "# HELP took_seconds Took.\n# TYPE took_seconds histogram\n" +
+				"took_seconds_bucket{product=\"metars\",le=\"0.1\"} 1\n" +
+				"took_seconds_bucket{product=\"metars\",le=\"1\"} 2\n" +
+				"took_seconds_bucket{product=\"metars\",le=\"+Inf\"} 3\n" +
+				"took_seconds_sum{product=\"metars\"} 3.55\n" +
+				"took_seconds_count{product=\"metars\"} 3\n",
+		},
+		{
+			name: "histogram without labels",
+			setup: func(r *Registry) {
+				r.NewHistogram("size_bytes", "Size.", []float64{1024}).Observe(2048)
+			},
+			want: "# HELP size_bytes Size.\n# TYPE size_bytes histogram\n" +
+				"size_bytes_bucket{le=\"1024\"} 0\nsize_bytes_bucket{le=\"+Inf\"} 1\n" +
+				"size_bytes_sum 2048\nsize_bytes_count 1\n",
+		},
+		{
+			name: "gauge skips samples with the wrong label count",
+			setup: func(r *Registry) {
+				r.NewGauge("age_seconds", "Age.", func() []Sample {
+					return []Sample{
+						{Labels: []string{"metars"}, Value: 30},
+						{Labels: []string{"tafs", "extra"}, Value: 1},
+						{Labels: []string{"pireps"}, Value: math.Inf(1)},
+					}
+				}, "product")
+			},
+			want: "# HELP age_seconds Age.\n# TYPE age_seconds gauge\n" +
+				"age_seconds{product=\"metars\"} 30\nage_seconds{product=\"pireps\"} +Inf\n",
+		},
+		{
+			name: "metrics ordered by name",
+			setup: func(r *Registry) {
+				r.NewCounter("b_total", "B.")
+				r.NewCounter("a_total", "A.")
+			},
+			want: "# HELP a_total A.\n# TYPE a_total counter\na_total 0\n" +
+				"# HELP b_total B.\n# TYPE b_total counter\nb_total 0\n",
+		},
+	}
+	for _, tt := range tests {
+		r := NewRegistry()
+		tt.setup(r)
+		var b bytes.Buffer
+		r.Write(&b)
+		if got := b.String(); got != tt.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFormatValue(t *testing.T) {
+	tests := []struct {
+		v    float64
+		want string
+	}{
+		{0, "0"},
+		{42, "42"},
+		{0.25, "0.25"},
+		{1e21, "1e+21"},
+		{math.Inf(1), "+Inf"},
+		{math.Inf(-1), "-Inf"},
+		{math.NaN(), "NaN"},
+	}
+	for _, tt := range tests {
+		if got := formatValue(tt.v); got != tt.want {
+			t.Errorf("formatValue(%v) = %q, want %q", tt.v, got, tt.want)
+		}
+	}
+}
+
+func TestLabelCountMismatchPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("no panic for missing label values")
+		}
+	}()
+	NewRegistry().NewCounter("tiles_total", "Tiles.", "result", "tileset").Inc("ok")
+}
@@ -0,0 +1,143 @@
+package notams
+
+import (
+	"encoding/json"
+	"go-charts/internal/weather"
+	"strings"
+	"time"
+)
+
+// NOTAM formats
+const (
+	FormatICAO     = "ICAO"
+	FormatDomestic = "DOMESTIC"
+)
+
+// NOTAM types from the ICAO series line
+const (
+	TypeNew     = "N"
+	TypeReplace = "R"
+	TypeCancel  = "C"
+)
+
+// Notam is one decoded NOTAM. The Q-line fields are only present for ICAO
+// format NOTAMs; domestic NOTAMs are positioned from their location.
+type Notam struct {
+	Id            string
+	Format        string
+	Type          string
+	Replaces      string `json:",omitempty"`
+	Fir           string `json:",omitempty"`
+	Code          string `json:",omitempty"`
+	Traffic       string `json:",omitempty"`
+	Purpose       string `json:",omitempty"`
+	Scope         string `json:",omitempty"`
+	LowerFt       int32
+	UpperFt       int32
+	Latitude      float64
+	Longitude     float64
+	RadiusNm      float64
+	Located       bool
+	Locations     []string
+	Keyword       string `json:",omitempty"`
+	EffectiveFrom time.Time
+	EffectiveTo   time.Time
+	Estimated     bool
+	Permanent     bool
+	Schedule      string `json:",omitempty"`
+	Text          string
+	Lower         string `json:",omitempty"`
+	Upper         string `json:",omitempty"`
+	RawText       string
+}
+
+// Response holds the NOTAMs of one download
+type Response struct {
+	Notams []Notam
+}
+
+// Locator positions a location identifier from another data source
+type Locator interface {
+	Locate(ident string) (lat, lon float64, ok bool)
+}
+
+// Active reports whether the NOTAM is in effect at t. A NOTAM without an
+// end time, or a permanent one, stays in effect.
+func (n *Notam) Active(t time.Time) bool {
+	if !n.EffectiveFrom.IsZero() && t.Before(n.EffectiveFrom) {
+		return false
+	}
+	if n.Permanent || n.EffectiveTo.IsZero() {
+		return true
+	}
+	return t.Before(n.EffectiveTo)
+}
+
+// Expired reports whether the NOTAM has ended before t
+func (n *Notam) Expired(t time.Time) bool {
+	return !n.Permanent && !n.EffectiveTo.IsZero() && !t.Before(n.EffectiveTo)
+}
+
+// AppliesTo reports whether the NOTAM is for the airport. Domestic NOTAMs
+// use three letter FAA identifiers, so KDEN also matches DEN.
+func (n *Notam) AppliesTo(airport string) bool {
+	airport = strings.ToUpper(airport)
+	short := ""
+	if len(airport) == 4 && (airport[0] == 'K' || airport[0] == 'P') {
+		short = airport[1:]
+	}
+	for _, loc := range n.Locations {
+		if loc == airport || (short != "" && loc == short) {
+			return true
+		}
+	}
+	return false
+}
+
+// Count returns the number of NOTAMs in the response
+func (r *Response) Count() int {
+	return len(r.Notams)
+}
+
+func (r *Response) ToJson() (s string, err error) {
+	list := r.Notams
+	if list == nil {
+		list = []Notam{}
+	}
+	bytes, err := json.Marshal(list)
+	if err != nil {
+		return "", err
+	}
+	s = string(bytes)
+	return
+}
+
+// FromJson replaces the NOTAMs with those encoded by ToJson, dropping
+// any that have expired since, as Current does
+func (r *Response) FromJson(data []byte) error {
+	var list []Notam
+	err := json.Unmarshal(data, &list)
+	if err != nil {
+		return err
+	}
+	r.Notams = Current(list, time.Now().UTC())
+	return nil
+}
+
+// Records returns the NOTAMs keyed by number
+func (r *Response) Records() []weather.Record {
+	records := make([]weather.Record, 0, len(r.Notams))
+	for _, n := range r.Notams {
+		records = append(records, weather.Record{Key: n.Id, Data: n})
+	}
+	return records
+}
+
+func (r *Response) ToJsonIndented() (s string, err error) {
+	bytes, err := json.MarshalIndent(r.Notams, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	s = string(bytes)
+	return
+}
@@ -0,0 +1,244 @@
+package notams
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// timeLayout is the YYMMDDHHMM UTC format of NOTAM validity times
+const timeLayout = "0601021504"
+
+var (
+	reICAOStart   = regexp.MustCompile(`^\(?[A-Z]\d{4}/\d{2}\s+NOTAM[NRC]\b`)
+	reICAOHeader  = regexp.MustCompile(`^\(?([A-Z]\d{4}/\d{2})\s+NOTAM([NRC])(?:\s+([A-Z]\d{4}/\d{2}))?`)
+	reField       = regexp.MustCompile(`(?:^|\s)([QA-G])\)`)
+	reQCoords     = regexp.MustCompile(`^(\d{2})(\d{2})([NS])(\d{3})(\d{2})([EW])(\d{3})?$`)
+	reDomestic    = regexp.MustCompile(`^!(\S+)\s+(\d{1,2}/\d{1,5})\s+(\S+)\s+(.*)$`)
+	reDomesticEnd = regexp.MustCompile(`\s*(?:WEF\s+)?(\d{10}|WIE)-(\d{10}(?:EST)?|PERM|UFN)\s*$`)
+)
+
+// fieldOrder is the order the lettered items appear in an ICAO NOTAM.
+// Only a later item can start a new field, so text such as "E) ... C)"
+// inside item E is not mistaken for a field.
+const fieldOrder = "QABCDEFG"
+
+// Parse splits text into NOTAMs and decodes each one. ICAO NOTAMs start
+// with a series line such as "A1234/23 NOTAMN", optionally in parentheses;
+// FAA domestic NOTAMs start with "!". Text before the first NOTAM is
+// ignored. NOTAMs that cannot be decoded are skipped and reported in the
+// error, which is returned together with every NOTAM that could be.
+func Parse(text string) ([]Notam, error) {
+	var blocks []string
+	var current []string
+	flush := func() {
+		if len(current) > 0 {
+			blocks = append(blocks, strings.Join(current, "\n"))
+			current = nil
+		}
+	}
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if reICAOStart.MatchString(trimmed) || strings.HasPrefix(trimmed, "!") {
+			flush()
+			current = []string{trimmed}
+		} else if current != nil && trimmed != "" {
+			current = append(current, trimmed)
+		}
+	}
+	flush()
+
+	list := make([]Notam, 0, len(blocks))
+	var failed []string
+	for _, b := range blocks {
+		var n Notam
+		var err error
+		if strings.HasPrefix(b, "!") {
+			n, err = parseDomestic(b)
+		} else {
+			n, err = parseICAO(b)
+		}
+		if err != nil {
+			failed = append(failed, err.Error())
+			continue
+		}
+		list = append(list, n)
+	}
+	if len(failed) > 0 {
+		return list, fmt.Errorf("%d NOTAMs could not be decoded: %s", len(failed), strings.Join(failed, "; "))
+	}
+	return list, nil
+}
+
+// parseICAO decodes an ICAO format NOTAM: the series line, the Q-line and
+// items A to G
+func parseICAO(block string) (Notam, error) {
+	n := Notam{Format: FormatICAO, RawText: block}
+	h := reICAOHeader.FindStringSubmatch(block)
+	if h == nil {
+		return n, fmt.Errorf("bad NOTAM series line %q", firstLine(block))
+	}
+	n.Id, n.Type, n.Replaces = h[1], h[2], h[3]
+
+	body := block[len(h[0]):]
+	if strings.HasPrefix(block, "(") {
+		body = strings.TrimSuffix(strings.TrimSpace(body), ")")
+	}
+	fields := icaoFields(body)
+	if fields['Q'] == "" && fields['A'] == "" {
+		return n, fmt.Errorf("NOTAM %s has no Q) or A) item", n.Id)
+	}
+	if q := fields['Q']; q != "" {
+		n.parseQLine(q)
+	}
+	n.Locations = strings.Fields(fields['A'])
+	var err error
+	if b := fields['B']; b != "" && b != "WIE" {
+		n.EffectiveFrom, err = parseTime(b)
+		if err != nil {
+			return n, fmt.Errorf("NOTAM %s item B: %v", n.Id, err)
+		}
+	}
+	if c := fields['C']; c != "" {
+		n.EffectiveTo, n.Estimated, n.Permanent, err = parseEnd(c)
+		if err != nil {
+			return n, fmt.Errorf("NOTAM %s item C: %v", n.Id, err)
+		}
+	}
+	n.Schedule = fields['D']
+	n.Text = fields['E']
+	n.Lower = fields['F']
+	n.Upper = fields['G']
+	return n, nil
+}
+
+// icaoFields splits the items of an ICAO NOTAM by their letter
+func icaoFields(body string) map[byte]string {
+	fields := make(map[byte]string)
+	var starts [][]int
+	last := -1
+	for _, m := range reField.FindAllStringSubmatchIndex(body, -1) {
+		pos := strings.IndexByte(fieldOrder, body[m[2]])
+		if pos > last {
+			starts = append(starts, m)
+			last = pos
+		}
+	}
+	for i, m := range starts {
+		end := len(body)
+		if i+1 < len(starts) {
+			end = starts[i+1][0]
+		}
+		fields[body[m[2]]] = strings.TrimSpace(body[m[1]:end])
+	}
+	return fields
+}
+
+// parseQLine decodes FIR/QCODE/TRAFFIC/PURPOSE/SCOPE/LOWER/UPPER/COORDS.
+// Limits are flight levels and the radius is in nautical miles.
+func (n *Notam) parseQLine(q string) {
+	parts := strings.Split(q, "/")
+	get := func(i int) string {
+		if i < len(parts) {
+			return strings.TrimSpace(parts[i])
+		}
+		return ""
+	}
+	n.Fir = get(0)
+	n.Code = get(1)
+	n.Traffic = get(2)
+	n.Purpose = get(3)
+	n.Scope = get(4)
+	if fl, err := strconv.Atoi(get(5)); err == nil {
+		n.LowerFt = int32(fl * 100)
+	}
+	if fl, err := strconv.Atoi(get(6)); err == nil {
+		n.UpperFt = int32(fl * 100)
+	}
+	c := reQCoords.FindStringSubmatch(get(7))
+	if c == nil {
+		return
+	}
+	n.Latitude = float64(atoi(c[1])) + float64(atoi(c[2]))/60
+	if c[3] == "S" {
+		n.Latitude = -n.Latitude
+	}
+	n.Longitude = float64(atoi(c[4])) + float64(atoi(c[5]))/60
+	if c[6] == "W" {
+		n.Longitude = -n.Longitude
+	}
+	n.RadiusNm = float64(atoi(c[7]))
+	n.Located = true
+}
+
+// parseDomestic decodes an FAA domestic NOTAM such as
+// "!DEN 10/123 DEN RWY 16L/34R CLSD 2310011200-2310152359"
+func parseDomestic(block string) (Notam, error) {
+	n := Notam{Format: FormatDomestic, Type: TypeNew, RawText: block}
+	m := reDomestic.FindStringSubmatch(strings.Join(strings.Fields(block), " "))
+	if m == nil {
+		return n, fmt.Errorf("bad domestic NOTAM %q", firstLine(block))
+	}
+	n.Id = m[1] + " " + m[2]
+	n.Locations = []string{m[3]}
+	text := m[4]
+	if v := reDomesticEnd.FindStringSubmatchIndex(text); v != nil {
+		start, end := text[v[2]:v[3]], text[v[4]:v[5]]
+		var err error
+		if start != "WIE" {
+			n.EffectiveFrom, err = parseTime(start)
+			if err != nil {
+				return n, fmt.Errorf("NOTAM %s: %v", n.Id, err)
+			}
+		}
+		if end != "UFN" {
+			n.EffectiveTo, n.Estimated, n.Permanent, err = parseEnd(end)
+			if err != nil {
+				return n, fmt.Errorf("NOTAM %s: %v", n.Id, err)
+			}
+		}
+		text = text[:v[0]]
+	}
+	n.Text = strings.TrimSpace(text)
+	if words := strings.Fields(n.Text); len(words) > 0 {
+		n.Keyword = words[0]
+	}
+	return n, nil
+}
+
+// parseEnd decodes an end of validity, which may be PERM or carry an EST suffix
+func parseEnd(s string) (t time.Time, estimated, permanent bool, err error) {
+	s = strings.TrimSpace(s)
+	if s == "PERM" {
+		return time.Time{}, false, true, nil
+	}
+	if strings.HasSuffix(s, "EST") {
+		estimated = true
+		s = strings.TrimSpace(strings.TrimSuffix(s, "EST"))
+	}
+	t, err = parseTime(s)
+	return t, estimated, false, err
+}
+
+// parseTime decodes a YYMMDDHHMM validity time
+func parseTime(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if len(s) > len(timeLayout) {
+		s = s[:len(timeLayout)]
+	}
+	return time.Parse(timeLayout, s)
+}
+
+func atoi(s string) int {
+	v, _ := strconv.Atoi(s)
+	return v
+}
+
+func firstLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
@@ -0,0 +1,97 @@
+package notams
+
+import (
+	"go-charts/internal/geojson"
+	"go-charts/internal/weather"
+	"time"
+)
+
+// Source downloads and decodes NOTAMs from an HTTP feed, or from local
+// files with a file:// url
+type Source struct {
+	url string
+	loc Locator
+	now func() time.Time
+}
+
+// NewSource returns a NOTAM source reading text in ICAO or FAA domestic
+// format from url. loc positions NOTAMs that have no Q-line coordinates
+// and may be nil. now, which may also be nil for the system clock, gives
+// the time expired NOTAMs are dropped at.
+func NewSource(url string, loc Locator, now func() time.Time) *Source {
+	if now == nil {
+		now = time.Now
+	}
+	return &Source{url: url, loc: loc, now: now}
+}
+
+// Name returns the product name used for messages and files
+func (s *Source) Name() string {
+	return "notams"
+}
+
+// URL returns the download location
+func (s *Source) URL() string {
+	return s.url
+}
+
+// Empty returns a Response with no reports
+func (s *Source) Empty() weather.Product {
+	return &Response{}
+}
+
+// Decode parses the NOTAMs and keeps those that are in effect or yet to
+// start. Undecodable NOTAMs are dropped unless none could be decoded.
+func (s *Source) Decode(data []byte) (weather.Product, error) {
+	list, err := Parse(string(data))
+	if err != nil && len(list) == 0 {
+		return nil, err
+	}
+	r := &Response{Notams: Current(list, s.now().UTC())}
+	if s.loc != nil {
+		for i := range r.Notams {
+			n := &r.Notams[i]
+			if n.Located || len(n.Locations) == 0 {
+				continue
+			}
+			n.Latitude, n.Longitude, n.Located = s.loc.Locate(n.Locations[0])
+		}
+	}
+	return r, nil
+}
+
+// Current drops cancellations, the NOTAMs they cancel, NOTAMs that have
+// been replaced and NOTAMs that expired before now
+func Current(list []Notam, now time.Time) []Notam {
+	superseded := make(map[string]bool)
+	for _, n := range list {
+		if n.Replaces != "" {
+			superseded[n.Replaces] = true
+		}
+	}
+	current := make([]Notam, 0, len(list))
+	for _, n := range list {
+		if n.Type == TypeCancel || superseded[n.Id] || n.Expired(now) {
+			continue
+		}
+		current = append(current, n)
+	}
+	return current
+}
+
+// ToGeoJson encodes the positioned NOTAMs as a FeatureCollection of
+// points, with the radius of the Q-line in the properties
+func (r *Response) ToGeoJson() (string, error) {
+	fc := geojson.NewFeatureCollection()
+	for _, n := range r.Notams {
+		if !n.Located {
+			continue
+		}
+		f, err := geojson.NewFeature(n.Id, geojson.NewPoint(n.Longitude, n.Latitude), n)
+		if err != nil {
+			return "", err
+		}
+		fc.Features = append(fc.Features, f)
+	}
+	return fc.Encode()
+}
@@ -0,0 +1,486 @@
+package pireps
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Locator resolves a station or navaid identifier to its position
+type Locator interface {
+	Locate(ident string) (lat, lon float64, ok bool)
+}
+
+var (
+	rePirepOffset   = regexp.MustCompile(`^([A-Z0-9]{3,5})(\d{3})(\d{3})$`)
+	rePirepIdent    = regexp.MustCompile(`^[A-Z0-9]{3,5}$`)
+	rePirepLatLon   = regexp.MustCompile(`^(\d{2})(\d{2})?([NS])\s?(\d{2,3}?)(\d{2})?([EW])$`)
+	rePirepTime     = regexp.MustCompile(`^(\d{2})(\d{2})Z?$`)
+	rePirepFL       = regexp.MustCompile(`^(\d{3})$`)
+	rePirepVis      = regexp.MustCompile(`^FV(\d{2})SM$`)
+	rePirepTemp     = regexp.MustCompile(`^(M|-|\+|PS|MS)?(\d{1,2})$`)
+	rePirepWind     = regexp.MustCompile(`^(\d{3})/?(\d{2,3})(?:KT)?$`)
+	rePirepAlt      = regexp.MustCompile(`^(\d{3})(?:-(\d{3}))?$`)
+	rePirepSky      = regexp.MustCompile(`^((?:SKC|CLR|FEW|SCT|BKN|OVC|OVX)(?:-(?:FEW|SCT|BKN|OVC))?)?(\d{3}|UNKN)?(?:-?TOPS?(\d{3}|UNKN))?$`)
+	rePirepAirepPos = regexp.MustCompile(`^(\d{2})(\d{2})?([NS])(\d{3})(\d{2})?([EW])$`)
+	rePirepAirepFL  = regexp.MustCompile(`^F(\d{3})$`)
+	rePirepAirepTA  = regexp.MustCompile(`^(PS|MS)(\d{2})$`)
+)
+
+var (
+	turbIntensities = map[string]bool{"NEG": true, "SMTH": true, "LGT": true, "MOD": true, "SEV": true, "EXTM": true, "EXTRM": true}
+	turbTypes       = map[string]bool{"CAT": true, "CHOP": true, "LLWS": true, "MWAVE": true}
+	turbFrequencies = map[string]bool{"OCNL": true, "INTMT": true, "CONS": true, "ISOL": true}
+	iceIntensities  = map[string]bool{"NEG": true, "NEGCLR": true, "TRC": true, "LGT": true, "MOD": true, "SEV": true, "HVY": true}
+	iceTypes        = map[string]string{"RIME": "RIME", "CLR": "CLEAR", "CLEAR": "CLEAR", "MX": "MIXED", "MXD": "MIXED", "MIXED": "MIXED"}
+)
+
+// Decode parses the text of a UA/UUA report, or an AIREP, into a Pirep.
+// The report time carries only hours and minutes, so ref supplies the date;
+// it is normally the time the report was received. loc resolves /OV
+// identifiers and may be nil, in which case only latitude/longitude
+// locations are decoded.
+func Decode(raw string, ref time.Time, loc Locator) (Pirep, error) {
+	p := Pirep{RawText: strings.TrimSpace(raw), ReceiptTime: ref.UTC()}
+	text := strings.TrimSuffix(p.RawText, "=")
+
+	head := strings.Fields(text)
+	if len(head) > 0 && (head[0] == "ARP" || head[0] == "AIREP") {
+		return decodeAirep(p, head[1:], ref)
+	}
+
+	kind, fields := splitFields(text)
+	if len(fields) == 0 {
+		return p, fmt.Errorf("no report fields found in %q", raw)
+	}
+	switch {
+	case strings.HasSuffix(strings.TrimSpace(kind), "UUA"):
+		p.PirepType = "Urgent PIREP"
+	case strings.HasSuffix(strings.TrimSpace(kind), "UA"):
+		p.PirepType = "PIREP"
+	default:
+		return p, fmt.Errorf("report type UA or UUA not found in %q", raw)
+	}
+
+	for _, field := range fields {
+		name, value := field[:2], strings.TrimSpace(field[2:])
+		switch name {
+		case "OV":
+			decodeLocation(&p, value, loc)
+		case "TM":
+			if m := rePirepTime.FindStringSubmatch(value); m != nil {
+				p.ObservationTime = reportTime(ref, atoi(m[1]), atoi(m[2]))
+			}
+		case "FL":
+			if m := rePirepFL.FindStringSubmatch(value); m != nil {
+				p.AltitudeFtMsl = float64(atoi(m[1]) * 100)
+			}
+		case "TP":
+			p.AircraftRef = value
+		case "SK":
+			p.SkyCondition = decodeSky(value)
+		case "WX":
+			var wx []string
+			for _, tok := range strings.Fields(value) {
+				if m := rePirepVis.FindStringSubmatch(tok); m != nil {
+					p.VisibilityStatuteMi = float64(atoi(m[1]))
+				} else {
+					wx = append(wx, tok)
+				}
+			}
+			p.WxString = strings.Join(wx, " ")
+		case "TA":
+			if t, ok := decodeTemp(value); ok {
+				p.TempC = t
+			}
+		case "WV":
+			if m := rePirepWind.FindStringSubmatch(value); m != nil {
+				p.WindDirDegrees = int32(atoi(m[1]))
+				p.WindSpeedKt = int32(atoi(m[2]))
+			}
+		case "TB":
+			p.TurbulenceCondition = decodeTurbulence(value)
+		case "IC":
+			p.IcingCondition = decodeIcing(value)
+		case "RM":
+			p.Remarks = value
+		}
+	}
+	if p.ObservationTime.IsZero() {
+		p.ObservationTime = p.ReceiptTime
+	}
+	return p, nil
+}
+
+// fieldNames are the fields of a UA/UUA report. A field starts with a
+// slash and its name, with or without a space before the slash, as in the
+// compact /OV BFF270015/TM 1755/FL095. Values may contain slashes of their
+// own, such as the wind 270/045KT or the tops in BKN045/OVC060, so only a
+// known name that isn't followed by another letter starts a field.
+var fieldNames = map[string]bool{"OV": true, "TM": true, "FL": true, "TP": true, "SK": true, "WX": true,
+	"TA": true, "WV": true, "TB": true, "IC": true, "RM": true}
+
+// splitFields splits a report into the text before its first field and
+// the fields, each starting with its two letter name. The remarks run to
+// the end of the report.
+func splitFields(text string) (string, []string) {
+	start := nextMarker(text, 0)
+	if start < 0 {
+		return text, nil
+	}
+	head := text[:start]
+	var fields []string
+	for start >= 0 {
+		next := -1
+		if text[start+1:start+3] != "RM" {
+			next = nextMarker(text, start+1)
+		}
+		end := next
+		if end < 0 {
+			end = len(text)
+		}
+		fields = append(fields, text[start+1:end])
+		start = next
+	}
+	return head, fields
+}
+
+// nextMarker returns the index of the slash of the first field at or
+// after i, or -1
+func nextMarker(text string, i int) int {
+	for j := i; j+3 <= len(text); j++ {
+		if text[j] != '/' || !fieldNames[text[j+1:j+3]] {
+			continue
+		}
+		if j+3 < len(text) && text[j+3] >= 'A' && text[j+3] <= 'Z' {
+			continue
+		}
+		return j
+	}
+	return -1
+}
+
+// decodeLocation resolves the /OV field: a fix, a fix with radial and
+// distance (DEN090020), a route between two fixes or a latitude/longitude
+func decodeLocation(p *Pirep, value string, loc Locator) {
+	points := strings.Split(value, "-")
+	var lats, lons []float64
+	for _, pt := range points {
+		lat, lon, ok := locatePoint(strings.TrimSpace(pt), loc)
+		if !ok {
+			p.QualityControlFlags.BadLocation = "TRUE"
+			return
+		}
+		lats = append(lats, lat)
+		lons = append(lons, lon)
+	}
+	if len(lats) == 0 {
+		p.QualityControlFlags.BadLocation = "TRUE"
+		return
+	}
+	if len(lats) == 1 {
+		p.Latitude, p.Longitude = lats[0], lons[0]
+		return
+	}
+	// a route report is placed at the midpoint of its first and last fixes
+	p.Latitude, p.Longitude = midpoint(lats[0], lons[0], lats[len(lats)-1], lons[len(lons)-1])
+	p.QualityControlFlags.MidPointAssumed = "TRUE"
+}
+
+func locatePoint(pt string, loc Locator) (float64, float64, bool) {
+	if m := rePirepLatLon.FindStringSubmatch(pt); m != nil {
+		lat := float64(atoi(m[1])) + float64(atoi(m[2]))/60
+		lon := float64(atoi(m[4])) + float64(atoi(m[5]))/60
+		if m[3] == "S" {
+			lat = -lat
+		}
+		if m[6] == "W" {
+			lon = -lon
+		}
+		return lat, lon, true
+	}
+	if loc == nil {
+		return 0, 0, false
+	}
+	if m := rePirepOffset.FindStringSubmatch(pt); m != nil {
+		lat, lon, ok := loc.Locate(m[1])
+		if !ok {
+			return 0, 0, false
+		}
+		lat, lon = offset(lat, lon, float64(atoi(m[2])), float64(atoi(m[3])))
+		return lat, lon, true
+	}
+	if rePirepIdent.MatchString(pt) {
+		return loc.Locate(pt)
+	}
+	return 0, 0, false
+}
+
+// decodeSky reads cloud layers such as BKN030-TOP045 OVC080
+func decodeSky(value string) []SkyCondition {
+	var layers []SkyCondition
+	for _, tok := range strings.Fields(strings.ReplaceAll(value, "TOP ", "TOP")) {
+		m := rePirepSky.FindStringSubmatch(tok)
+		if m == nil || (m[1] == "" && m[2] == "" && m[3] == "") {
+			continue
+		}
+		var layer SkyCondition
+		layer.SkyCover = m[1]
+		if m[2] != "" && m[2] != "UNKN" {
+			layer.CloudBaseFtMsl = strconv.Itoa(atoi(m[2]) * 100)
+		}
+		if m[3] != "" && m[3] != "UNKN" {
+			layer.CloudTopFtMsl = strconv.Itoa(atoi(m[3]) * 100)
+		}
+		// a bare tops report belongs to the preceding layer
+		if m[1] == "" && m[2] == "" && len(layers) > 0 {
+			layers[len(layers)-1].CloudTopFtMsl = layer.CloudTopFtMsl
+			continue
+		}
+		layers = append(layers, layer)
+	}
+	return layers
+}
+
+// decodeTurbulence reads one or more turbulence reports, e.g.
+// OCNL LGT-MOD CHOP 060-080 or MOD CAT ABV 350. A slash, as in
+// ABV 090/TOPS, separates words like a space.
+func decodeTurbulence(value string) []TurbulenceCondition {
+	var conds []TurbulenceCondition
+	var cur *TurbulenceCondition
+	altitudeSet := false
+	start := func() {
+		conds = append(conds, TurbulenceCondition{})
+		cur = &conds[len(conds)-1]
+		altitudeSet = false
+	}
+	tokens := strings.Fields(strings.ReplaceAll(value, "/", " "))
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+		switch {
+		case turbFrequencies[tok]:
+			if cur == nil || altitudeSet || cur.TurbulenceIntensity != "" {
+				start()
+			}
+			cur.TurbulenceFreq = tok
+		case isIntensity(tok, turbIntensities):
+			if cur == nil || altitudeSet || cur.TurbulenceIntensity != "" {
+				start()
+			}
+			cur.TurbulenceIntensity = normalizeIntensity(tok)
+		case turbTypes[tok]:
+			if cur == nil {
+				start()
+			}
+			cur.TurbulenceType = tok
+		case (tok == "BLO" || tok == "ABV") && i+1 < len(tokens) && rePirepFL.MatchString(tokens[i+1]):
+			if cur == nil {
+				start()
+			}
+			alt := strconv.Itoa(atoi(tokens[i+1]) * 100)
+			if tok == "BLO" {
+				cur.TurbulenceTopFtMsl = alt
+			} else {
+				cur.TurbulenceBaseFtMsl = alt
+			}
+			altitudeSet = true
+			i++
+		case rePirepAlt.MatchString(tok):
+			if cur == nil {
+				start()
+			}
+			cur.TurbulenceBaseFtMsl, cur.TurbulenceTopFtMsl = altitudeRange(tok)
+			altitudeSet = true
+		}
+	}
+	return conds
+}
+
+// decodeIcing reads one or more icing reports, e.g. LGT-MOD RIME 070-090.
+// A slash separates words like a space.
+func decodeIcing(value string) []IcingCondition {
+	var conds []IcingCondition
+	var cur *IcingCondition
+	altitudeSet := false
+	start := func() {
+		conds = append(conds, IcingCondition{})
+		cur = &conds[len(conds)-1]
+		altitudeSet = false
+	}
+	tokens := strings.Fields(strings.ReplaceAll(value, "/", " "))
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+		switch {
+		case isIntensity(strings.ToUpper(tok), iceIntensities):
+			if cur == nil || altitudeSet || cur.IcingIntensity != "" {
+				start()
+			}
+			cur.IcingIntensity = normalizeIntensity(tok)
+			if strings.ToUpper(tok) == "NEGCLR" {
+				cur.IcingIntensity = "NEGclr"
+			}
+		case iceTypes[tok] != "":
+			if cur == nil {
+				start()
+			}
+			cur.IcingType = iceTypes[tok]
+		case (tok == "BLO" || tok == "ABV") && i+1 < len(tokens) && rePirepFL.MatchString(tokens[i+1]):
+			if cur == nil {
+				start()
+			}
+			alt := strconv.Itoa(atoi(tokens[i+1]) * 100)
+			if tok == "BLO" {
+				cur.IcingTopFtMsl = alt
+			} else {
+				cur.IcingBaseFtMsl = alt
+			}
+			altitudeSet = true
+			i++
+		case rePirepAlt.MatchString(tok):
+			if cur == nil {
+				start()
+			}
+			cur.IcingBaseFtMsl, cur.IcingTopFtMsl = altitudeRange(tok)
+			altitudeSet = true
+		}
+	}
+	return conds
+}
+
+// decodeAirep reads the fixed-order AIREP body:
+// callsign position time flightlevel [temperature] [wind] [TB ...] [ICE ...]
+func decodeAirep(p Pirep, tokens []string, ref time.Time) (Pirep, error) {
+	p.PirepType = "AIREP"
+	if len(tokens) < 3 {
+		return p, fmt.Errorf("incomplete AIREP %q", p.RawText)
+	}
+	p.AircraftRef = tokens[0]
+	for i := 1; i < len(tokens); i++ {
+		tok := tokens[i]
+		switch {
+		case rePirepAirepPos.MatchString(tok):
+			lat, lon, _ := locatePoint(tok, nil)
+			p.Latitude, p.Longitude = lat, lon
+		case rePirepTime.MatchString(tok) && p.ObservationTime.IsZero():
+			m := rePirepTime.FindStringSubmatch(tok)
+			p.ObservationTime = reportTime(ref, atoi(m[1]), atoi(m[2]))
+		case rePirepAirepFL.MatchString(tok):
+			p.AltitudeFtMsl = float64(atoi(tok[1:]) * 100)
+		case rePirepAirepTA.MatchString(tok):
+			p.TempC, _ = decodeTemp(tok)
+		case rePirepWind.MatchString(tok) && strings.Contains(tok, "/"):
+			m := rePirepWind.FindStringSubmatch(tok)
+			p.WindDirDegrees = int32(atoi(m[1]))
+			p.WindSpeedKt = int32(atoi(m[2]))
+		case tok == "TB" || tok == "TURB":
+			end := nextKeyword(tokens, i+1)
+			p.TurbulenceCondition = decodeTurbulence(strings.Join(tokens[i+1:end], " "))
+			i = end - 1
+		case tok == "ICE" || tok == "IC":
+			end := nextKeyword(tokens, i+1)
+			p.IcingCondition = decodeIcing(strings.Join(tokens[i+1:end], " "))
+			i = end - 1
+		}
+	}
+	if p.ObservationTime.IsZero() {
+		p.ObservationTime = p.ReceiptTime
+	}
+	if p.Latitude == 0 && p.Longitude == 0 {
+		p.QualityControlFlags.BadLocation = "TRUE"
+	}
+	return p, nil
+}
+
+func nextKeyword(tokens []string, i int) int {
+	for ; i < len(tokens); i++ {
+		switch tokens[i] {
+		case "TB", "TURB", "ICE", "IC", "RM":
+			return i
+		}
+	}
+	return i
+}
+
+func decodeTemp(value string) (float64, bool) {
+	m := rePirepTemp.FindStringSubmatch(strings.TrimSpace(value))
+	if m == nil {
+		return 0, false
+	}
+	t := float64(atoi(m[2]))
+	if m[1] == "M" || m[1] == "-" || m[1] == "MS" {
+		t = -t
+	}
+	return t, true
+}
+
+// isIntensity reports whether tok is an intensity or a range of intensities
+// such as LGT-MOD
+func isIntensity(tok string, known map[string]bool) bool {
+	for _, part := range strings.Split(tok, "-") {
+		if !known[part] {
+			return false
+		}
+	}
+	return true
+}
+
+func normalizeIntensity(tok string) string {
+	tok = strings.ReplaceAll(tok, "EXTRM", "EXTM")
+	if tok == "SMTH" {
+		return "SMTH-LGT"
+	}
+	return tok
+}
+
+func altitudeRange(tok string) (base, top string) {
+	m := rePirepAlt.FindStringSubmatch(tok)
+	base = strconv.Itoa(atoi(m[1]) * 100)
+	if m[2] != "" {
+		top = strconv.Itoa(atoi(m[2]) * 100)
+	} else {
+		top = base
+	}
+	return base, top
+}
+
+// reportTime returns the latest time with the given hour and minute that is
+// not more than an hour after ref
+func reportTime(ref time.Time, hour, minute int) time.Time {
+	ref = ref.UTC()
+	t := time.Date(ref.Year(), ref.Month(), ref.Day(), hour, minute, 0, 0, time.UTC)
+	if t.After(ref.Add(time.Hour)) {
+		t = t.AddDate(0, 0, -1)
+	}
+	return t
+}
+
+// offset moves a position along a great circle by a bearing in degrees and
+// a distance in nautical miles
+func offset(lat, lon, bearing, distNm float64) (float64, float64) {
+	const earthRadiusNm = 3440.065
+	d := distNm / earthRadiusNm
+	b := bearing * math.Pi / 180
+	la1 := lat * math.Pi / 180
+	lo1 := lon * math.Pi / 180
+	la2 := math.Asin(math.Sin(la1)*math.Cos(d) + math.Cos(la1)*math.Sin(d)*math.Cos(b))
+	lo2 := lo1 + math.Atan2(math.Sin(b)*math.Sin(d)*math.Cos(la1), math.Cos(d)-math.Sin(la1)*math.Sin(la2))
+	return la2 * 180 / math.Pi, math.Mod(lo2*180/math.Pi+540, 360) - 180
+}
+
+func midpoint(lat1, lon1, lat2, lon2 float64) (float64, float64) {
+	la1, lo1 := lat1*math.Pi/180, lon1*math.Pi/180
+	la2, lo2 := lat2*math.Pi/180, lon2*math.Pi/180
+	bx := math.Cos(la2) * math.Cos(lo2-lo1)
+	by := math.Cos(la2) * math.Sin(lo2-lo1)
+	la := math.Atan2(math.Sin(la1)+math.Sin(la2), math.Sqrt((math.Cos(la1)+bx)*(math.Cos(la1)+bx)+by*by))
+	lo := lo1 + math.Atan2(by, math.Cos(la1)+bx)
+	return la * 180 / math.Pi, lo * 180 / math.Pi
+}
+
+func atoi(s string) int {
+	v, _ := strconv.Atoi(s)
+	return v
+}
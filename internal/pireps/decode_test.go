@@ -0,0 +1,183 @@
+package pireps
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+var testRef = time.Date(2026, 10, 18, 16, 0, 0, 0, time.UTC)
+
+// testLocator places every identifier it knows at a fixed position
+type testLocator map[string][2]float64
+
+func (l testLocator) Locate(ident string) (float64, float64, bool) {
+	p, ok := l[ident]
+	return p[0], p[1], ok
+}
+
+var testFixes = testLocator{
+	"DEN": {39.86, -104.67},
+	"COS": {38.81, -104.70},
+	"BFF": {41.87, -103.60},
+}
+
+func TestDecodeFields(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want Pirep
+	}{
+		{
+			"DEN UA /OV DEN /TM 1530 /FL080 /TP C172 /SK BKN045-TOP065 /TA M05 /WV 270/45KT /RM SMOOTH",
+			Pirep{
+				PirepType:       "PIREP",
+				ObservationTime: time.Date(2026, 10, 18, 15, 30, 0, 0, time.UTC),
+				Latitude:        39.86,
+				Longitude:       -104.67,
+				AltitudeFtMsl:   8000,
+				AircraftRef:     "C172",
+				SkyCondition:    []SkyCondition{{SkyCover: "BKN", CloudBaseFtMsl: "4500", CloudTopFtMsl: "6500"}},
+				TempC:           -5,
+				WindDirDegrees:  270,
+				WindSpeedKt:     45,
+				Remarks:         "SMOOTH",
+			},
+		},
+		{
+			"COS UUA /OV 3850N10445W /TM 1545 /FL120 /TP B737 /TB SEV CHOP 100-140 /IC MOD RIME 090-110",
+			Pirep{
+				PirepType:           "Urgent PIREP",
+				ObservationTime:     time.Date(2026, 10, 18, 15, 45, 0, 0, time.UTC),
+				Latitude:            38 + 50.0/60,
+				Longitude:           -(104 + 45.0/60),
+				AltitudeFtMsl:       12000,
+				AircraftRef:         "B737",
+				TurbulenceCondition: []TurbulenceCondition{{TurbulenceIntensity: "SEV", TurbulenceType: "CHOP", TurbulenceBaseFtMsl: "10000", TurbulenceTopFtMsl: "14000"}},
+				IcingCondition:      []IcingCondition{{IcingIntensity: "MOD", IcingType: "RIME", IcingBaseFtMsl: "9000", IcingTopFtMsl: "11000"}},
+			},
+		},
+		{
+			// the slash inside the tops report doesn't end the field
+			"DEN UA /OV DEN /TM 1510 /FL070 /TP PA28 /TB LGT ABV 090/TOPS /WX FV05SM HZ",
+			Pirep{
+				PirepType:           "PIREP",
+				ObservationTime:     time.Date(2026, 10, 18, 15, 10, 0, 0, time.UTC),
+				Latitude:            39.86,
+				Longitude:           -104.67,
+				AltitudeFtMsl:       7000,
+				AircraftRef:         "PA28",
+				TurbulenceCondition: []TurbulenceCondition{{TurbulenceIntensity: "LGT", TurbulenceBaseFtMsl: "9000"}},
+				VisibilityStatuteMi: 5,
+				WxString:            "HZ",
+			},
+		},
+		{
+			// the remarks run to the end, slashes and all
+			"DEN UA /OV DEN /TM 1550 /FL350 /TP A320 /RM WND 270/110 /TB MOD CAT",
+			Pirep{
+				PirepType:       "PIREP",
+				ObservationTime: time.Date(2026, 10, 18, 15, 50, 0, 0, time.UTC),
+				Latitude:        39.86,
+				Longitude:       -104.67,
+				AltitudeFtMsl:   35000,
+				AircraftRef:     "A320",
+				Remarks:         "WND 270/110 /TB MOD CAT",
+			},
+		},
+	}
+	for _, tt := range tests {
+		p, err := Decode(tt.raw, testRef, testFixes)
+		if err != nil {
+			t.Errorf("%s: %v", tt.raw, err)
+			continue
+		}
+		tt.want.RawText = tt.raw
+		tt.want.ReceiptTime = testRef
+		if !reflect.DeepEqual(p, tt.want) {
+			t.Errorf("%s:\ngot  %+v\nwant %+v", tt.raw, p, tt.want)
+		}
+	}
+}
+
+func TestDecodeCompactFields(t *testing.T) {
+	// the compact form leaves out the space before each field
+	tests := []struct {
+		compact, spaced string
+	}{
+		{
+			"BFF UA /OV BFF270015/TM 1755/FL095/TP BE20/TB LGT/RM ZDV",
+			"BFF UA /OV BFF270015 /TM 1755 /FL095 /TP BE20 /TB LGT /RM ZDV",
+		},
+		{
+			"DEN UA /OV DEN/TM 1530/FL080/TP C172/SK BKN045-TOP065/TA M05/WV 270/45KT/RM SMOOTH",
+			"DEN UA /OV DEN /TM 1530 /FL080 /TP C172 /SK BKN045-TOP065 /TA M05 /WV 270/45KT /RM SMOOTH",
+		},
+		{
+			"COS UUA /OV DEN-COS/TM 1545/FL120/TP B737/TB SEV CHOP 100-140/IC MOD RIME 090-110",
+			"COS UUA /OV DEN-COS /TM 1545 /FL120 /TP B737 /TB SEV CHOP 100-140 /IC MOD RIME 090-110",
+		},
+		{
+			// the remarks still run to the end
+			"DEN UA /OV DEN/TM 1550/FL350/TP A320/RM WND 270/110/TB MOD CAT",
+			"DEN UA /OV DEN /TM 1550 /FL350 /TP A320 /RM WND 270/110/TB MOD CAT",
+		},
+	}
+	for _, tt := range tests {
+		got, err := Decode(tt.compact, testRef, testFixes)
+		if err != nil {
+			t.Errorf("%s: %v", tt.compact, err)
+			continue
+		}
+		want, err := Decode(tt.spaced, testRef, testFixes)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.spaced, err)
+		}
+		if got.QualityControlFlags.BadLocation == "TRUE" || got.AltitudeFtMsl == 0 || got.AircraftRef == "" {
+			t.Errorf("%s: fields not split: %+v", tt.compact, got)
+		}
+		got.RawText = want.RawText
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s:\ngot  %+v\nwant %+v", tt.compact, got, want)
+		}
+	}
+}
+
+func TestDecodeLocation(t *testing.T) {
+	tests := []struct {
+		ov       string
+		lat, lon float64
+		midpoint bool
+		bad      bool
+	}{
+		{"DEN", 39.86, -104.67, false, false},
+		{"DEN360030", 39.86 + 0.5, -104.67, false, false},
+		{"DEN-COS", 39.335, -104.685, true, false},
+		{"3950N10430W", 39 + 50.0/60, -104.5, false, false},
+		{"XYZ", 0, 0, false, true},
+	}
+	for _, tt := range tests {
+		p, err := Decode("DEN UA /OV "+tt.ov+" /TM 1530 /FL080 /TP C172", testRef, testFixes)
+		if err != nil {
+			t.Errorf("%s: %v", tt.ov, err)
+			continue
+		}
+		if !near(p.Latitude, tt.lat) || !near(p.Longitude, tt.lon) {
+			t.Errorf("%s: position %v,%v, want %v,%v", tt.ov, p.Latitude, p.Longitude, tt.lat, tt.lon)
+		}
+		if (p.QualityControlFlags.MidPointAssumed == "TRUE") != tt.midpoint || (p.QualityControlFlags.BadLocation == "TRUE") != tt.bad {
+			t.Errorf("%s: flags %+v", tt.ov, p.QualityControlFlags)
+		}
+	}
+}
+
+func TestDecodeRejectsOtherReports(t *testing.T) {
+	for _, raw := range []string{"KDEN 181153Z 27015KT 10SM CLR 12/M01 A3012", "DEN UA"} {
+		if _, err := Decode(raw, testRef, testFixes); err == nil {
+			t.Errorf("%s: decoded as a PIREP", raw)
+		}
+	}
+}
+
+func near(a, b float64) bool {
+	return a-b < 0.01 && b-a < 0.01
+}
@@ -0,0 +1,277 @@
+package pireps
+
+import (
+	"encoding/json"
+	"go-charts/internal/geojson"
+	"go-charts/internal/weather"
+	"strconv"
+	"time"
+)
+
+// apiPirep is a PIREP as returned by the aviationweather.gov Data API in
+// JSON format. Flight level, layer bases and tops are in hundreds of feet.
+type apiPirep struct {
+	ReceiptTime weather.Time   `json:"receiptTime"`
+	ObsTime     weather.Time   `json:"obsTime"`
+	AcType      string         `json:"acType"`
+	Lat         float64        `json:"lat"`
+	Lon         float64        `json:"lon"`
+	FltLvl      weather.Number `json:"fltLvl"`
+	Temp        weather.Number `json:"temp"`
+	Wdir        weather.Number `json:"wdir"`
+	Wspd        weather.Number `json:"wspd"`
+	WxString    string         `json:"wxString"`
+	Visib       weather.Number `json:"visib"`
+	PirepType   string         `json:"pirepType"`
+	RawOb       string         `json:"rawOb"`
+
+	CloudCvg1 string         `json:"cloudCvg1"`
+	CloudBas1 weather.Number `json:"cloudBas1"`
+	CloudTop1 weather.Number `json:"cloudTop1"`
+	CloudCvg2 string         `json:"cloudCvg2"`
+	CloudBas2 weather.Number `json:"cloudBas2"`
+	CloudTop2 weather.Number `json:"cloudTop2"`
+	CloudCvg3 string         `json:"cloudCvg3"`
+	CloudBas3 weather.Number `json:"cloudBas3"`
+	CloudTop3 weather.Number `json:"cloudTop3"`
+
+	IcgBas1  weather.Number `json:"icgBas1"`
+	IcgTop1  weather.Number `json:"icgTop1"`
+	IcgInt1  string         `json:"icgInt1"`
+	IcgType1 string         `json:"icgType1"`
+	IcgBas2  weather.Number `json:"icgBas2"`
+	IcgTop2  weather.Number `json:"icgTop2"`
+	IcgInt2  string         `json:"icgInt2"`
+	IcgType2 string         `json:"icgType2"`
+
+	TbBas1  weather.Number `json:"tbBas1"`
+	TbTop1  weather.Number `json:"tbTop1"`
+	TbInt1  string         `json:"tbInt1"`
+	TbType1 string         `json:"tbType1"`
+	TbFreq1 string         `json:"tbFreq1"`
+	TbBas2  weather.Number `json:"tbBas2"`
+	TbTop2  weather.Number `json:"tbTop2"`
+	TbInt2  string         `json:"tbInt2"`
+	TbType2 string         `json:"tbType2"`
+	TbFreq2 string         `json:"tbFreq2"`
+}
+
+// apiPirepProperties are the properties of a Data API GeoJSON PIREP feature
+type apiPirepProperties struct {
+	ObsTime   weather.Time `json:"obsTime"`
+	AcType    string       `json:"acType"`
+	RawOb     string       `json:"rawOb"`
+	AirepType string       `json:"airepType"`
+}
+
+// decodeJSON maps a Data API JSON response onto a Response
+func decodeJSON(data []byte) (*Response, error) {
+	var list []apiPirep
+	err := weather.DecodeJSONArray(data, &list)
+	if err != nil {
+		return nil, err
+	}
+	r := &Response{}
+	for _, a := range list {
+		p := Pirep{
+			RawText:             a.RawOb,
+			ReceiptTime:         a.ReceiptTime.Time,
+			ObservationTime:     a.ObsTime.Time,
+			AircraftRef:         a.AcType,
+			Latitude:            a.Lat,
+			Longitude:           a.Lon,
+			AltitudeFtMsl:       float64(a.FltLvl) * 100,
+			TempC:               float64(a.Temp),
+			WindDirDegrees:      int32(a.Wdir),
+			WindSpeedKt:         int32(a.Wspd),
+			PirepType:           a.PirepType,
+			WxString:            a.WxString,
+			VisibilityStatuteMi: float64(a.Visib),
+		}
+		p.SkyCondition = appendSky(p.SkyCondition, a.CloudCvg1, a.CloudBas1, a.CloudTop1)
+		p.SkyCondition = appendSky(p.SkyCondition, a.CloudCvg2, a.CloudBas2, a.CloudTop2)
+		p.SkyCondition = appendSky(p.SkyCondition, a.CloudCvg3, a.CloudBas3, a.CloudTop3)
+		p.IcingCondition = appendIcing(p.IcingCondition, a.IcgInt1, a.IcgType1, a.IcgBas1, a.IcgTop1)
+		p.IcingCondition = appendIcing(p.IcingCondition, a.IcgInt2, a.IcgType2, a.IcgBas2, a.IcgTop2)
+		p.TurbulenceCondition = appendTurbulence(p.TurbulenceCondition, a.TbInt1, a.TbType1, a.TbFreq1, a.TbBas1, a.TbTop1)
+		p.TurbulenceCondition = appendTurbulence(p.TurbulenceCondition, a.TbInt2, a.TbType2, a.TbFreq2, a.TbBas2, a.TbTop2)
+		r.Data.Pireps = append(r.Data.Pireps, p)
+	}
+	r.Data.NumResults = int32(len(r.Data.Pireps))
+	return r, nil
+}
+
+// decodeGeoJSON maps a Data API GeoJSON response onto a Response. The
+// layers are decoded from the raw report text of each feature.
+func decodeGeoJSON(data []byte) (*Response, error) {
+	fc, err := geojson.Decode(data)
+	if err != nil {
+		return nil, err
+	}
+	r := &Response{}
+	for _, f := range fc.Features {
+		var props apiPirepProperties
+		err = json.Unmarshal(f.Properties, &props)
+		if err != nil {
+			return nil, err
+		}
+		ref := props.ObsTime.Time
+		if ref.IsZero() {
+			ref = time.Now().UTC()
+		}
+		p, err := Decode(props.RawOb, ref, nil)
+		if err != nil {
+			p = Pirep{RawText: props.RawOb, ReceiptTime: ref}
+		}
+		if !props.ObsTime.IsZero() {
+			p.ObservationTime = props.ObsTime.Time
+		}
+		if props.AcType != "" {
+			p.AircraftRef = props.AcType
+		}
+		if props.AirepType != "" {
+			p.PirepType = props.AirepType
+		}
+		// the feature geometry is authoritative for the location
+		if lon, lat, ok := f.Geometry.Point(); ok {
+			p.Longitude, p.Latitude = lon, lat
+			p.QualityControlFlags.BadLocation = ""
+		}
+		r.Data.Pireps = append(r.Data.Pireps, p)
+	}
+	r.Data.NumResults = int32(len(r.Data.Pireps))
+	return r, nil
+}
+
+// decodeCSV maps the aircraftreports.cache.csv file onto a Response
+func decodeCSV(data []byte) (*Response, error) {
+	records, err := weather.ReadCSV(data, "raw_text")
+	if err != nil {
+		return nil, err
+	}
+	r := &Response{}
+	for _, rec := range records {
+		p := Pirep{
+			RawText:             rec.Get("raw_text"),
+			ReceiptTime:         rec.Time("receipt_time"),
+			ObservationTime:     rec.Time("observation_time"),
+			AircraftRef:         rec.Get("aircraft_ref"),
+			Latitude:            rec.Float("latitude"),
+			Longitude:           rec.Float("longitude"),
+			AltitudeFtMsl:       rec.Float("altitude_ft_msl"),
+			TempC:               rec.Float("temp_c"),
+			WindDirDegrees:      int32(rec.Float("wind_dir_degrees")),
+			WindSpeedKt:         int32(rec.Float("wind_speed_kt")),
+			PirepType:           rec.Get("report_type"),
+			WxString:            rec.Get("wx_string"),
+			VisibilityStatuteMi: rec.Float("visibility_statute_mi"),
+		}
+		covers, bases, tops := rec.All("sky_cover"), rec.All("cloud_base_ft_msl"), rec.All("cloud_top_ft_msl")
+		for i, cover := range covers {
+			if cover == "" {
+				continue
+			}
+			p.SkyCondition = append(p.SkyCondition, SkyCondition{
+				SkyCover:       cover,
+				CloudBaseFtMsl: index(bases, i),
+				CloudTopFtMsl:  index(tops, i),
+			})
+		}
+		intensities := rec.All("turbulence_intensity")
+		for i, intensity := range intensities {
+			if intensity == "" {
+				continue
+			}
+			p.TurbulenceCondition = append(p.TurbulenceCondition, TurbulenceCondition{
+				TurbulenceType:      index(rec.All("turbulence_type"), i),
+				TurbulenceIntensity: intensity,
+				TurbulenceBaseFtMsl: index(rec.All("turbulence_base_ft_msl"), i),
+				TurbulenceTopFtMsl:  index(rec.All("turbulence_top_ft_msl"), i),
+				TurbulenceFreq:      index(rec.All("turbulence_freq"), i),
+			})
+		}
+		intensities = rec.All("icing_intensity")
+		for i, intensity := range intensities {
+			if intensity == "" {
+				continue
+			}
+			p.IcingCondition = append(p.IcingCondition, IcingCondition{
+				IcingType:      index(rec.All("icing_type"), i),
+				IcingIntensity: intensity,
+				IcingBaseFtMsl: index(rec.All("icing_base_ft_msl"), i),
+				IcingTopFtMsl:  index(rec.All("icing_top_ft_msl"), i),
+			})
+		}
+		r.Data.Pireps = append(r.Data.Pireps, p)
+	}
+	r.Data.NumResults = int32(len(r.Data.Pireps))
+	return r, nil
+}
+
+func appendSky(layers []SkyCondition, cover string, base, top weather.Number) []SkyCondition {
+	if cover == "" {
+		return layers
+	}
+	return append(layers, SkyCondition{
+		SkyCover:       cover,
+		CloudBaseFtMsl: hundredsOfFeet(base),
+		CloudTopFtMsl:  hundredsOfFeet(top),
+	})
+}
+
+func appendIcing(conds []IcingCondition, intensity, kind string, base, top weather.Number) []IcingCondition {
+	if intensity == "" {
+		return conds
+	}
+	return append(conds, IcingCondition{
+		IcingIntensity: intensity,
+		IcingType:      kind,
+		IcingBaseFtMsl: hundredsOfFeet(base),
+		IcingTopFtMsl:  hundredsOfFeet(top),
+	})
+}
+
+func appendTurbulence(conds []TurbulenceCondition, intensity, kind, freq string, base, top weather.Number) []TurbulenceCondition {
+	if intensity == "" {
+		return conds
+	}
+	return append(conds, TurbulenceCondition{
+		TurbulenceIntensity: intensity,
+		TurbulenceType:      kind,
+		TurbulenceFreq:      freq,
+		TurbulenceBaseFtMsl: hundredsOfFeet(base),
+		TurbulenceTopFtMsl:  hundredsOfFeet(top),
+	})
+}
+
+func hundredsOfFeet(n weather.Number) string {
+	if n == 0 {
+		return ""
+	}
+	return strconv.Itoa(int(n) * 100)
+}
+
+func index(values []string, i int) string {
+	if i < len(values) {
+		return values[i]
+	}
+	return ""
+}
+
+// ToGeoJson encodes the PIREPs as a GeoJSON FeatureCollection of points.
+// Reports whose location could not be resolved get a null geometry.
+func (r *Response) ToGeoJson() (string, error) {
+	fc := geojson.NewFeatureCollection()
+	for _, p := range r.Data.Pireps {
+		var g *geojson.Geometry
+		if p.QualityControlFlags.BadLocation != "TRUE" {
+			g = geojson.NewPoint(p.Longitude, p.Latitude)
+		}
+		f, err := geojson.NewFeature(nil, g, p)
+		if err != nil {
+			return "", err
+		}
+		fc.Features = append(fc.Features, f)
+	}
+	return fc.Encode()
+}
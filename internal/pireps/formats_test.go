@@ -0,0 +1,111 @@
+package pireps
+
+import (
+	"fmt"
+	"go-charts/internal/weather"
+	"testing"
+	"time"
+)
+
+func TestSourceDecodeFormats(t *testing.T) {
+	type summary struct {
+		observed   time.Time
+		aircraft   string
+		lat, lon   float64
+		altitude   float64
+		sky        string
+		turbulence string
+	}
+	// reports arrive oldest first and are returned newest first
+	want := []summary{
+		{
+			observed: time.Date(2026, 10, 18, 17, 55, 0, 0, time.UTC),
+			aircraft: "C172",
+			lat:      39.87,
+			lon:      -104.25,
+			altitude: 8000,
+			sky:      "BKN 3000-5000",
+		},
+		{
+			observed:   time.Date(2026, 10, 18, 17, 30, 0, 0, time.UTC),
+			aircraft:   "B737",
+			lat:        38.81,
+			lon:        -104.70,
+			altitude:   24000,
+			turbulence: "MOD 22000-26000",
+		},
+	}
+	const rawC172 = "DEN UA /OV DEN090020/TM 1755/FL080/TP C172/SK BKN030-TOP050"
+	const rawB737 = "COS UA /OV COS/TM 1730/FL240/TP B737/TB MOD 220-260"
+	tests := []struct {
+		name   string
+		format string
+		data   string
+	}{
+		{"json", weather.FormatJSON, `[` +
+			`{"obsTime":1792344600,"acType":"B737","lat":38.81,"lon":-104.70,"fltLvl":240,"rawOb":"` + rawB737 + `",` +
+			`"tbInt1":"MOD","tbBas1":220,"tbTop1":260},` +
+			`{"obsTime":1792346100,"acType":"C172","lat":39.87,"lon":-104.25,"fltLvl":"080","rawOb":"` + rawC172 + `",` +
+			`"cloudCvg1":"BKN","cloudBas1":30,"cloudTop1":50}]`},
+		{"geojson", weather.FormatGeoJSON, `{"type":"FeatureCollection","features":[` +
+			`{"type":"Feature","geometry":{"type":"Point","coordinates":[-104.70,38.81]},` +
+			`"properties":{"obsTime":"2026-10-18T17:30:00Z","acType":"B737","rawOb":"` + rawB737 + `"}},` +
+			`{"type":"Feature","geometry":{"type":"Point","coordinates":[-104.25,39.87]},` +
+			`"properties":{"obsTime":"2026-10-18T17:55:00Z","acType":"C172","rawOb":"` + rawC172 + `"}}]}`},
+		{"csv", weather.FormatCSV, "No errors\nNo warnings\n5 ms\ndata source=aircraftreports\n2 results\n" +
+			"receipt_time,observation_time,aircraft_ref,latitude,longitude,altitude_ft_msl," +
+			"sky_cover,cloud_base_ft_msl,cloud_top_ft_msl,turbulence_type,turbulence_intensity," +
+			"turbulence_base_ft_msl,turbulence_top_ft_msl,turbulence_freq,raw_text\n" +
+			"2026-10-18T17:31:00Z,2026-10-18T17:30:00Z,B737,38.81,-104.70,24000,,,,,MOD,22000,26000,,\"" + rawB737 + "\"\n" +
+			"2026-10-18T17:56:00Z,2026-10-18T17:55:00Z,C172,39.87,-104.25,8000,BKN,3000,5000,,,,,,\"" + rawC172 + "\"\n"},
+	}
+	for _, tt := range tests {
+		p, err := NewSource("", tt.format).Decode([]byte(tt.data))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		r := p.(*Response)
+		if len(r.Data.Pireps) != len(want) || int(r.Data.NumResults) != len(want) {
+			t.Errorf("%s: %d reports, NumResults %d, want %d", tt.name, len(r.Data.Pireps), r.Data.NumResults, len(want))
+			continue
+		}
+		for i, w := range want {
+			rep := r.Data.Pireps[i]
+			got := summary{
+				observed: rep.ObservationTime,
+				aircraft: rep.AircraftRef,
+				lat:      rep.Latitude,
+				lon:      rep.Longitude,
+				altitude: rep.AltitudeFtMsl,
+			}
+			for _, sc := range rep.SkyCondition {
+				got.sky += fmt.Sprintf("%s %s-%s", sc.SkyCover, sc.CloudBaseFtMsl, sc.CloudTopFtMsl)
+			}
+			for _, tc := range rep.TurbulenceCondition {
+				got.turbulence += fmt.Sprintf("%s %s-%s", tc.TurbulenceIntensity, tc.TurbulenceBaseFtMsl, tc.TurbulenceTopFtMsl)
+			}
+			if got != w {
+				t.Errorf("%s report %d: got %+v, want %+v", tt.name, i, got, w)
+			}
+		}
+	}
+}
+
+func TestSourceDecodeErrors(t *testing.T) {
+	tests := []struct {
+		format string
+		data   string
+	}{
+		{weather.FormatJSON, `{"data":[`},
+		{weather.FormatGeoJSON, `[]`},
+		{weather.FormatCSV, "aircraft_ref\nC172\n"},
+		{"kml", `<kml/>`},
+	}
+	for _, tt := range tests {
+		_, err := NewSource("", tt.format).Decode([]byte(tt.data))
+		if err == nil {
+			t.Errorf("%s %q: no error", tt.format, tt.data)
+		}
+	}
+}
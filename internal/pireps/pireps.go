@@ -1,14 +1,9 @@
 package pireps
 
 import (
-	"log"
-	"fmt"
-	"net"
-	"net/http"
 	"encoding/json"
 	"encoding/xml"
-	"io/ioutil"
-	"os"
+	"go-charts/internal/weather"
 	"time"
 )
 
@@ -89,58 +84,12 @@ type Pirep struct {
 	WxString            string `xml:"wx_string"`
 	IcingCondition      []IcingCondition
 	VisibilityStatuteMi float64 `xml:"visibility_statute_mi"`
+	Remarks             string  `xml:"-"`
 }
 
-// SaveAsJSONFile downloads xml file from ADDS weather server and converts to a JSON string
-func (r *Response) SaveAsJSONFile(url string) (err error) {
-
-	t := &http.Transport{
-		Dial: (&net.Dialer{
-				Timeout: 60 * time.Second,
-				KeepAlive: 30 * time.Second,
-		}).Dial,
-		TLSHandshakeTimeout: 60 * time.Second,
-	}
-	c := &http.Client{
-		Transport: t,
-	}
-	resp, err := c.Get(url)
-	if err != nil {
-		return err
-	} else if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("Error status code received: %v", resp.StatusCode)
-	}
-
-	defer resp.Body.Close()
-	data, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return err
-	}
-	
-	err = xml.Unmarshal(data, &r)
-	if err != nil {
-		return err
-	}
-
-	var s string
-	s, err = r.ToJson()
-	if err != nil {
-		return err
-	}
-
-	jsonfile := "./workfiles/pireps.json"
-	err = os.Remove(jsonfile)
-	if err != nil {
-		log.Println(err)
-	}
-
-	nstr := []byte("{ \"pireps\": " + s + "}")
-	err = os.WriteFile(jsonfile, nstr, 0644)
-	if (err != nil) {
-		return err
-	}
-	
-	return nil
+// Count returns the number of reports in the response
+func (r *Response) Count() int {
+	return len(r.Data.Pireps)
 }
 
 func (r *Response) ToRawTextOnly() (s []string) {
@@ -159,6 +108,20 @@ func (r *Response) ToJson() (s string, err error) {
 	return
 }
 
+// FromJson replaces the reports with those encoded by ToJson
+func (r *Response) FromJson(data []byte) error {
+	return json.Unmarshal(data, &r.Data.Pireps)
+}
+
+// Records returns the reports keyed by observation time and raw text
+func (r *Response) Records() []weather.Record {
+	records := make([]weather.Record, 0, len(r.Data.Pireps))
+	for _, p := range r.Data.Pireps {
+		records = append(records, weather.Record{Key: p.ObservationTime.Format(time.RFC3339Nano) + " " + p.RawText, Data: p})
+	}
+	return records
+}
+
 func (r *Response) ToJsonIndented() (s string, err error) {
 	bytes, err := json.MarshalIndent(r.Data.Pireps, "", "  ")
 	if err != nil {
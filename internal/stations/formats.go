@@ -0,0 +1,76 @@
+package stations
+
+import (
+	"go-charts/internal/geojson"
+	"go-charts/internal/weather"
+	"strings"
+)
+
+// apiStation is a station in the Data API and stations cache JSON
+type apiStation struct {
+	Id       string         `json:"id"`
+	IcaoId   string         `json:"icaoId"`
+	FaaId    string         `json:"faaId"`
+	WmoId    string         `json:"wmoId"`
+	Site     string         `json:"site"`
+	Lat      weather.Number `json:"lat"`
+	Lon      weather.Number `json:"lon"`
+	Elev     weather.Number `json:"elev"`
+	State    string         `json:"state"`
+	Country  string         `json:"country"`
+	SiteType []string       `json:"siteType"`
+}
+
+// decodeJSON maps a Data API JSON response onto a Response. Stations are
+// keyed by ICAO identifier, falling back to the FAA identifier for
+// stations that have none.
+func decodeJSON(data []byte) (*Response, error) {
+	var list []apiStation
+	err := weather.DecodeJSONArray(data, &list)
+	if err != nil {
+		return nil, err
+	}
+	r := &Response{}
+	for _, a := range list {
+		id := a.IcaoId
+		if id == "" {
+			id = a.Id
+		}
+		if id == "" {
+			id = a.FaaId
+		}
+		if id == "" {
+			continue
+		}
+		var types SiteTypes
+		for _, t := range a.SiteType {
+			types = append(types, normalizeSiteType(t))
+		}
+		r.Data.Stations = append(r.Data.Stations, Station{
+			StationId:  strings.ToUpper(id),
+			WmoId:      a.WmoId,
+			Latitude:   float64(a.Lat),
+			Longitude:  float64(a.Lon),
+			ElevationM: float64(a.Elev),
+			Name:       strings.TrimSpace(a.Site),
+			State:      a.State,
+			Country:    a.Country,
+			SiteTypes:  types,
+		})
+	}
+	r.Data.NumResults = int32(len(r.Data.Stations))
+	return r, nil
+}
+
+// ToGeoJson encodes the stations as a FeatureCollection of points
+func (r *Response) ToGeoJson() (string, error) {
+	fc := geojson.NewFeatureCollection()
+	for _, s := range r.Data.Stations {
+		f, err := geojson.NewFeature(s.StationId, geojson.NewPoint(s.Longitude, s.Latitude), s)
+		if err != nil {
+			return "", err
+		}
+		fc.Features = append(fc.Features, f)
+	}
+	return fc.Encode()
+}
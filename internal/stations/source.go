@@ -0,0 +1,58 @@
+package stations
+
+import (
+	"encoding/xml"
+	"fmt"
+	"go-charts/internal/weather"
+)
+
+// Source downloads and decodes the station list
+type Source struct {
+	url    string
+	format string
+}
+
+// NewSource returns a station list source reading from url. format
+// selects the decoder: xml (ADDS stations) or json (the Data API
+// stationinfo endpoint or the compressed stations cache). An empty format
+// means json.
+func NewSource(url, format string) *Source {
+	if format == "" {
+		format = weather.FormatJSON
+	}
+	return &Source{url: url, format: format}
+}
+
+// Name returns the product name used for messages and files
+func (s *Source) Name() string {
+	return "stations"
+}
+
+// URL returns the download location
+func (s *Source) URL() string {
+	return s.url
+}
+
+// Empty returns a Response with no reports
+func (s *Source) Empty() weather.Product {
+	return &Response{}
+}
+
+// Decode converts downloaded data in the configured format into a Response
+func (s *Source) Decode(data []byte) (weather.Product, error) {
+	var r *Response
+	var err error
+	switch s.format {
+	case weather.FormatXML:
+		r = &Response{}
+		err = xml.Unmarshal(data, r)
+	case weather.FormatJSON:
+		r, err = decodeJSON(data)
+	default:
+		err = fmt.Errorf("unsupported %s format %q", s.Name(), s.format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
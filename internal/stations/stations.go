@@ -0,0 +1,153 @@
+package stations
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"go-charts/internal/weather"
+	"strings"
+)
+
+// Site types a station reports
+const (
+	SiteMETAR = "METAR"
+	SiteTAF   = "TAF"
+	SiteRAOB  = "RAOB"
+)
+
+type Response struct {
+	XMLName      xml.Name   `xml:"response" json:"-"`
+	Version      string     `xml:"version,attr"`
+	RequestIndex int32      `xml:"request_index"`
+	Errors       []string   `xml:"errors>error"`
+	Warnings     []string   `xml:"warnings>warning"`
+	TimeTakenMs  int32      `xml:"time_taken_ms"`
+	DataSource   DataSource `xml:"data_source"`
+	Request      Request    `xml:"request"`
+	Data         Data       `xml:"data"`
+}
+
+type Request struct {
+	XMLName xml.Name `xml:"request" json:"-"`
+	Type    string   `xml:"type,attr"`
+}
+
+type DataSource struct {
+	XMLName xml.Name `xml:"data_source" json:"-"`
+	Name    string   `xml:"name,attr"`
+}
+
+type Data struct {
+	XMLName    xml.Name  `xml:"data" json:"-"`
+	NumResults int32     `xml:"num_results,attr"`
+	Stations   []Station `xml:"Station"`
+}
+
+// SiteTypes is the list of products a station reports. In ADDS XML each
+// type is an empty element, such as <site_type><METAR/><TAF/></site_type>.
+type SiteTypes []string
+
+// UnmarshalXML implements xml.Unmarshaler
+func (st *SiteTypes) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var v struct {
+		Types []struct {
+			XMLName xml.Name
+		} `xml:",any"`
+	}
+	err := d.DecodeElement(&v, &start)
+	if err != nil {
+		return err
+	}
+	for _, t := range v.Types {
+		*st = append(*st, normalizeSiteType(t.XMLName.Local))
+	}
+	return nil
+}
+
+// normalizeSiteType maps the ADDS rawinsonde element to RAOB so every
+// format reports the same names
+func normalizeSiteType(s string) string {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if s == "RAWINSONDE" {
+		return SiteRAOB
+	}
+	return s
+}
+
+type Station struct {
+	XMLName    xml.Name  `xml:"Station" json:"-"`
+	StationId  string    `xml:"station_id"`
+	WmoId      string    `xml:"wmo_id"`
+	Latitude   float64   `xml:"latitude"`
+	Longitude  float64   `xml:"longitude"`
+	ElevationM float64   `xml:"elevation_m"`
+	Name       string    `xml:"site"`
+	State      string    `xml:"state"`
+	Country    string    `xml:"country"`
+	SiteTypes  SiteTypes `xml:"site_type"`
+}
+
+// Info is the part of a station that is joined to weather reports, which
+// already carry their own position and elevation
+type Info struct {
+	Name      string
+	State     string   `json:",omitempty"`
+	Country   string   `json:",omitempty"`
+	SiteTypes []string `json:",omitempty"`
+}
+
+// Info returns the station details joined to its reports
+func (s *Station) Info() *Info {
+	return &Info{
+		Name:      s.Name,
+		State:     s.State,
+		Country:   s.Country,
+		SiteTypes: s.SiteTypes,
+	}
+}
+
+// Has reports whether the station reports the given site type
+func (s *Station) Has(siteType string) bool {
+	for _, t := range s.SiteTypes {
+		if t == siteType {
+			return true
+		}
+	}
+	return false
+}
+
+// Count returns the number of stations in the response
+func (r *Response) Count() int {
+	return len(r.Data.Stations)
+}
+
+func (r *Response) ToJson() (s string, err error) {
+	bytes, err := json.Marshal(r.Data.Stations)
+	if err != nil {
+		return "", err
+	}
+	s = string(bytes)
+	return
+}
+
+// FromJson replaces the stations with those encoded by ToJson
+func (r *Response) FromJson(data []byte) error {
+	return json.Unmarshal(data, &r.Data.Stations)
+}
+
+// Records returns the stations keyed by identifier
+func (r *Response) Records() []weather.Record {
+	records := make([]weather.Record, 0, len(r.Data.Stations))
+	for _, s := range r.Data.Stations {
+		records = append(records, weather.Record{Key: s.StationId, Data: s})
+	}
+	return records
+}
+
+func (r *Response) ToJsonIndented() (s string, err error) {
+	bytes, err := json.MarshalIndent(r.Data.Stations, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	s = string(bytes)
+	return
+}
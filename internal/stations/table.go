@@ -0,0 +1,204 @@
+package stations
+
+import (
+	"database/sql"
+	"strings"
+	"sync"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const schema = `CREATE TABLE IF NOT EXISTS stations (
+	station_id  TEXT PRIMARY KEY,
+	wmo_id      TEXT,
+	name        TEXT NOT NULL,
+	state       TEXT,
+	country     TEXT,
+	elevation_m REAL,
+	latitude    REAL,
+	longitude   REAL,
+	site_types  TEXT
+);
+CREATE TABLE IF NOT EXISTS stations_updated (
+	updated TEXT NOT NULL
+);`
+
+// Table is the station list, kept in a SQLite table so it survives a
+// restart and cached in memory for joining to weather reports
+type Table struct {
+	db *sql.DB
+
+	mu      sync.RWMutex
+	byId    map[string]Station
+	updated time.Time
+}
+
+// Open opens or creates the station database and loads it into memory
+func Open(path string) (*Table, error) {
+	db, err := sql.Open("sqlite3", "file:"+path+"?mode=rwc&_busy_timeout=5000")
+	if err != nil {
+		return nil, err
+	}
+	_, err = db.Exec(schema)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	t := &Table{db: db, byId: make(map[string]Station)}
+	err = t.load()
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return t, nil
+}
+
+// Close closes the database
+func (t *Table) Close() error {
+	return t.db.Close()
+}
+
+func (t *Table) load() error {
+	rows, err := t.db.Query(`SELECT station_id, wmo_id, name, state, country,
+		elevation_m, latitude, longitude, site_types FROM stations`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	byId := make(map[string]Station)
+	for rows.Next() {
+		var s Station
+		var types string
+		err = rows.Scan(&s.StationId, &s.WmoId, &s.Name, &s.State, &s.Country,
+			&s.ElevationM, &s.Latitude, &s.Longitude, &types)
+		if err != nil {
+			return err
+		}
+		if types != "" {
+			s.SiteTypes = strings.Split(types, ",")
+		}
+		byId[s.StationId] = s
+	}
+	err = rows.Err()
+	if err != nil {
+		return err
+	}
+
+	var updated time.Time
+	var ts string
+	err = t.db.QueryRow("SELECT updated FROM stations_updated").Scan(&ts)
+	if err == nil {
+		updated, _ = time.Parse(time.RFC3339, ts)
+	} else if err != sql.ErrNoRows {
+		return err
+	}
+
+	t.mu.Lock()
+	t.byId = byId
+	t.updated = updated
+	t.mu.Unlock()
+	return nil
+}
+
+// Replace swaps the stored station list for a freshly downloaded one
+func (t *Table) Replace(list []Station) error {
+	tx, err := t.db.Begin()
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("DELETE FROM stations")
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	stmt, err := tx.Prepare(`INSERT OR REPLACE INTO stations (station_id, wmo_id, name, state, country,
+		elevation_m, latitude, longitude, site_types) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+
+	byId := make(map[string]Station, len(list))
+	for _, s := range list {
+		s.StationId = strings.ToUpper(strings.TrimSpace(s.StationId))
+		if s.StationId == "" {
+			continue
+		}
+		_, err = stmt.Exec(s.StationId, s.WmoId, s.Name, s.State, s.Country,
+			s.ElevationM, s.Latitude, s.Longitude, strings.Join(s.SiteTypes, ","))
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		byId[s.StationId] = s
+	}
+	updated := time.Now().UTC()
+	_, err = tx.Exec("DELETE FROM stations_updated")
+	if err == nil {
+		_, err = tx.Exec("INSERT INTO stations_updated (updated) VALUES (?)", updated.Format(time.RFC3339))
+	}
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	t.byId = byId
+	t.updated = updated
+	t.mu.Unlock()
+	return nil
+}
+
+// Lookup returns the station with the given identifier. It is safe to
+// call on a nil Table, which knows no stations.
+func (t *Table) Lookup(id string) (Station, bool) {
+	if t == nil {
+		return Station{}, false
+	}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	s, ok := t.byId[strings.ToUpper(id)]
+	return s, ok
+}
+
+// Stations returns every station in no particular order
+func (t *Table) Stations() []Station {
+	if t == nil {
+		return nil
+	}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	list := make([]Station, 0, len(t.byId))
+	for _, s := range t.byId {
+		list = append(list, s)
+	}
+	return list
+}
+
+// Count returns the number of stations
+func (t *Table) Count() int {
+	if t == nil {
+		return 0
+	}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return len(t.byId)
+}
+
+// Updated returns when the station list was last replaced, or a zero time
+// if it never has been
+func (t *Table) Updated() time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.updated
+}
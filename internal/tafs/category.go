@@ -0,0 +1,73 @@
+package tafs
+
+import "go-charts/internal/flightrules"
+
+// Ceiling returns the lowest BKN, OVC or OVX base, or the vertical
+// visibility, in feet AGL. It returns flightrules.NoCeiling when the
+// period forecasts the sky without a ceiling and flightrules.Unknown when
+// the period does not mention the sky.
+func (f *Forecast) Ceiling() int {
+	ceiling := flightrules.Unknown
+	if len(f.SkyCondition) > 0 {
+		ceiling = flightrules.NoCeiling
+	}
+	for _, sc := range f.SkyCondition {
+		// ADDS reports an indefinite ceiling as OVX at 0 with the height in VertVisFt
+		if sc.SkyCover == "OVX" && f.VertVisFt > 0 {
+			continue
+		}
+		if flightrules.IsCeiling(sc.SkyCover) && int(sc.CloudBaseFtAGL) < ceiling {
+			ceiling = int(sc.CloudBaseFtAGL)
+		}
+	}
+	if f.VertVisFt > 0 && int(f.VertVisFt) < ceiling {
+		ceiling = int(f.VertVisFt)
+	}
+	return ceiling
+}
+
+// visibility returns the forecast visibility or flightrules.Unknown
+func (f *Forecast) visibility() float64 {
+	if f.VisibilityStatuteMi > 0 {
+		return f.VisibilityStatuteMi
+	}
+	return flightrules.Unknown
+}
+
+// isPrevailing reports whether a period changes the prevailing conditions.
+// TEMPO and PROB periods are temporary and leave them in place.
+func (f *Forecast) isPrevailing() bool {
+	switch f.ChangeIndicator {
+	case "TEMPO", "PROB":
+		return false
+	}
+	return f.Probability == 0
+}
+
+// FillFlightCategories sets the flight category of every forecast period.
+// An element a period does not forecast is taken from the prevailing
+// conditions, which the initial, FM and BECMG periods establish.
+func FillFlightCategories(t *Taf) {
+	ceiling, vis := flightrules.Unknown, float64(flightrules.Unknown)
+	for i := range t.Forecast {
+		f := &t.Forecast[i]
+		c, v := f.Ceiling(), f.visibility()
+		if c == flightrules.Unknown {
+			c = ceiling
+		}
+		if v == flightrules.Unknown {
+			v = vis
+		}
+		f.FlightCategory = flightrules.Category(c, v)
+		if f.isPrevailing() {
+			ceiling, vis = c, v
+		}
+	}
+}
+
+// fillFlightCategories categorizes the periods of every TAF
+func (r *Response) fillFlightCategories() {
+	for i := range r.Data.Tafs {
+		FillFlightCategories(&r.Data.Tafs[i])
+	}
+}
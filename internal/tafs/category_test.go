@@ -0,0 +1,56 @@
+package tafs
+
+import "testing"
+
+func TestFillFlightCategories(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want []string
+	}{
+		{
+			"TAF KDEN 181120Z 1812/1918 27012KT P6SM SCT080 FM181800 31020G30KT 4SM BKN020 FM190300 VRB05KT 1/2SM FG OVC002",
+			[]string{"VFR", "MVFR", "LIFR"},
+		},
+		{
+			// a change group that only forecasts visibility keeps the prevailing ceiling
+			"TAF KBOS 181120Z 1812/1912 18008KT P6SM OVC008 TEMPO 1818/1822 2SM -RA",
+			[]string{"IFR", "IFR"},
+		},
+		{
+			// and one that only forecasts the sky keeps the prevailing visibility
+			"TAF KBOS 181120Z 1812/1912 18008KT 4SM BR SCT030 BECMG 1820/1822 OVC015",
+			[]string{"MVFR", "MVFR"},
+		},
+		{
+			// TEMPO and PROB groups don't change what later groups inherit
+			"TAF KMIA 181120Z 1812/1912 09012KT P6SM SCT025 TEMPO 1818/1822 OVC004 PROB30 1900/1904 3SM",
+			[]string{"VFR", "LIFR", "MVFR"},
+		},
+		{
+			// a BECMG group does
+			"TAF KMIA 181120Z 1812/1912 09012KT P6SM SCT025 BECMG 1818/1820 OVC009 PROB30 1900/1904 5SM",
+			[]string{"VFR", "IFR", "IFR"},
+		},
+		{
+			"TAF KDEN 181120Z 1812/1918 27012KT",
+			[]string{""},
+		},
+	}
+	for _, tt := range tests {
+		taf, err := Decode(tt.raw, testRef)
+		if err != nil {
+			t.Errorf("%s: %v", tt.raw, err)
+			continue
+		}
+		FillFlightCategories(&taf)
+		if len(taf.Forecast) != len(tt.want) {
+			t.Errorf("%s: %d periods, want %d", tt.raw, len(taf.Forecast), len(tt.want))
+			continue
+		}
+		for i, want := range tt.want {
+			if got := taf.Forecast[i].FlightCategory; got != want {
+				t.Errorf("%s: period %d category %q, want %q", tt.raw, i, got, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package tafs
+
+import (
+	"fmt"
+	"go-charts/internal/flightrules"
+	"time"
+)
+
+// Conditions are the forecast conditions in effect at one time
+type Conditions struct {
+	StationId    string
+	Time         time.Time
+	Prevailing   Forecast
+	Alternatives []Forecast
+	WorstCase    Forecast
+}
+
+// ConditionsAt returns the conditions a TAF forecasts for time t. The
+// prevailing conditions start from the initial period and apply every FM
+// group that has begun and every BECMG group whose transition is complete.
+// A BECMG group still in transition, and every TEMPO or PROB group in
+// effect, is returned as an alternative overlaid on the prevailing
+// conditions. WorstCase is the most restrictive of all of them.
+func ConditionsAt(taf Taf, t time.Time) (Conditions, error) {
+	t = t.UTC()
+	c := Conditions{StationId: taf.StationId, Time: t, Alternatives: []Forecast{}}
+	if len(taf.Forecast) == 0 {
+		return c, fmt.Errorf("TAF for %s has no forecast periods", taf.StationId)
+	}
+	if !taf.ValidTimeFrom.IsZero() && (t.Before(taf.ValidTimeFrom) || !t.Before(taf.ValidTimeTo)) {
+		return c, fmt.Errorf("%s is outside the TAF valid period %s to %s", t.Format(time.RFC3339),
+			taf.ValidTimeFrom.Format(time.RFC3339), taf.ValidTimeTo.Format(time.RFC3339))
+	}
+
+	var prevailing Forecast
+	var pending []Forecast
+	for i, f := range taf.Forecast {
+		if f.FcstTimeFrom.After(t) {
+			continue
+		}
+		switch {
+		case i == 0 || f.ChangeIndicator == "FM" || f.ChangeIndicator == "":
+			// FM replaces the forecast; only a missing visibility or sky
+			// is carried over, as FillFlightCategories does
+			prevailing = overlay(Forecast{
+				VisibilityStatuteMi: prevailing.VisibilityStatuteMi,
+				SkyCondition:        prevailing.SkyCondition,
+				VertVisFt:           prevailing.VertVisFt,
+			}, f)
+			pending = nil
+		case f.ChangeIndicator == "BECMG":
+			if f.TimeBecoming.IsZero() || !t.Before(f.TimeBecoming) {
+				prevailing = overlay(prevailing, f)
+			} else {
+				pending = append(pending, f)
+			}
+		case f.isPrevailing():
+			prevailing = overlay(prevailing, f)
+		default:
+			if t.Before(f.FcstTimeTo) {
+				pending = append(pending, f)
+			}
+		}
+	}
+	prevailing.FlightCategory = category(prevailing)
+	c.Prevailing = prevailing
+	c.WorstCase = prevailing
+	for _, f := range pending {
+		alt := overlay(prevailing, f)
+		alt.FcstTimeFrom, alt.FcstTimeTo = f.FcstTimeFrom, f.FcstTimeTo
+		alt.FlightCategory = category(alt)
+		c.Alternatives = append(c.Alternatives, alt)
+		if worse(alt, c.WorstCase) {
+			c.WorstCase = alt
+		}
+	}
+	return c, nil
+}
+
+// overlay applies the elements a change group forecasts on top of the base
+// conditions. Elements the group does not mention are kept.
+func overlay(base, change Forecast) Forecast {
+	f := base
+	f.FcstTimeFrom = change.FcstTimeFrom
+	f.FcstTimeTo = change.FcstTimeTo
+	f.ChangeIndicator = change.ChangeIndicator
+	f.TimeBecoming = change.TimeBecoming
+	f.Probability = change.Probability
+	if change.WindReported {
+		f.WindDirDegrees = change.WindDirDegrees
+		f.WindSpeedKt = change.WindSpeedKt
+		f.WindGustKt = change.WindGustKt
+	}
+	if change.WindShearHgtFtAgl != 0 {
+		f.WindShearHgtFtAgl = change.WindShearHgtFtAgl
+		f.WindShearDirDegrees = change.WindShearDirDegrees
+		f.WindShearSpeedKt = change.WindShearSpeedKt
+	}
+	if change.VisibilityStatuteMi > 0 {
+		f.VisibilityStatuteMi = change.VisibilityStatuteMi
+	}
+	if change.AltimInHg != 0 {
+		f.AltimInHg = change.AltimInHg
+	}
+	if len(change.SkyCondition) > 0 {
+		f.SkyCondition = change.SkyCondition
+		f.VertVisFt = change.VertVisFt
+	}
+	if change.WxString == "NSW" {
+		f.WxString = ""
+	} else if change.WxString != "" {
+		f.WxString = change.WxString
+	}
+	if len(change.TurbulenceCondition) > 0 {
+		f.TurbulenceCondition = change.TurbulenceCondition
+	}
+	if len(change.IcingCondition) > 0 {
+		f.IcingCondition = change.IcingCondition
+	}
+	if change.NotDecoded != "" {
+		f.NotDecoded = change.NotDecoded
+	}
+	return f
+}
+
+func category(f Forecast) string {
+	return flightrules.Category(f.Ceiling(), f.visibility())
+}
+
+// worse reports whether a is more restrictive than b, comparing flight
+// category first and then the strongest wind
+func worse(a, b Forecast) bool {
+	if a.FlightCategory != b.FlightCategory {
+		return flightrules.Worst(b.FlightCategory, a.FlightCategory) == a.FlightCategory
+	}
+	return maxWind(a) > maxWind(b)
+}
+
+func maxWind(f Forecast) int32 {
+	if f.WindGustKt > f.WindSpeedKt {
+		return f.WindGustKt
+	}
+	return f.WindSpeedKt
+}
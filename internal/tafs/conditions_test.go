@@ -0,0 +1,150 @@
+package tafs
+
+import (
+	"testing"
+	"time"
+)
+
+func TestConditionsAt(t *testing.T) {
+	type forecast struct {
+		windKt   int32
+		visSM    float64
+		wx       string
+		category string
+	}
+	tests := []struct {
+		name         string
+		raw          string
+		at           time.Time
+		prevailing   forecast
+		alternatives []forecast
+		worst        forecast
+	}{
+		{
+			name:       "initial period",
+			raw:        "TAF KDEN 181120Z 1812/1918 27012KT P6SM SCT080 FM181800 31020G30KT 4SM BKN020",
+			at:         utc(18, 14),
+			prevailing: forecast{12, 6.21, "", "VFR"},
+			worst:      forecast{12, 6.21, "", "VFR"},
+		},
+		{
+			name:       "FM replaces the forecast",
+			raw:        "TAF KDEN 181120Z 1812/1918 27012KT P6SM SCT080 FM181800 31020G30KT 4SM -SN BKN020",
+			at:         utc(18, 18),
+			prevailing: forecast{20, 4, "-SN", "MVFR"},
+			worst:      forecast{20, 4, "-SN", "MVFR"},
+		},
+		{
+			name:         "BECMG in transition is an alternative",
+			raw:          "TAF KBOS 181120Z 1812/1912 18008KT P6SM BKN040 BECMG 1820/1822 22015KT 3SM -RA OVC020",
+			at:           utc(18, 21),
+			prevailing:   forecast{8, 6.21, "", "VFR"},
+			alternatives: []forecast{{15, 3, "-RA", "MVFR"}},
+			worst:        forecast{15, 3, "-RA", "MVFR"},
+		},
+		{
+			name:       "BECMG complete is prevailing",
+			raw:        "TAF KBOS 181120Z 1812/1912 18008KT P6SM BKN040 BECMG 1820/1822 22015KT 3SM -RA OVC020",
+			at:         utc(18, 22),
+			prevailing: forecast{15, 3, "-RA", "MVFR"},
+			worst:      forecast{15, 3, "-RA", "MVFR"},
+		},
+		{
+			name:       "BECMG keeps what it does not mention",
+			raw:        "TAF KBOS 181120Z 1812/1912 18008KT 4SM BR BKN040 BECMG 1820/1822 OVC008",
+			at:         utc(19, 0),
+			prevailing: forecast{8, 4, "BR", "IFR"},
+			worst:      forecast{8, 4, "BR", "IFR"},
+		},
+		{
+			name:         "TEMPO overlays the prevailing conditions",
+			raw:          "TAF KMIA 181120Z 1812/1912 09012KT P6SM SCT025 TEMPO 1818/1822 2SM TSRA BKN020CB",
+			at:           utc(18, 19),
+			prevailing:   forecast{12, 6.21, "", "VFR"},
+			alternatives: []forecast{{12, 2, "TSRA", "IFR"}},
+			worst:        forecast{12, 2, "TSRA", "IFR"},
+		},
+		{
+			name:       "TEMPO after it ends",
+			raw:        "TAF KMIA 181120Z 1812/1912 09012KT P6SM SCT025 TEMPO 1818/1822 2SM TSRA BKN020CB",
+			at:         utc(18, 22),
+			prevailing: forecast{12, 6.21, "", "VFR"},
+			worst:      forecast{12, 6.21, "", "VFR"},
+		},
+		{
+			name:         "PROB and TEMPO together",
+			raw:          "TAF KMIA 181120Z 1812/1912 09012KT P6SM SCT025 TEMPO 1818/1822 5SM -SHRA PROB30 1819/1821 1/2SM +TSRA",
+			at:           utc(18, 20),
+			prevailing:   forecast{12, 6.21, "", "VFR"},
+			alternatives: []forecast{{12, 5, "-SHRA", "MVFR"}, {12, 0.5, "+TSRA", "LIFR"}},
+			worst:        forecast{12, 0.5, "+TSRA", "LIFR"},
+		},
+		{
+			name:         "worse wind in the same category",
+			raw:          "TAF KDEN 181120Z 1812/1918 27012KT P6SM SCT080 TEMPO 1814/1818 27025G40KT",
+			at:           utc(18, 15),
+			prevailing:   forecast{12, 6.21, "", "VFR"},
+			alternatives: []forecast{{25, 6.21, "", "VFR"}},
+			worst:        forecast{25, 6.21, "", "VFR"},
+		},
+		{
+			name:       "calm BECMG wind replaces the base wind",
+			raw:        "TAF KDEN 181120Z 1812/1918 27012KT P6SM SCT080 BECMG 1814/1816 00000KT",
+			at:         utc(18, 17),
+			prevailing: forecast{0, 6.21, "", "VFR"},
+			worst:      forecast{0, 6.21, "", "VFR"},
+		},
+		{
+			name:       "NSW ends the weather",
+			raw:        "TAF KBOS 181120Z 1812/1912 18008KT 4SM -RA BKN040 BECMG 1820/1822 P6SM NSW",
+			at:         utc(18, 23),
+			prevailing: forecast{8, 6.21, "", "VFR"},
+			worst:      forecast{8, 6.21, "", "VFR"},
+		},
+	}
+	for _, tt := range tests {
+		taf, err := Decode(tt.raw, testRef)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		c, err := ConditionsAt(taf, tt.at)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		summarize := func(f Forecast) forecast {
+			return forecast{f.WindSpeedKt, f.VisibilityStatuteMi, f.WxString, f.FlightCategory}
+		}
+		if got := summarize(c.Prevailing); got != tt.prevailing {
+			t.Errorf("%s: prevailing %+v, want %+v", tt.name, got, tt.prevailing)
+		}
+		if len(c.Alternatives) != len(tt.alternatives) {
+			t.Errorf("%s: %d alternatives, want %d", tt.name, len(c.Alternatives), len(tt.alternatives))
+		} else {
+			for i, want := range tt.alternatives {
+				if got := summarize(c.Alternatives[i]); got != want {
+					t.Errorf("%s: alternative %d %+v, want %+v", tt.name, i, got, want)
+				}
+			}
+		}
+		if got := summarize(c.WorstCase); got != tt.worst {
+			t.Errorf("%s: worst case %+v, want %+v", tt.name, got, tt.worst)
+		}
+	}
+}
+
+func TestConditionsAtOutsideValidity(t *testing.T) {
+	taf, err := Decode("TAF KDEN 181120Z 1812/1918 27012KT P6SM SCT080", testRef)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, at := range []time.Time{utc(18, 11), utc(19, 18)} {
+		if _, err := ConditionsAt(taf, at); err == nil {
+			t.Errorf("conditions at %v outside the valid period", at)
+		}
+	}
+	if _, err := ConditionsAt(Taf{StationId: "KDEN"}, utc(18, 12)); err == nil {
+		t.Error("conditions of a TAF without periods")
+	}
+}
@@ -0,0 +1,297 @@
+package tafs
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	reTafIssue    = regexp.MustCompile(`^(\d{2})(\d{2})(\d{2})Z$`)
+	reTafValid    = regexp.MustCompile(`^(\d{2})(\d{2})/(\d{2})(\d{2})$`)
+	reTafFM       = regexp.MustCompile(`^FM(\d{2})(\d{2})(\d{2})$`)
+	reTafProb     = regexp.MustCompile(`^PROB(\d{2})$`)
+	reTafWind     = regexp.MustCompile(`^(\d{3}|VRB)(\d{2,3})(?:G(\d{2,3}))?(KT|MPS|KMH)$`)
+	reTafWS       = regexp.MustCompile(`^WS(\d{3})/(\d{3})(\d{2,3})KT$`)
+	reTafVisSM    = regexp.MustCompile(`^([MP])?(\d{1,2}|\d/\d{1,2})SM$`)
+	reTafVisWhole = regexp.MustCompile(`^\d$`)
+	reTafVisM     = regexp.MustCompile(`^(\d{4})$`)
+	reTafCloud    = regexp.MustCompile(`^(FEW|SCT|BKN|OVC)(\d{3})(CB|TCU)?$`)
+	reTafVertVis  = regexp.MustCompile(`^VV(\d{3})$`)
+	reTafAltim    = regexp.MustCompile(`^QNH(\d{4})INS$`)
+	reTafIcing    = regexp.MustCompile(`^6(\d)(\d{3})(\d)$`)
+	reTafTurb     = regexp.MustCompile(`^5(\d)(\d{3})(\d)$`)
+	reTafTemp     = regexp.MustCompile(`^T([XN])(M?\d{2})/(\d{2})(\d{2})Z$`)
+	reTafWeather  = regexp.MustCompile(`^(-|\+|VC)?(MI|PR|BC|DR|BL|SH|TS|FZ)?((?:DZ|RA|SN|SG|IC|PL|GR|GS|UP|BR|FG|FU|VA|DU|SA|HZ|PY|PO|SQ|FC|SS|DS)*)$`)
+)
+
+// Decode parses plain TAF text into a Taf with one Forecast per period.
+// The report carries only days of the month, so ref supplies the year and
+// month; it is normally the time the bulletin was received.
+func Decode(raw string, ref time.Time) (Taf, error) {
+	taf := Taf{RawText: strings.TrimSpace(raw)}
+	body := taf.RawText
+	if i := strings.Index(" "+body+" ", " RMK "); i >= 0 {
+		taf.Remarks = strings.TrimSpace(body[i+3:])
+		body = strings.TrimSpace(body[:i])
+	}
+	tokens := strings.Fields(strings.TrimSuffix(body, "="))
+
+	i := 0
+	var amendment string
+	for i < len(tokens) && (tokens[i] == "TAF" || tokens[i] == "AMD" || tokens[i] == "COR" || tokens[i] == "RTD") {
+		if tokens[i] != "TAF" {
+			amendment = tokens[i]
+		}
+		i++
+	}
+	if i >= len(tokens) || len(tokens[i]) != 4 {
+		return taf, fmt.Errorf("station identifier not found in %q", raw)
+	}
+	taf.StationId = tokens[i]
+	i++
+
+	if i < len(tokens) && reTafIssue.MatchString(tokens[i]) {
+		m := reTafIssue.FindStringSubmatch(tokens[i])
+		taf.IssueTime = resolveDay(ref, atoi(m[1]), atoi(m[2]), atoi(m[3]))
+		taf.BulletinTime = taf.IssueTime
+		i++
+	}
+	if i >= len(tokens) || !reTafValid.MatchString(tokens[i]) {
+		return taf, fmt.Errorf("validity period not found in %q", raw)
+	}
+	if taf.IssueTime.IsZero() {
+		taf.IssueTime = ref.UTC()
+	}
+	taf.ValidTimeFrom, taf.ValidTimeTo = decodePeriod(tokens[i], taf.IssueTime)
+	i++
+	if amendment != "" {
+		taf.Remarks = strings.TrimSpace(amendment + " " + taf.Remarks)
+	}
+
+	// split the remaining tokens into change groups
+	type group struct {
+		head   Forecast
+		tokens []string
+	}
+	groups := []group{{head: Forecast{FcstTimeFrom: taf.ValidTimeFrom}}}
+	for ; i < len(tokens); i++ {
+		tok := tokens[i]
+		var g group
+		switch {
+		case reTafFM.MatchString(tok):
+			m := reTafFM.FindStringSubmatch(tok)
+			g.head.ChangeIndicator = "FM"
+			g.head.FcstTimeFrom = resolveDay(taf.IssueTime, atoi(m[1]), atoi(m[2]), atoi(m[3]))
+		case tok == "BECMG" || tok == "TEMPO" || reTafProb.MatchString(tok):
+			if m := reTafProb.FindStringSubmatch(tok); m != nil {
+				g.head.ChangeIndicator = "PROB"
+				g.head.Probability = int32(atoi(m[1]))
+				if i+1 < len(tokens) && tokens[i+1] == "TEMPO" {
+					g.head.ChangeIndicator = "TEMPO"
+					i++
+				}
+			} else {
+				g.head.ChangeIndicator = tok
+			}
+			if i+1 < len(tokens) && reTafValid.MatchString(tokens[i+1]) {
+				i++
+				g.head.FcstTimeFrom, g.head.FcstTimeTo = decodePeriod(tokens[i], taf.IssueTime)
+			}
+			if g.head.ChangeIndicator == "BECMG" {
+				g.head.TimeBecoming = g.head.FcstTimeTo
+				g.head.FcstTimeTo = time.Time{}
+			}
+		default:
+			groups[len(groups)-1].tokens = append(groups[len(groups)-1].tokens, tok)
+			continue
+		}
+		groups = append(groups, g)
+	}
+
+	// prevailing periods (base, FM and BECMG) run until the next one starts
+	last := -1
+	for n := range groups {
+		f := &groups[n].head
+		if f.ChangeIndicator == "TEMPO" || f.ChangeIndicator == "PROB" {
+			continue
+		}
+		if last >= 0 {
+			groups[last].head.FcstTimeTo = f.FcstTimeFrom
+		}
+		last = n
+	}
+	if last >= 0 {
+		groups[last].head.FcstTimeTo = taf.ValidTimeTo
+	}
+
+	for _, g := range groups {
+		f := g.head
+		decodeForecast(&f, g.tokens, taf.IssueTime)
+		taf.Forecast = append(taf.Forecast, f)
+	}
+	return taf, nil
+}
+
+// decodeForecast fills a forecast period from the tokens of its change group
+func decodeForecast(f *Forecast, tokens []string, ref time.Time) {
+	var wx, notDecoded []string
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+		switch {
+		case reTafWind.MatchString(tok):
+			m := reTafWind.FindStringSubmatch(tok)
+			if m[1] != "VRB" {
+				f.WindDirDegrees = int16(atoi(m[1]))
+			}
+			f.WindSpeedKt = windToKnots(atoi(m[2]), m[4])
+			f.WindReported = true
+			if m[3] != "" {
+				f.WindGustKt = windToKnots(atoi(m[3]), m[4])
+			}
+		case reTafWS.MatchString(tok):
+			m := reTafWS.FindStringSubmatch(tok)
+			f.WindShearHgtFtAgl = int16(atoi(m[1]) * 100)
+			f.WindShearDirDegrees = int16(atoi(m[2]))
+			f.WindShearSpeedKt = float64(atoi(m[3]))
+		case reTafVisWhole.MatchString(tok) && i+1 < len(tokens) && reTafVisSM.MatchString(tokens[i+1]):
+			f.VisibilityStatuteMi = float64(atoi(tok)) + visibilitySM(tokens[i+1])
+			i++
+		case reTafVisSM.MatchString(tok):
+			f.VisibilityStatuteMi = visibilitySM(tok)
+		case reTafVisM.MatchString(tok):
+			if tok == "9999" {
+				f.VisibilityStatuteMi = 6.21
+			} else {
+				f.VisibilityStatuteMi = math.Round(float64(atoi(tok))/1609.344*100) / 100
+			}
+		case tok == "CAVOK":
+			f.VisibilityStatuteMi = 6.21
+			f.SkyCondition = append(f.SkyCondition, SkyCondition{SkyCover: "CAVOK"})
+		case reTafCloud.MatchString(tok):
+			m := reTafCloud.FindStringSubmatch(tok)
+			f.SkyCondition = append(f.SkyCondition, SkyCondition{
+				SkyCover:       m[1],
+				CloudBaseFtAGL: int32(atoi(m[2]) * 100),
+				CloudType:      m[3],
+			})
+		case tok == "SKC" || tok == "NSC" || tok == "CLR":
+			f.SkyCondition = append(f.SkyCondition, SkyCondition{SkyCover: tok})
+		case reTafVertVis.MatchString(tok):
+			f.VertVisFt = int16(atoi(tok[2:]) * 100)
+			f.SkyCondition = append(f.SkyCondition, SkyCondition{SkyCover: "OVX"})
+		case reTafAltim.MatchString(tok):
+			f.AltimInHg = float64(atoi(tok[3:7])) / 100
+		case reTafIcing.MatchString(tok):
+			m := reTafIcing.FindStringSubmatch(tok)
+			base := int32(atoi(m[2]) * 100)
+			f.IcingCondition = append(f.IcingCondition, IcingCondition{
+				Intensity:   m[1],
+				MinAltFtAGL: base,
+				MaxAltFtAGL: base + int32(atoi(m[3])*1000),
+			})
+		case reTafTurb.MatchString(tok):
+			m := reTafTurb.FindStringSubmatch(tok)
+			base := int32(atoi(m[2]) * 100)
+			f.TurbulenceCondition = append(f.TurbulenceCondition, TurbulenceCondition{
+				Intensity:   m[1],
+				MinAltFtAGL: base,
+				MaxAltFtAGL: base + int32(atoi(m[3])*1000),
+			})
+		case reTafTemp.MatchString(tok):
+			m := reTafTemp.FindStringSubmatch(tok)
+			v := float64(atoi(strings.TrimPrefix(m[2], "M")))
+			if strings.HasPrefix(m[2], "M") {
+				v = -v
+			}
+			f.Temperature.ValidTime = resolveDay(ref, atoi(m[3]), atoi(m[4]), 0)
+			if m[1] == "X" {
+				f.MaxTempC = v
+				f.Temperature.MaxTempC = strconv.FormatFloat(v, 'f', -1, 64)
+			} else {
+				f.MinTempC = v
+				f.Temperature.MinTempC = strconv.FormatFloat(v, 'f', -1, 64)
+			}
+		case tok == "NSW" || isWeather(tok):
+			wx = append(wx, tok)
+		default:
+			notDecoded = append(notDecoded, tok)
+		}
+	}
+	f.WxString = strings.Join(wx, " ")
+	f.NotDecoded = strings.Join(notDecoded, " ")
+}
+
+// decodePeriod resolves a ddhh/ddhh group against ref
+func decodePeriod(tok string, ref time.Time) (from, to time.Time) {
+	m := reTafValid.FindStringSubmatch(tok)
+	from = resolveDay(ref, atoi(m[1]), atoi(m[2]), 0)
+	to = resolveDay(from, atoi(m[3]), atoi(m[4]), 0)
+	if to.Before(from) {
+		to = to.AddDate(0, 1, 0)
+	}
+	return from, to
+}
+
+// resolveDay builds a UTC time from day, hour and minute, choosing the month
+// (this, previous or next relative to ref) that puts the result closest to ref.
+// Hour 24 is accepted and rolls into the next day.
+func resolveDay(ref time.Time, day, hour, minute int) time.Time {
+	ref = ref.UTC()
+	best := time.Time{}
+	for _, dm := range []int{0, -1, 1} {
+		first := time.Date(ref.Year(), ref.Month()+time.Month(dm), 1, 0, 0, 0, 0, time.UTC)
+		if day < 1 || day > first.AddDate(0, 1, -1).Day() {
+			continue
+		}
+		t := first.AddDate(0, 0, day-1).Add(time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute)
+		if best.IsZero() || absDuration(t.Sub(ref)) < absDuration(best.Sub(ref)) {
+			best = t
+		}
+	}
+	return best
+}
+
+func visibilitySM(tok string) float64 {
+	m := reTafVisSM.FindStringSubmatch(tok)
+	if m[1] == "P" && m[2] == "6" {
+		return 6.21
+	}
+	if parts := strings.Split(m[2], "/"); len(parts) == 2 {
+		if den := atoi(parts[1]); den != 0 {
+			return float64(atoi(parts[0])) / float64(den)
+		}
+		return 0
+	}
+	return float64(atoi(m[2]))
+}
+
+func windToKnots(v int, unit string) int32 {
+	switch unit {
+	case "MPS":
+		return int32(math.Round(float64(v) * 1.943844))
+	case "KMH":
+		return int32(math.Round(float64(v) * 0.539957))
+	}
+	return int32(v)
+}
+
+func isWeather(tok string) bool {
+	m := reTafWeather.FindStringSubmatch(tok)
+	return m != nil && (m[2] != "" || m[3] != "")
+}
+
+func atoi(s string) int {
+	v, _ := strconv.Atoi(s)
+	return v
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
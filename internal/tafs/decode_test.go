@@ -0,0 +1,121 @@
+package tafs
+
+import (
+	"testing"
+	"time"
+)
+
+var testRef = time.Date(2026, 10, 18, 11, 40, 0, 0, time.UTC)
+
+func utc(day, hour int) time.Time {
+	return time.Date(2026, 10, day, hour, 0, 0, 0, time.UTC)
+}
+
+func TestDecodeChangeGroups(t *testing.T) {
+	type period struct {
+		indicator   string
+		from, to    time.Time
+		becoming    time.Time
+		probability int32
+		windKt      int32
+		visSM       float64
+		wx          string
+	}
+	tests := []struct {
+		raw  string
+		want []period
+	}{
+		{
+			"TAF KDEN 181120Z 1812/1918 27012KT P6SM SCT080 FM181800 31020G30KT P6SM BKN050 FM190300 VRB05KT 5SM BR OVC015",
+			[]period{
+				{indicator: "", from: utc(18, 12), to: utc(18, 18), windKt: 12, visSM: 6.21},
+				{indicator: "FM", from: utc(18, 18), to: utc(19, 3), windKt: 20, visSM: 6.21},
+				{indicator: "FM", from: utc(19, 3), to: utc(19, 18), windKt: 5, visSM: 5, wx: "BR"},
+			},
+		},
+		{
+			"TAF KBOS 181120Z 1812/1912 18008KT P6SM BKN040 BECMG 1820/1822 22015KT 3SM -RA OVC020",
+			[]period{
+				{indicator: "", from: utc(18, 12), to: utc(18, 20), windKt: 8, visSM: 6.21},
+				{indicator: "BECMG", from: utc(18, 20), to: utc(19, 12), becoming: utc(18, 22), windKt: 15, visSM: 3, wx: "-RA"},
+			},
+		},
+		{
+			"TAF KMIA 181120Z 1812/1912 09012KT P6SM SCT025 TEMPO 1818/1822 2SM TSRA BKN020CB PROB30 1900/1904 1SM +TSRA",
+			[]period{
+				{indicator: "", from: utc(18, 12), to: utc(19, 12), windKt: 12, visSM: 6.21},
+				{indicator: "TEMPO", from: utc(18, 18), to: utc(18, 22), visSM: 2, wx: "TSRA"},
+				{indicator: "PROB", from: utc(19, 0), to: utc(19, 4), probability: 30, visSM: 1, wx: "+TSRA"},
+			},
+		},
+		{
+			"TAF EGLL 181100Z 1812/1918 24010KT 9999 SCT030 PROB40 TEMPO 1814/1818 4000 SHRA",
+			[]period{
+				{indicator: "", from: utc(18, 12), to: utc(19, 18), windKt: 10, visSM: 6.21},
+				{indicator: "TEMPO", from: utc(18, 14), to: utc(18, 18), probability: 40, visSM: 2.49, wx: "SHRA"},
+			},
+		},
+		{
+			// hour 24 ends the day
+			"TAF KDEN 181120Z 1812/1824 27012KT P6SM SKC",
+			[]period{
+				{indicator: "", from: utc(18, 12), to: utc(19, 0), windKt: 12, visSM: 6.21},
+			},
+		},
+	}
+	for _, tt := range tests {
+		taf, err := Decode(tt.raw, testRef)
+		if err != nil {
+			t.Errorf("%s: %v", tt.raw, err)
+			continue
+		}
+		if len(taf.Forecast) != len(tt.want) {
+			t.Errorf("%s: %d periods, want %d", tt.raw, len(taf.Forecast), len(tt.want))
+			continue
+		}
+		for i, w := range tt.want {
+			f := taf.Forecast[i]
+			got := period{
+				indicator:   f.ChangeIndicator,
+				from:        f.FcstTimeFrom,
+				to:          f.FcstTimeTo,
+				becoming:    f.TimeBecoming,
+				probability: f.Probability,
+				windKt:      f.WindSpeedKt,
+				visSM:       f.VisibilityStatuteMi,
+				wx:          f.WxString,
+			}
+			if !got.from.Equal(w.from) || !got.to.Equal(w.to) || !got.becoming.Equal(w.becoming) {
+				t.Errorf("%s: period %d from %v to %v becoming %v, want %v to %v becoming %v",
+					tt.raw, i, got.from, got.to, got.becoming, w.from, w.to, w.becoming)
+			}
+			got.from, got.to, got.becoming = w.from, w.to, w.becoming
+			if got != w {
+				t.Errorf("%s: period %d %+v, want %+v", tt.raw, i, got, w)
+			}
+		}
+	}
+}
+
+func TestDecodeValidity(t *testing.T) {
+	taf, err := Decode("TAF AMD KDEN 181120Z 1812/1918 27012KT P6SM SCT080 RMK NXT FCST BY 18Z", testRef)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if taf.StationId != "KDEN" {
+		t.Errorf("station %q, want KDEN", taf.StationId)
+	}
+	if want := time.Date(2026, 10, 18, 11, 20, 0, 0, time.UTC); !taf.IssueTime.Equal(want) {
+		t.Errorf("issue time %v, want %v", taf.IssueTime, want)
+	}
+	if !taf.ValidTimeFrom.Equal(utc(18, 12)) || !taf.ValidTimeTo.Equal(utc(19, 18)) {
+		t.Errorf("valid %v to %v", taf.ValidTimeFrom, taf.ValidTimeTo)
+	}
+	if taf.Remarks != "AMD NXT FCST BY 18Z" {
+		t.Errorf("remarks %q", taf.Remarks)
+	}
+
+	if _, err := Decode("TAF KDEN 181120Z 27012KT P6SM", testRef); err == nil {
+		t.Error("TAF without a validity period decoded")
+	}
+}
@@ -0,0 +1,234 @@
+package tafs
+
+import (
+	"encoding/json"
+	"go-charts/internal/geojson"
+	"go-charts/internal/weather"
+	"math"
+	"strconv"
+	"time"
+)
+
+// apiTaf is a TAF as returned by the aviationweather.gov Data API in JSON format
+type apiTaf struct {
+	IcaoId        string         `json:"icaoId"`
+	BulletinTime  weather.Time   `json:"bulletinTime"`
+	IssueTime     weather.Time   `json:"issueTime"`
+	ValidTimeFrom weather.Time   `json:"validTimeFrom"`
+	ValidTimeTo   weather.Time   `json:"validTimeTo"`
+	RawTAF        string         `json:"rawTAF"`
+	Remarks       string         `json:"remarks"`
+	Lat           float64        `json:"lat"`
+	Lon           float64        `json:"lon"`
+	Elev          weather.Number `json:"elev"`
+	Fcsts         []apiForecast  `json:"fcsts"`
+}
+
+type apiForecast struct {
+	TimeFrom    weather.Time    `json:"timeFrom"`
+	TimeTo      weather.Time    `json:"timeTo"`
+	TimeBec     weather.Time    `json:"timeBec"`
+	FcstChange  string          `json:"fcstChange"`
+	Probability weather.Number  `json:"probability"`
+	Wdir        weather.Number  `json:"wdir"`
+	Wspd        *weather.Number `json:"wspd"`
+	Wgst        weather.Number  `json:"wgst"`
+	WshearHgt   weather.Number  `json:"wshearHgt"`
+	WshearDir   weather.Number  `json:"wshearDir"`
+	WshearSpd   weather.Number  `json:"wshearSpd"`
+	Visib       json.RawMessage `json:"visib"`
+	Altim       weather.Number  `json:"altim"`
+	VertVis     weather.Number  `json:"vertVis"`
+	WxString    string          `json:"wxString"`
+	NotDecoded  string          `json:"notDecoded"`
+	Clouds      []struct {
+		Cover string         `json:"cover"`
+		Base  weather.Number `json:"base"`
+		Type  string         `json:"type"`
+	} `json:"clouds"`
+	IcgTurb []struct {
+		Var       string         `json:"var"`
+		Intensity weather.Number `json:"intensity"`
+		MinAlt    weather.Number `json:"minAlt"`
+		MaxAlt    weather.Number `json:"maxAlt"`
+	} `json:"icgTurb"`
+}
+
+// apiTafProperties are the properties of a Data API GeoJSON TAF feature
+type apiTafProperties struct {
+	Id        string       `json:"id"`
+	IssueTime weather.Time `json:"issueTime"`
+	RawTAF    string       `json:"rawTAF"`
+}
+
+// decodeJSON maps a Data API JSON response onto a Response
+func decodeJSON(data []byte) (*Response, error) {
+	var list []apiTaf
+	err := weather.DecodeJSONArray(data, &list)
+	if err != nil {
+		return nil, err
+	}
+	r := &Response{}
+	for _, a := range list {
+		t := Taf{
+			RawText:       a.RawTAF,
+			StationId:     a.IcaoId,
+			IssueTime:     a.IssueTime.Time,
+			BulletinTime:  a.BulletinTime.Time,
+			ValidTimeFrom: a.ValidTimeFrom.Time,
+			ValidTimeTo:   a.ValidTimeTo.Time,
+			Remarks:       a.Remarks,
+			Latitude:      a.Lat,
+			Longitude:     a.Lon,
+			ElevationM:    float64(a.Elev),
+		}
+		for _, af := range a.Fcsts {
+			f := Forecast{
+				FcstTimeFrom:        af.TimeFrom.Time,
+				FcstTimeTo:          af.TimeTo.Time,
+				ChangeIndicator:     af.FcstChange,
+				TimeBecoming:        af.TimeBec.Time,
+				Probability:         int32(af.Probability),
+				WindDirDegrees:      int16(af.Wdir),
+				WindReported:        af.Wspd != nil,
+				WindGustKt:          int32(af.Wgst),
+				WindShearHgtFtAgl:   int16(af.WshearHgt),
+				WindShearDirDegrees: int16(af.WshearDir),
+				WindShearSpeedKt:    float64(af.WshearSpd),
+				VisibilityStatuteMi: apiVisibility(af.Visib),
+				AltimInHg:           math.Round(float64(af.Altim)*0.0295300*100) / 100,
+				VertVisFt:           int16(af.VertVis),
+				WxString:            af.WxString,
+				NotDecoded:          af.NotDecoded,
+			}
+			if af.Wspd != nil {
+				f.WindSpeedKt = int32(*af.Wspd)
+			}
+			for _, c := range af.Clouds {
+				f.SkyCondition = append(f.SkyCondition, SkyCondition{
+					SkyCover:       c.Cover,
+					CloudBaseFtAGL: int32(c.Base),
+					CloudType:      c.Type,
+				})
+			}
+			for _, it := range af.IcgTurb {
+				intensity := strconv.Itoa(int(it.Intensity))
+				if it.Var == "ICE" {
+					f.IcingCondition = append(f.IcingCondition, IcingCondition{
+						Intensity:   intensity,
+						MinAltFtAGL: int32(it.MinAlt),
+						MaxAltFtAGL: int32(it.MaxAlt),
+					})
+				} else {
+					f.TurbulenceCondition = append(f.TurbulenceCondition, TurbulenceCondition{
+						Intensity:   intensity,
+						MinAltFtAGL: int32(it.MinAlt),
+						MaxAltFtAGL: int32(it.MaxAlt),
+					})
+				}
+			}
+			t.Forecast = append(t.Forecast, f)
+		}
+		r.Data.Tafs = append(r.Data.Tafs, t)
+	}
+	r.Data.NumResults = int32(len(r.Data.Tafs))
+	return r, nil
+}
+
+// apiVisibility converts a Data API visibility, which may be "6+", to
+// statute miles the way ADDS reports P6SM
+func apiVisibility(raw json.RawMessage) float64 {
+	var s string
+	if json.Unmarshal(raw, &s) == nil && s == "6+" {
+		return 6.21
+	}
+	var n weather.Number
+	_ = n.UnmarshalJSON(raw)
+	return float64(n)
+}
+
+// decodeGeoJSON maps a Data API GeoJSON response onto a Response. The
+// forecast periods are decoded from the raw TAF text of each feature.
+func decodeGeoJSON(data []byte) (*Response, error) {
+	fc, err := geojson.Decode(data)
+	if err != nil {
+		return nil, err
+	}
+	r := &Response{}
+	seen := make(map[string]bool)
+	for _, f := range fc.Features {
+		var p apiTafProperties
+		err = json.Unmarshal(f.Properties, &p)
+		if err != nil {
+			return nil, err
+		}
+		// the feed may split a TAF into one feature per period
+		if p.RawTAF == "" || seen[p.RawTAF] {
+			continue
+		}
+		seen[p.RawTAF] = true
+		t, err := Decode(p.RawTAF, issueRef(p.IssueTime.Time))
+		if err != nil {
+			continue
+		}
+		t.Longitude, t.Latitude, _ = f.Geometry.Point()
+		r.Data.Tafs = append(r.Data.Tafs, t)
+	}
+	r.Data.NumResults = int32(len(r.Data.Tafs))
+	return r, nil
+}
+
+// decodeCSV maps the tafs.cache.csv file onto a Response. Forecast
+// columns repeat once per period, so the periods are decoded from the
+// raw text and the station columns are taken from the file.
+func decodeCSV(data []byte) (*Response, error) {
+	records, err := weather.ReadCSV(data, "raw_text")
+	if err != nil {
+		return nil, err
+	}
+	r := &Response{}
+	for _, rec := range records {
+		issued := rec.Time("issue_time")
+		t, err := Decode(rec.Get("raw_text"), issueRef(issued))
+		if err != nil {
+			continue
+		}
+		if !issued.IsZero() {
+			t.IssueTime = issued
+		}
+		if bt := rec.Time("bulletin_time"); !bt.IsZero() {
+			t.BulletinTime = bt
+		}
+		t.Latitude = rec.Float("latitude")
+		t.Longitude = rec.Float("longitude")
+		t.ElevationM = rec.Float("elevation_m")
+		r.Data.Tafs = append(r.Data.Tafs, t)
+	}
+	r.Data.NumResults = int32(len(r.Data.Tafs))
+	return r, nil
+}
+
+// issueRef is the reference time used when decoding raw TAF text
+func issueRef(t time.Time) time.Time {
+	if t.IsZero() {
+		return time.Now().UTC()
+	}
+	return t
+}
+
+// ToGeoJson encodes the TAFs as a GeoJSON FeatureCollection of points
+// keyed by station and issue time, as Records keys them, since an amended
+// TAF can arrive alongside the one it replaces. The decoded fields and
+// periods are the properties.
+func (r *Response) ToGeoJson() (string, error) {
+	fc := geojson.NewFeatureCollection()
+	for _, t := range r.Data.Tafs {
+		id := t.StationId + " " + t.IssueTime.Format(time.RFC3339Nano)
+		f, err := geojson.NewFeature(id, geojson.NewPoint(t.Longitude, t.Latitude), t)
+		if err != nil {
+			return "", err
+		}
+		fc.Features = append(fc.Features, f)
+	}
+	return fc.Encode()
+}
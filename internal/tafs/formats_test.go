@@ -0,0 +1,98 @@
+package tafs
+
+import (
+	"go-charts/internal/weather"
+	"testing"
+	"time"
+)
+
+func TestSourceDecodeFormats(t *testing.T) {
+	type period struct {
+		indicator string
+		from      time.Time
+		windKt    int32
+		calm      bool
+		visSM     float64
+		category  string
+	}
+	const raw = "TAF KDEN 181120Z 1812/1912 00000KT P6SM BKN025 FM181800 27015KT 3SM BR OVC008"
+	want := []period{
+		{indicator: "", from: utc(18, 12), calm: true, visSM: 6.21, category: "MVFR"},
+		{indicator: "FM", from: utc(18, 18), windKt: 15, visSM: 3, category: "IFR"},
+	}
+	jsonReport := `{"icaoId":"KDEN","issueTime":"2026-10-18T11:20:00Z","validTimeFrom":1792324800,` +
+		`"validTimeTo":1792411200,"rawTAF":"` + raw + `","lat":39.85,"lon":-104.66,"fcsts":[` +
+		`{"timeFrom":1792324800,"timeTo":1792346400,"wdir":0,"wspd":0,"visib":"6+","clouds":[{"cover":"BKN","base":2500}]},` +
+		`{"timeFrom":1792346400,"timeTo":1792411200,"fcstChange":"FM","wdir":270,"wspd":15,"visib":3,` +
+		`"wxString":"BR","clouds":[{"cover":"OVC","base":800}]}]}`
+	geoFeature := `{"type":"Feature","geometry":{"type":"Point","coordinates":[-104.66,39.85]},` +
+		`"properties":{"id":"KDEN","issueTime":"2026-10-18T11:20:00Z","rawTAF":"` + raw + `"}}`
+	tests := []struct {
+		name   string
+		format string
+		data   string
+	}{
+		{"json", weather.FormatJSON, "[" + jsonReport + "]"},
+		// the feed may repeat a TAF once per period
+		{"geojson", weather.FormatGeoJSON, `{"type":"FeatureCollection","features":[` + geoFeature + "," + geoFeature + "]}"},
+		{"csv", weather.FormatCSV, "No errors\nNo warnings\n5 ms\ndata source=tafs\n1 results\n" +
+			"raw_text,station_id,issue_time,bulletin_time,valid_time_from,valid_time_to,latitude,longitude,elevation_m\n" +
+			raw + ",KDEN,2026-10-18T11:20:00Z,2026-10-18T11:20:00Z,2026-10-18T12:00:00Z,2026-10-19T12:00:00Z,39.85,-104.66,1640\n"},
+	}
+	for _, tt := range tests {
+		p, err := NewSource("", tt.format).Decode([]byte(tt.data))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		r := p.(*Response)
+		if len(r.Data.Tafs) != 1 || r.Data.NumResults != 1 {
+			t.Errorf("%s: %d TAFs, NumResults %d, want 1", tt.name, len(r.Data.Tafs), r.Data.NumResults)
+			continue
+		}
+		taf := r.Data.Tafs[0]
+		issued := time.Date(2026, 10, 18, 11, 20, 0, 0, time.UTC)
+		if taf.StationId != "KDEN" || !taf.IssueTime.Equal(issued) || taf.Latitude != 39.85 || taf.Longitude != -104.66 {
+			t.Errorf("%s: station %s issued %v at %v,%v", tt.name, taf.StationId, taf.IssueTime, taf.Latitude, taf.Longitude)
+		}
+		if !taf.ValidTimeFrom.Equal(utc(18, 12)) || !taf.ValidTimeTo.Equal(utc(19, 12)) {
+			t.Errorf("%s: valid %v to %v", tt.name, taf.ValidTimeFrom, taf.ValidTimeTo)
+		}
+		if len(taf.Forecast) != len(want) {
+			t.Errorf("%s: %d periods, want %d", tt.name, len(taf.Forecast), len(want))
+			continue
+		}
+		for i, w := range want {
+			f := taf.Forecast[i]
+			got := period{
+				indicator: f.ChangeIndicator,
+				from:      f.FcstTimeFrom,
+				windKt:    f.WindSpeedKt,
+				calm:      f.WindReported && f.WindSpeedKt == 0,
+				visSM:     f.VisibilityStatuteMi,
+				category:  f.FlightCategory,
+			}
+			if got != w {
+				t.Errorf("%s period %d: got %+v, want %+v", tt.name, i, got, w)
+			}
+		}
+	}
+}
+
+func TestSourceDecodeErrors(t *testing.T) {
+	tests := []struct {
+		format string
+		data   string
+	}{
+		{weather.FormatJSON, `[{"icaoId":`},
+		{weather.FormatGeoJSON, `[]`},
+		{weather.FormatCSV, "station_id\nKDEN\n"},
+		{"kml", `<kml/>`},
+	}
+	for _, tt := range tests {
+		_, err := NewSource("", tt.format).Decode([]byte(tt.data))
+		if err == nil {
+			t.Errorf("%s %q: no error", tt.format, tt.data)
+		}
+	}
+}
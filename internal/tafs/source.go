@@ -0,0 +1,62 @@
+package tafs
+
+import (
+	"encoding/xml"
+	"fmt"
+	"go-charts/internal/weather"
+)
+
+// Source downloads and decodes TAF reports
+type Source struct {
+	url    string
+	format string
+}
+
+// NewSource returns a TAF source reading from url. format selects the
+// decoder: xml (ADDS or compressed cache XML), json, geojson or csv.
+// An empty format means xml.
+func NewSource(url, format string) *Source {
+	if format == "" {
+		format = weather.FormatXML
+	}
+	return &Source{url: url, format: format}
+}
+
+// Name returns the product name used for messages and files
+func (s *Source) Name() string {
+	return "tafs"
+}
+
+// URL returns the download location
+func (s *Source) URL() string {
+	return s.url
+}
+
+// Empty returns a Response with no reports
+func (s *Source) Empty() weather.Product {
+	return &Response{}
+}
+
+// Decode converts downloaded data in the configured format into a Response
+func (s *Source) Decode(data []byte) (weather.Product, error) {
+	var r *Response
+	var err error
+	switch s.format {
+	case weather.FormatXML:
+		r = &Response{}
+		err = xml.Unmarshal(data, r)
+	case weather.FormatJSON:
+		r, err = decodeJSON(data)
+	case weather.FormatGeoJSON:
+		r, err = decodeGeoJSON(data)
+	case weather.FormatCSV:
+		r, err = decodeCSV(data)
+	default:
+		err = fmt.Errorf("unsupported %s format %q", s.Name(), s.format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	r.fillFlightCategories()
+	return r, nil
+}
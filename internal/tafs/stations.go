@@ -0,0 +1,13 @@
+package tafs
+
+import "go-charts/internal/stations"
+
+// FillStations joins each report to its station's name and site types.
+// table may be nil, and reports from unknown stations are left without one.
+func (r *Response) FillStations(table *stations.Table) {
+	for i := range r.Data.Tafs {
+		if s, ok := table.Lookup(r.Data.Tafs[i].StationId); ok {
+			r.Data.Tafs[i].Station = s.Info()
+		}
+	}
+}
@@ -3,12 +3,8 @@ package tafs
 import (
 	"encoding/json"
 	"encoding/xml"
-	"fmt"
-	"io/ioutil"
-	"log"
-	"net"
-	"net/http"
-	"os"
+	"go-charts/internal/stations"
+	"go-charts/internal/weather"
 	"time"
 )
 
@@ -94,73 +90,49 @@ type Forecast struct {
 	SfcTempC            float64               `xml:"sfc_temp_c"`
 	MaxTempC            float64               `xml:"max_temp_c"`
 	MinTempC            float64               `xml:"min_temp_c"`
+	FlightCategory      string                `xml:"-"`
+	WindReported        bool                  `xml:"-"`
 }
 
-type Taf struct {
-	XMLName       xml.Name   `xml:"TAF" json:"-"`
-	RawText       string     `xml:"raw_text"`
-	StationId     string     `xml:"station_id"`
-	IssueTime     time.Time  `xml:"issue_time"`
-	BulletinTime  time.Time  `xml:"bulletin_time"`
-	ValidTimeFrom time.Time  `xml:"valid_time_from"`
-	ValidTimeTo   time.Time  `xml:"valid_time_to"`
-	Remarks       string     `xml:"remarks"`
-	Latitude      float64    `xml:"latitude"`
-	Longitude     float64    `xml:"longitude"`
-	ElevationM    float64    `xml:"elevation_m"`
-	Forecast      []Forecast `xml:"forecast"`
-}
-
-// SaveAsJSONFile downloads xml file from ADDS weather server and converts to a JSON string
-func (r *Response) SaveAsJSONFile(url string) (err error) {
-
-	t := &http.Transport{
-		Dial: (&net.Dialer{
-			Timeout:   60 * time.Second,
-			KeepAlive: 30 * time.Second,
-		}).Dial,
-		TLSHandshakeTimeout: 60 * time.Second,
-	}
-	c := &http.Client{
-		Transport: t,
-	}
-	resp, err := c.Get(url)
-	if err != nil {
-		return err
-	} else if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("Error status code received: %v", resp.StatusCode)
-	}
-
-	defer resp.Body.Close()
-	data, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return err
-	}
-
-	err = xml.Unmarshal(data, &r)
-	if err != nil {
-		return err
+// UnmarshalXML decodes a forecast period and notes whether it forecasts a
+// wind, since a calm wind has the same zero speed as a missing one
+func (f *Forecast) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type Period Forecast
+	var v struct {
+		Period
+		WindSpeedKt *int32 `xml:"wind_speed_kt"`
 	}
-
-	var s string
-	s, err = r.ToJson()
+	err := d.DecodeElement(&v, &start)
 	if err != nil {
 		return err
 	}
-
-	jsonfile := "./workfiles/tafs.json"
-	err = os.Remove(jsonfile)
-	if err != nil {
-		log.Println(err)
+	*f = Forecast(v.Period)
+	if v.WindSpeedKt != nil {
+		f.WindSpeedKt = *v.WindSpeedKt
+		f.WindReported = true
 	}
+	return nil
+}
 
-	nstr := []byte("{ \"tafs\": " + s + "}")
-	err = os.WriteFile(jsonfile, nstr, 0644)
-	if err != nil {
-		return err
-	}
+type Taf struct {
+	XMLName       xml.Name       `xml:"TAF" json:"-"`
+	RawText       string         `xml:"raw_text"`
+	StationId     string         `xml:"station_id"`
+	IssueTime     time.Time      `xml:"issue_time"`
+	BulletinTime  time.Time      `xml:"bulletin_time"`
+	ValidTimeFrom time.Time      `xml:"valid_time_from"`
+	ValidTimeTo   time.Time      `xml:"valid_time_to"`
+	Remarks       string         `xml:"remarks"`
+	Latitude      float64        `xml:"latitude"`
+	Longitude     float64        `xml:"longitude"`
+	ElevationM    float64        `xml:"elevation_m"`
+	Forecast      []Forecast     `xml:"forecast"`
+	Station       *stations.Info `xml:"-" json:",omitempty"`
+}
 
-	return nil
+// Count returns the number of reports in the response
+func (r *Response) Count() int {
+	return len(r.Data.Tafs)
 }
 
 func (r *Response) ToRawTextOnly() (s []string) {
@@ -179,6 +151,25 @@ func (r *Response) ToJson() (s string, err error) {
 	return
 }
 
+// FromJson replaces the reports with those encoded by ToJson
+func (r *Response) FromJson(data []byte) error {
+	return json.Unmarshal(data, &r.Data.Tafs)
+}
+
+// Records returns the reports keyed by station and issue time
+func (r *Response) Records() []weather.Record {
+	records := make([]weather.Record, 0, len(r.Data.Tafs))
+	for _, t := range r.Data.Tafs {
+		records = append(records, weather.Record{Key: t.StationId + " " + t.IssueTime.Format(time.RFC3339Nano), Data: t})
+	}
+	return records
+}
+
+// StationReport returns the station and issue time of a report
+func (r *Response) StationReport(i int) (string, time.Time) {
+	return r.Data.Tafs[i].StationId, r.Data.Tafs[i].IssueTime
+}
+
 func (r *Response) ToJsonIndented() (s string, err error) {
 	b, err := json.MarshalIndent(r.Data.Tafs, "", "  ")
 	if err != nil {
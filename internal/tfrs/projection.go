@@ -0,0 +1,74 @@
+package tfrs
+
+import (
+	"go-charts/internal/geo"
+	"time"
+)
+
+// Track is an ownship position with the course and speed made good
+type Track struct {
+	Latitude      float64
+	Longitude     float64
+	AltitudeFt    float64
+	TrackDeg      float64
+	GroundSpeedKt float64
+	Time          time.Time
+}
+
+// Conflict is a TFR area that ownship is inside of or is projected to enter
+type Conflict struct {
+	Id           string
+	Name         string
+	Area         string
+	Inside       bool
+	MinutesAhead float64
+	EffectiveTo  time.Time
+}
+
+// Conflicts projects the track ahead in one minute steps for lookahead,
+// at constant altitude, and returns the first point at which it is inside
+// each TFR that is active at that time. A track with no ground speed is
+// only checked at its current position.
+func Conflicts(list []Tfr, track Track, lookahead time.Duration) []Conflict {
+	var conflicts []Conflict
+	steps := int(lookahead / time.Minute)
+	if track.GroundSpeedKt <= 0 {
+		steps = 0
+	}
+	for _, t := range list {
+		for step := 0; step <= steps; step++ {
+			when := track.Time.Add(time.Duration(step) * time.Minute)
+			if !t.Active(when) {
+				continue
+			}
+			lat, lon := track.Latitude, track.Longitude
+			if step > 0 {
+				lat, lon = geo.Destination(lat, lon, track.TrackDeg, track.GroundSpeedKt*float64(step)/60)
+			}
+			a, ok := t.areaAt(lat, lon, track.AltitudeFt, when)
+			if !ok {
+				continue
+			}
+			conflicts = append(conflicts, Conflict{
+				Id:           t.Id,
+				Name:         t.Name,
+				Area:         a.Name,
+				Inside:       step == 0,
+				MinutesAhead: float64(step),
+				EffectiveTo:  t.EffectiveTo,
+			})
+			break
+		}
+	}
+	return conflicts
+}
+
+// areaAt returns the active area of the TFR containing a position
+func (f *Tfr) areaAt(lat, lon, altFt float64, t time.Time) (Area, bool) {
+	for _, a := range f.Areas {
+		if a.Active(t) && a.ContainsAltitude(altFt) && a.Contains(lat, lon) {
+			return a, true
+		}
+	}
+	return Area{}, false
+}
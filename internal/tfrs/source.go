@@ -0,0 +1,91 @@
+package tfrs
+
+import (
+	"fmt"
+	"go-charts/internal/geojson"
+	"go-charts/internal/weather"
+	"time"
+)
+
+// Source downloads and decodes TFRs from XNOTAM XML, either from a URL
+// or from a local directory of detail files with a file:// url
+type Source struct {
+	url string
+	now func() time.Time
+}
+
+// NewSource returns a TFR source reading XNOTAM-Update XML from url. now,
+// which may be nil for the system clock, gives the time expired TFRs are
+// dropped at.
+func NewSource(url string, now func() time.Time) *Source {
+	if now == nil {
+		now = time.Now
+	}
+	return &Source{url: url, now: now}
+}
+
+// Name returns the product name used for messages and files
+func (s *Source) Name() string {
+	return "tfrs"
+}
+
+// URL returns the download location
+func (s *Source) URL() string {
+	return s.url
+}
+
+// Empty returns a Response with no reports
+func (s *Source) Empty() weather.Product {
+	return &Response{}
+}
+
+// Decode parses the TFRs and drops those that have expired
+func (s *Source) Decode(data []byte) (weather.Product, error) {
+	list, err := DecodeXNOTAM(data)
+	if err != nil {
+		return nil, err
+	}
+	return &Response{Tfrs: Current(list, s.now().UTC())}, nil
+}
+
+// Current drops the TFRs that expired before now
+func Current(list []Tfr, now time.Time) []Tfr {
+	current := make([]Tfr, 0, len(list))
+	for _, t := range list {
+		if !t.Expired(now) {
+			current = append(current, t)
+		}
+	}
+	return current
+}
+
+// ToGeoJson encodes every TFR area as a polygon feature. Circles are
+// approximated by polygons and the area limits are merged into the TFR
+// properties.
+func (r *Response) ToGeoJson() (string, error) {
+	fc := geojson.NewFeatureCollection()
+	for _, t := range r.Tfrs {
+		for i, a := range t.Areas {
+			props := map[string]interface{}{
+				"Id":            t.Id,
+				"Facility":      t.Facility,
+				"Type":          t.Type,
+				"Name":          t.Name,
+				"Description":   t.Description,
+				"EffectiveFrom": t.EffectiveFrom,
+				"EffectiveTo":   t.EffectiveTo,
+				"Area":          a.Name,
+				"LowerFt":       a.LowerFt,
+				"LowerRef":      a.LowerRef,
+				"UpperFt":       a.UpperFt,
+				"UpperRef":      a.UpperRef,
+			}
+			f, err := geojson.NewFeature(fmt.Sprintf("%s-%d", t.Id, i), geojson.NewPolygon(a.Ring()), props)
+			if err != nil {
+				return "", err
+			}
+			fc.Features = append(fc.Features, f)
+		}
+	}
+	return fc.Encode()
+}
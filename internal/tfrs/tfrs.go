@@ -0,0 +1,160 @@
+package tfrs
+
+import (
+	"encoding/json"
+	"go-charts/internal/geo"
+	"go-charts/internal/weather"
+	"time"
+)
+
+// Altitude references of TFR limits
+const (
+	RefMSL = "MSL"
+	RefAGL = "AGL"
+)
+
+// Tfr is one Temporary Flight Restriction with the areas it covers
+type Tfr struct {
+	Id            string
+	Facility      string
+	Type          string
+	Name          string
+	Description   string
+	EffectiveFrom time.Time
+	EffectiveTo   time.Time
+	Areas         []Area
+}
+
+// Area is a polygon, or a circle when RadiusNm is set, with altitude limits
+// and optionally its own effective times
+type Area struct {
+	Name          string
+	LowerFt       int32
+	LowerRef      string
+	UpperFt       int32
+	UpperRef      string
+	EffectiveFrom time.Time
+	EffectiveTo   time.Time
+	Points        [][2]float64 `json:",omitempty"`
+	CenterLat     float64      `json:",omitempty"`
+	CenterLon     float64      `json:",omitempty"`
+	RadiusNm      float64      `json:",omitempty"`
+}
+
+// Response holds the TFRs of one download
+type Response struct {
+	Tfrs []Tfr
+}
+
+// activeAt reports whether t falls in a from-to period. Zero times leave
+// that end of the period open.
+func activeAt(from, to, t time.Time) bool {
+	if !from.IsZero() && t.Before(from) {
+		return false
+	}
+	return to.IsZero() || t.Before(to)
+}
+
+// Active reports whether the TFR is in effect at t
+func (f *Tfr) Active(t time.Time) bool {
+	return activeAt(f.EffectiveFrom, f.EffectiveTo, t)
+}
+
+// Expired reports whether the TFR has ended before t
+func (f *Tfr) Expired(t time.Time) bool {
+	return !f.EffectiveTo.IsZero() && !t.Before(f.EffectiveTo)
+}
+
+// Active reports whether the area is in effect at t
+func (a *Area) Active(t time.Time) bool {
+	return activeAt(a.EffectiveFrom, a.EffectiveTo, t)
+}
+
+// IsCircle reports whether the area is a circle rather than a polygon
+func (a *Area) IsCircle() bool {
+	return a.RadiusNm > 0
+}
+
+// Contains reports whether a point lies inside the area laterally
+func (a *Area) Contains(lat, lon float64) bool {
+	if a.IsCircle() {
+		return geo.DistanceNm(a.CenterLat, a.CenterLon, lat, lon) <= a.RadiusNm
+	}
+	return len(a.Points) >= 3 && geo.PolygonContains(lat, lon, a.Points)
+}
+
+// ContainsAltitude reports whether an altitude in feet MSL may be inside
+// the vertical limits. Ground elevation is not known, so an AGL limit is
+// treated conservatively: an AGL floor counts as the surface and an AGL
+// ceiling as unlimited.
+func (a *Area) ContainsAltitude(altFt float64) bool {
+	if a.LowerRef == RefMSL && altFt < float64(a.LowerFt) {
+		return false
+	}
+	if a.UpperRef == RefMSL && a.UpperFt > 0 && altFt > float64(a.UpperFt) {
+		return false
+	}
+	return true
+}
+
+// Ring returns the area outline as a ring of [longitude, latitude]
+// vertices. Circles are approximated with one vertex every 10 degrees.
+func (a *Area) Ring() [][2]float64 {
+	if !a.IsCircle() {
+		return a.Points
+	}
+	ring := make([][2]float64, 0, 37)
+	for brg := 0; brg < 360; brg += 10 {
+		lat, lon := geo.Destination(a.CenterLat, a.CenterLon, float64(brg), a.RadiusNm)
+		ring = append(ring, [2]float64{lon, lat})
+	}
+	return ring
+}
+
+// Count returns the number of TFRs in the response
+func (r *Response) Count() int {
+	return len(r.Tfrs)
+}
+
+func (r *Response) ToJson() (s string, err error) {
+	list := r.Tfrs
+	if list == nil {
+		list = []Tfr{}
+	}
+	bytes, err := json.Marshal(list)
+	if err != nil {
+		return "", err
+	}
+	s = string(bytes)
+	return
+}
+
+// FromJson replaces the TFRs with those encoded by ToJson, dropping any
+// that have expired since, as Current does
+func (r *Response) FromJson(data []byte) error {
+	var list []Tfr
+	err := json.Unmarshal(data, &list)
+	if err != nil {
+		return err
+	}
+	r.Tfrs = Current(list, time.Now().UTC())
+	return nil
+}
+
+// Records returns the TFRs keyed by number
+func (r *Response) Records() []weather.Record {
+	records := make([]weather.Record, 0, len(r.Tfrs))
+	for _, t := range r.Tfrs {
+		records = append(records, weather.Record{Key: t.Id, Data: t})
+	}
+	return records
+}
+
+func (r *Response) ToJsonIndented() (s string, err error) {
+	bytes, err := json.MarshalIndent(r.Tfrs, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	s = string(bytes)
+	return
+}
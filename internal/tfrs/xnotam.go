@@ -0,0 +1,212 @@
+package tfrs
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"go-charts/internal/weather"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// xnotamUpdate is an FAA XNOTAM-Update document, as served for each TFR
+// by tfr.faa.gov
+type xnotamUpdate struct {
+	Nots []xnot `xml:"Group>Add>Not"`
+}
+
+type xnot struct {
+	LocalName     string       `xml:"NotUid>txtLocalName"`
+	DateEffective string       `xml:"dateEffective"`
+	DateExpire    string       `xml:"dateExpire"`
+	Facility      string       `xml:"codeFacility"`
+	Description   string       `xml:"txtDescrUSNS"`
+	Type          string       `xml:"TfrNot>codeType"`
+	AreaGroups    []xareaGroup `xml:"TfrNot>TFRAreaGroup"`
+}
+
+type xareaGroup struct {
+	Name      string      `xml:"aseTFRArea>txtName"`
+	UpperCode string      `xml:"aseTFRArea>codeDistVerUpper"`
+	UpperVal  string      `xml:"aseTFRArea>valDistVerUpper"`
+	UpperUom  string      `xml:"aseTFRArea>uomDistVerUpper"`
+	LowerCode string      `xml:"aseTFRArea>codeDistVerLower"`
+	LowerVal  string      `xml:"aseTFRArea>valDistVerLower"`
+	LowerUom  string      `xml:"aseTFRArea>uomDistVerLower"`
+	Schedules []xschedule `xml:"aseTFRArea>ScheduleGroup"`
+	Merged    []xavx      `xml:"abdMergedArea>Avx"`
+	Shapes    []xavx      `xml:"aseShapes>Abd>Avx"`
+}
+
+type xschedule struct {
+	DateEffective string `xml:"dateEffective"`
+	DateExpire    string `xml:"dateExpire"`
+}
+
+// xavx is a border vertex: a great circle point (GRC) or the start of a
+// clockwise (CWA) or counterclockwise (CCA) arc about geoLatArc/geoLongArc
+type xavx struct {
+	Type      string `xml:"codeType"`
+	Lat       string `xml:"geoLat"`
+	Lon       string `xml:"geoLong"`
+	LatArc    string `xml:"geoLatArc"`
+	LonArc    string `xml:"geoLongArc"`
+	Radius    string `xml:"valRadiusArc"`
+	RadiusUom string `xml:"uomRadiusArc"`
+}
+
+// DecodeXNOTAM decodes one or more concatenated XNOTAM-Update documents
+func DecodeXNOTAM(data []byte) ([]Tfr, error) {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	list := make([]Tfr, 0)
+	for {
+		var doc xnotamUpdate
+		err := d.Decode(&doc)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, n := range doc.Nots {
+			t, err := n.toTfr()
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, t)
+		}
+	}
+	return list, nil
+}
+
+func (n *xnot) toTfr() (Tfr, error) {
+	t := Tfr{
+		Id:          strings.TrimSpace(n.LocalName),
+		Facility:    strings.TrimSpace(n.Facility),
+		Type:        strings.TrimSpace(n.Type),
+		Description: strings.TrimSpace(n.Description),
+	}
+	var err error
+	t.EffectiveFrom, err = parseTime(n.DateEffective)
+	if err != nil {
+		return t, fmt.Errorf("TFR %s: %v", t.Id, err)
+	}
+	t.EffectiveTo, err = parseTime(n.DateExpire)
+	if err != nil {
+		return t, fmt.Errorf("TFR %s: %v", t.Id, err)
+	}
+	for _, g := range n.AreaGroups {
+		a, ok, err := g.toArea()
+		if err != nil {
+			return t, fmt.Errorf("TFR %s: %v", t.Id, err)
+		}
+		if ok {
+			t.Areas = append(t.Areas, a)
+		}
+	}
+	if len(t.Areas) > 0 {
+		t.Name = t.Areas[0].Name
+	}
+	return t, nil
+}
+
+// toArea builds the area from the merged outline, which tfr.faa.gov
+// provides for every area with arcs already expanded to points. A lone
+// arc vertex in the shapes is a circle.
+func (g *xareaGroup) toArea() (Area, bool, error) {
+	a := Area{Name: strings.TrimSpace(g.Name)}
+	a.LowerFt, a.LowerRef = altitude(g.LowerCode, g.LowerVal, g.LowerUom)
+	a.UpperFt, a.UpperRef = altitude(g.UpperCode, g.UpperVal, g.UpperUom)
+	if len(g.Schedules) > 0 {
+		var err error
+		a.EffectiveFrom, err = parseTime(g.Schedules[0].DateEffective)
+		if err != nil {
+			return a, false, err
+		}
+		a.EffectiveTo, err = parseTime(g.Schedules[0].DateExpire)
+		if err != nil {
+			return a, false, err
+		}
+	}
+	if len(g.Merged) >= 3 {
+		for _, v := range g.Merged {
+			lat, lon, ok := position(v.Lat, v.Lon)
+			if ok {
+				a.Points = append(a.Points, [2]float64{lon, lat})
+			}
+		}
+		return a, len(a.Points) >= 3, nil
+	}
+	if len(g.Shapes) == 1 && (g.Shapes[0].Type == "CWA" || g.Shapes[0].Type == "CCA") {
+		v := g.Shapes[0]
+		lat, lon, ok := position(v.LatArc, v.LonArc)
+		if !ok {
+			return a, false, nil
+		}
+		a.CenterLat, a.CenterLon = lat, lon
+		a.RadiusNm = weather.ParseNumber(v.Radius)
+		switch strings.ToUpper(v.RadiusUom) {
+		case "KM":
+			a.RadiusNm /= 1.852
+		case "M":
+			a.RadiusNm /= 1852
+		}
+		return a, a.RadiusNm > 0, nil
+	}
+	for _, v := range g.Shapes {
+		lat, lon, ok := position(v.Lat, v.Lon)
+		if ok {
+			a.Points = append(a.Points, [2]float64{lon, lat})
+		}
+	}
+	return a, len(a.Points) >= 3, nil
+}
+
+// altitude converts an AIXM vertical limit to feet. HEI is above ground
+// level; ALT and STD (a flight level) are treated as MSL.
+func altitude(code, val, uom string) (int32, string) {
+	v := weather.ParseNumber(val)
+	if strings.EqualFold(strings.TrimSpace(uom), "FL") {
+		v *= 100
+	}
+	ref := RefMSL
+	if strings.EqualFold(strings.TrimSpace(code), "HEI") {
+		ref = RefAGL
+	}
+	return int32(v), ref
+}
+
+// position decodes coordinates such as 39.50000000N and 104.50000000W
+func position(lat, lon string) (float64, float64, bool) {
+	la, ok1 := coordinate(lat, 'S')
+	lo, ok2 := coordinate(lon, 'W')
+	return la, lo, ok1 && ok2
+}
+
+func coordinate(s string, negative byte) (float64, bool) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, false
+	}
+	hemi := s[len(s)-1]
+	if hemi >= '0' && hemi <= '9' {
+		v, err := strconv.ParseFloat(s, 64)
+		return v, err == nil
+	}
+	v, err := strconv.ParseFloat(s[:len(s)-1], 64)
+	if err != nil {
+		return 0, false
+	}
+	if hemi == negative {
+		v = -v
+	}
+	return v, true
+}
+
+// parseTime decodes an XNOTAM date. Dates are always given in UTC;
+// codeTimeZone only names the zone the FAA displays them in.
+func parseTime(s string) (time.Time, error) {
+	return weather.ParseTime(s)
+}
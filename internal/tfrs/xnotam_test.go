@@ -0,0 +1,98 @@
+package tfrs
+
+import (
+	"testing"
+	"time"
+)
+
+// detail6_4938 is a detail XNOTAM-Update as served by tfr.faa.gov for a
+// stadium-style TFR published with an eastern time zone
+const detail6_4938 = `<?xml version="1.0" encoding="UTF-8"?>
+<XNOTAM-Update version="2.12" origin="USNS" created="2026-10-14T18:27:09">
+  <Group>
+    <Add>
+      <Not>
+        <NotUid>
+          <txtLocalName>6/4938</txtLocalName>
+        </NotUid>
+        <dateEffective>2026-10-18T16:30:00</dateEffective>
+        <dateExpire>2026-10-18T21:00:00</dateExpire>
+        <codeTimeZone>EDT</codeTimeZone>
+        <codeExpirationTimeZone>EDT</codeExpirationTimeZone>
+        <codeFacility>ZNY</codeFacility>
+        <txtDescrUSNS>NEW YORK, NY, OCTOBER 18, 2026 LOCAL. PURSUANT TO 49 USC 40103(B), FLIGHT RESTRICTIONS 2610181630 UTC UNTIL 2610182100 UTC.</txtDescrUSNS>
+        <TfrNot>
+          <codeType>SECURITY</codeType>
+          <TFRAreaGroup>
+            <aseTFRArea>
+              <txtName>EAST RUTHERFORD</txtName>
+              <codeDistVerUpper>ALT</codeDistVerUpper>
+              <valDistVerUpper>3000</valDistVerUpper>
+              <uomDistVerUpper>FT</uomDistVerUpper>
+              <codeDistVerLower>ALT</codeDistVerLower>
+              <valDistVerLower>0</valDistVerLower>
+              <uomDistVerLower>FT</uomDistVerLower>
+              <ScheduleGroup>
+                <dateEffective>2026-10-18T16:30:00</dateEffective>
+                <dateExpire>2026-10-18T21:00:00</dateExpire>
+              </ScheduleGroup>
+            </aseTFRArea>
+            <aseShapes>
+              <Abd>
+                <Avx>
+                  <codeType>CWA</codeType>
+                  <geoLat>40.86611111N</geoLat>
+                  <geoLong>074.02777778W</geoLong>
+                  <geoLatArc>40.81361111N</geoLatArc>
+                  <geoLongArc>074.07444444W</geoLongArc>
+                  <valRadiusArc>3</valRadiusArc>
+                  <uomRadiusArc>NM</uomRadiusArc>
+                </Avx>
+              </Abd>
+            </aseShapes>
+          </TFRAreaGroup>
+        </TfrNot>
+      </Not>
+    </Add>
+  </Group>
+</XNOTAM-Update>
+`
+
+func TestDecodeXNOTAMTimesAreUTC(t *testing.T) {
+	list, err := DecodeXNOTAM([]byte(detail6_4938))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 {
+		t.Fatalf("decoded %d TFRs, want 1", len(list))
+	}
+	f := list[0]
+
+	// txtDescrUSNS gives the same period as 2610181630 UTC until 2610182100 UTC
+	from := time.Date(2026, 10, 18, 16, 30, 0, 0, time.UTC)
+	to := time.Date(2026, 10, 18, 21, 0, 0, 0, time.UTC)
+	if !f.EffectiveFrom.Equal(from) || !f.EffectiveTo.Equal(to) {
+		t.Errorf("effective %v to %v, want %v to %v", f.EffectiveFrom, f.EffectiveTo, from, to)
+	}
+	if f.Id != "6/4938" || f.Facility != "ZNY" || f.Type != "SECURITY" || f.Name != "EAST RUTHERFORD" {
+		t.Errorf("got id %q facility %q type %q name %q", f.Id, f.Facility, f.Type, f.Name)
+	}
+
+	if len(f.Areas) != 1 {
+		t.Fatalf("decoded %d areas, want 1", len(f.Areas))
+	}
+	a := f.Areas[0]
+	if !a.EffectiveFrom.Equal(from) || !a.EffectiveTo.Equal(to) {
+		t.Errorf("area effective %v to %v, want %v to %v", a.EffectiveFrom, a.EffectiveTo, from, to)
+	}
+	if a.LowerFt != 0 || a.UpperFt != 3000 || a.LowerRef != RefMSL || a.UpperRef != RefMSL {
+		t.Errorf("area limits %d %s to %d %s", a.LowerFt, a.LowerRef, a.UpperFt, a.UpperRef)
+	}
+	if !a.IsCircle() || a.RadiusNm != 3 || a.CenterLat != 40.81361111 || a.CenterLon != -74.07444444 {
+		t.Errorf("area circle %v,%v radius %v", a.CenterLat, a.CenterLon, a.RadiusNm)
+	}
+
+	if !f.Active(from) || f.Active(to) || f.Active(from.Add(-time.Minute)) {
+		t.Error("TFR active outside its UTC period")
+	}
+}
@@ -0,0 +1,171 @@
+package weather
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Formats a weather source can be configured to read
+const (
+	FormatXML     = "xml"
+	FormatJSON    = "json"
+	FormatGeoJSON = "geojson"
+	FormatCSV     = "csv"
+)
+
+// Number is a JSON value that may be a number, a numeric string such as
+// "10+", or null. Non-numeric strings such as "VRB" decode as zero.
+type Number float64
+
+// UnmarshalJSON implements json.Unmarshaler
+func (n *Number) UnmarshalJSON(b []byte) error {
+	s := strings.Trim(string(b), `"`)
+	*n = Number(ParseNumber(s))
+	return nil
+}
+
+// ParseNumber converts a Data API or CSV field to a float, ignoring a
+// trailing "+" and returning zero for empty or non-numeric values
+func ParseNumber(s string) float64 {
+	s = strings.TrimSuffix(strings.TrimSpace(s), "+")
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0
+	}
+	return v
+}
+
+// Time is a JSON value that may be a unix timestamp or a date string
+type Time struct {
+	time.Time
+}
+
+var timeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05.000Z",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04Z07:00",
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (t *Time) UnmarshalJSON(b []byte) error {
+	s := strings.Trim(string(b), `"`)
+	if s == "null" || s == "" {
+		return nil
+	}
+	pt, err := ParseTime(s)
+	if err != nil {
+		return err
+	}
+	t.Time = pt
+	return nil
+}
+
+// ParseTime converts a unix timestamp or one of the date formats used by
+// the Data API and the cache files to a UTC time
+func ParseTime(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if secs, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(secs, 0).UTC(), nil
+	}
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized time %q", s)
+}
+
+// DecodeJSONArray unmarshals a Data API response, which is either a bare
+// array or an object wrapping the array in a "data" member
+func DecodeJSONArray(data []byte, v interface{}) error {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '{' {
+		var wrapper struct {
+			Data json.RawMessage `json:"data"`
+		}
+		err := json.Unmarshal(data, &wrapper)
+		if err != nil {
+			return err
+		}
+		data = wrapper.Data
+	}
+	return json.Unmarshal(data, v)
+}
+
+// CSVRecord is a row from a cache CSV file. Columns such as sky_cover
+// repeat, so each name maps to all of its values in column order.
+type CSVRecord map[string][]string
+
+// Get returns the first value of a column
+func (r CSVRecord) Get(name string) string {
+	if v := r[name]; len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+// All returns every value of a repeated column
+func (r CSVRecord) All(name string) []string {
+	return r[name]
+}
+
+// Float returns the first value of a column as a float
+func (r CSVRecord) Float(name string) float64 {
+	return ParseNumber(r.Get(name))
+}
+
+// Time returns the first value of a column as a time
+func (r CSVRecord) Time(name string) time.Time {
+	t, _ := ParseTime(r.Get(name))
+	return t
+}
+
+// ReadCSV reads a cache CSV file. Any preamble lines (errors, warnings,
+// timing and result count) before the header row containing firstColumn
+// are skipped.
+func ReadCSV(data []byte, firstColumn string) ([]CSVRecord, error) {
+	cr := csv.NewReader(bytes.NewReader(data))
+	cr.FieldsPerRecord = -1
+	var header []string
+	var records []CSVRecord
+	for {
+		row, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if header == nil {
+			for _, col := range row {
+				if strings.TrimSpace(col) == firstColumn {
+					header = row
+					break
+				}
+			}
+			continue
+		}
+		rec := make(CSVRecord)
+		for i, col := range header {
+			if i < len(row) {
+				rec[col] = append(rec[col], row[i])
+			}
+		}
+		records = append(records, rec)
+	}
+	if header == nil {
+		return nil, fmt.Errorf("CSV header with column %q not found", firstColumn)
+	}
+	return records, nil
+}
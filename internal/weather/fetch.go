@@ -0,0 +1,207 @@
+package weather
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNotModified is returned by Fetch when the server reports that the
+// data has not changed since the last successful download
+var ErrNotModified = errors.New("not modified")
+
+// Validator identifies the version of a download so the next request
+// only returns changed data. It is kept only once the download has been
+// decoded and published, so a rejected download is fetched again.
+type Validator struct {
+	ETag         string
+	LastModified string
+}
+
+// Fetcher downloads weather data with conditional GET, gzip support and
+// retries with exponential backoff
+type Fetcher struct {
+	Client  *http.Client
+	Retries int
+	Backoff time.Duration
+
+	mu         sync.Mutex
+	validators map[string]Validator
+}
+
+// NewFetcher returns a Fetcher using the same timeouts as the original ADDS downloads
+func NewFetcher() *Fetcher {
+	t := &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout:   60 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSHandshakeTimeout: 60 * time.Second,
+	}
+	return &Fetcher{
+		Client:     &http.Client{Transport: t},
+		Retries:    3,
+		Backoff:    5 * time.Second,
+		validators: make(map[string]Validator),
+	}
+}
+
+// Get implements Getter by downloading the source URL
+func (f *Fetcher) Get(ctx context.Context, s Source) ([]byte, Validator, error) {
+	return f.Fetch(ctx, s.URL())
+}
+
+// Keep implements Getter by saving the validator of the source URL
+func (f *Fetcher) Keep(s Source, v Validator) {
+	f.mu.Lock()
+	f.validators[s.URL()] = v
+	f.mu.Unlock()
+}
+
+// Fetch downloads url, retrying transient failures until the retry count
+// is used up or ctx is cancelled. A file:// url reads a local file, or
+// every file in a local directory. The returned validator is sent with
+// later requests once it has been saved with Keep.
+func (f *Fetcher) Fetch(ctx context.Context, url string) ([]byte, Validator, error) {
+	if strings.HasPrefix(url, fileScheme) {
+		return f.readLocal(url)
+	}
+	var lastErr error
+	for attempt := 0; attempt <= f.Retries; attempt++ {
+		if attempt > 0 {
+			wait := f.Backoff << uint(attempt-1)
+			select {
+			case <-ctx.Done():
+				return nil, Validator{}, ctx.Err()
+			case <-time.After(wait):
+			}
+		}
+		data, v, retry, err := f.fetchOnce(ctx, url)
+		if err == nil || !retry {
+			return data, v, err
+		}
+		lastErr = err
+	}
+	return nil, Validator{}, lastErr
+}
+
+// fetchOnce makes a single request and reports whether a failure is worth retrying
+func (f *Fetcher) fetchOnce(ctx context.Context, url string) ([]byte, Validator, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, Validator{}, false, err
+	}
+	req.Header.Set("Accept-Encoding", "gzip")
+	f.mu.Lock()
+	v := f.validators[url]
+	f.mu.Unlock()
+	if v.ETag != "" {
+		req.Header.Set("If-None-Match", v.ETag)
+	}
+	if v.LastModified != "" {
+		req.Header.Set("If-Modified-Since", v.LastModified)
+	}
+
+	resp, err := f.Client.Do(req)
+	if err != nil {
+		return nil, Validator{}, ctx.Err() == nil, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified:
+		return nil, Validator{}, false, ErrNotModified
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return nil, Validator{}, true, fmt.Errorf("Error status code received: %v", resp.StatusCode)
+	case resp.StatusCode != http.StatusOK:
+		return nil, Validator{}, false, fmt.Errorf("Error status code received: %v", resp.StatusCode)
+	}
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, Validator{}, true, err
+	}
+	// handles both Content-Encoding: gzip and compressed .gz cache files
+	if len(data) >= 2 && data[0] == 0x1f && data[1] == 0x8b {
+		gz, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, Validator{}, false, err
+		}
+		data, err = ioutil.ReadAll(gz)
+		if err != nil {
+			return nil, Validator{}, false, err
+		}
+	}
+
+	v = Validator{
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}
+	return data, v, false, nil
+}
+
+const fileScheme = "file://"
+
+// readLocal reads a file, or the files of a directory in name order joined
+// by blank lines. The newest modification time and total size stand in
+// for an HTTP validator, so unchanged files report ErrNotModified.
+func (f *Fetcher) readLocal(url string) ([]byte, Validator, error) {
+	path := strings.TrimPrefix(url, fileScheme)
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, Validator{}, err
+	}
+	files := []string{path}
+	if fi.IsDir() {
+		entries, err := ioutil.ReadDir(path)
+		if err != nil {
+			return nil, Validator{}, err
+		}
+		files = files[:0]
+		for _, e := range entries {
+			if e.Mode().IsRegular() && !strings.HasPrefix(e.Name(), ".") {
+				files = append(files, filepath.Join(path, e.Name()))
+			}
+		}
+		sort.Strings(files)
+	}
+
+	var buf bytes.Buffer
+	var newest time.Time
+	for _, name := range files {
+		fi, err := os.Stat(name)
+		if err != nil {
+			return nil, Validator{}, err
+		}
+		if fi.ModTime().After(newest) {
+			newest = fi.ModTime()
+		}
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			return nil, Validator{}, err
+		}
+		if buf.Len() > 0 {
+			buf.WriteString("\n\n")
+		}
+		buf.Write(data)
+	}
+
+	v := Validator{LastModified: fmt.Sprintf("%d files %d bytes %s", len(files), buf.Len(), newest.UTC().Format(time.RFC3339Nano))}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.validators[url] == v {
+		return nil, Validator{}, ErrNotModified
+	}
+	return buf.Bytes(), v, nil
+}
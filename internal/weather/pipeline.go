@@ -0,0 +1,108 @@
+package weather
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrEmpty is returned by Update when a required source decodes to no
+// records, which is taken as a bad download rather than a real answer
+var ErrEmpty = errors.New("empty product")
+
+// Getter retrieves the raw data of a source. Fetcher downloads it from
+// the source URL; other implementations record or replay it. Keep is
+// called with the validator returned by Get once the data has been
+// published; until then Get keeps returning the same data.
+type Getter interface {
+	Get(ctx context.Context, s Source) ([]byte, Validator, error)
+	Keep(s Source, v Validator)
+}
+
+// Publisher receives each successfully downloaded and decoded product
+type Publisher func(name string, p Product) error
+
+// Pipeline runs the fetch, decode and publish steps for registered sources
+type Pipeline struct {
+	getter  Getter
+	publish Publisher
+
+	mu       sync.Mutex
+	sources  []Source
+	required map[string]bool
+}
+
+// NewPipeline returns a Pipeline that retrieves data with getter and hands
+// decoded products to publish
+func NewPipeline(getter Getter, publish Publisher) *Pipeline {
+	return &Pipeline{getter: getter, publish: publish}
+}
+
+// SetGetter replaces how the pipeline retrieves source data
+func (p *Pipeline) SetGetter(g Getter) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.getter = g
+}
+
+// Getter returns how the pipeline retrieves source data
+func (p *Pipeline) Getter() Getter {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.getter
+}
+
+// Register adds a source to the pipeline
+func (p *Pipeline) Register(s Source) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.sources = append(p.sources, s)
+}
+
+// Require marks sources that always have data, so an empty download is
+// rejected and the last good product is kept
+func (p *Pipeline) Require(names ...string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.required == nil {
+		p.required = make(map[string]bool)
+	}
+	for _, name := range names {
+		p.required[name] = true
+	}
+}
+
+// Sources returns the registered sources
+func (p *Pipeline) Sources() []Source {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]Source(nil), p.sources...)
+}
+
+// Update fetches, decodes and publishes a single source. It returns
+// ErrNotModified when the server has nothing new and ErrEmpty when a
+// required source has no records. A download that fails to decode or
+// publish is not marked as seen, so the next Update fetches it again.
+func (p *Pipeline) Update(ctx context.Context, s Source) error {
+	g := p.Getter()
+	data, v, err := g.Get(ctx, s)
+	if err != nil {
+		return err
+	}
+	product, err := s.Decode(data)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	required := p.required[s.Name()]
+	p.mu.Unlock()
+	if required && product.Count() == 0 {
+		return ErrEmpty
+	}
+	err = p.publish(s.Name(), product)
+	if err != nil {
+		return err
+	}
+	g.Keep(s, v)
+	return nil
+}
@@ -0,0 +1,89 @@
+package weather
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+var errNoLines = errors.New("no lines")
+
+type countProduct int
+
+func (c countProduct) ToJson() (string, error)    { return "[]", nil }
+func (c countProduct) FromJson(data []byte) error { return nil }
+func (c countProduct) Count() int                 { return int(c) }
+func (c countProduct) Records() []Record          { return nil }
+
+type lineSource string
+
+func (s lineSource) Name() string   { return "lines" }
+func (s lineSource) URL() string    { return string(s) }
+func (s lineSource) Empty() Product { return countProduct(0) }
+func (s lineSource) Decode(data []byte) (Product, error) {
+	n := 0
+	for _, b := range data {
+		if b == '\n' {
+			n++
+		}
+	}
+	if n == 0 {
+		return nil, errNoLines
+	}
+	return countProduct(n), nil
+}
+
+// lineServer serves body with an ETag and answers conditional requests
+type lineServer struct {
+	mu   sync.Mutex
+	body string
+}
+
+func (l *lineServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	l.mu.Lock()
+	body := l.body
+	l.mu.Unlock()
+	etag := strconv.Quote(body)
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("ETag", etag)
+	io.WriteString(w, body)
+}
+
+func TestUpdateRetriesRejectedDownload(t *testing.T) {
+	lines := &lineServer{}
+	srv := httptest.NewServer(lines)
+	defer srv.Close()
+	published := 0
+	p := NewPipeline(NewFetcher(), func(string, Product) error {
+		published++
+		return nil
+	})
+	src := lineSource(srv.URL)
+
+	// a download that fails to decode is fetched again, never reported as unchanged
+	for i := 0; i < 2; i++ {
+		if err := p.Update(context.Background(), src); err != errNoLines {
+			t.Fatalf("update %d: got %v, want errNoLines", i, err)
+		}
+	}
+	lines.mu.Lock()
+	lines.body = "a\nb\n"
+	lines.mu.Unlock()
+	if err := p.Update(context.Background(), src); err != nil {
+		t.Fatalf("got %v after the data changed", err)
+	}
+	if err := p.Update(context.Background(), src); err != ErrNotModified {
+		t.Fatalf("got %v, want ErrNotModified once published", err)
+	}
+	if published != 1 {
+		t.Fatalf("published %d times, want 1", published)
+	}
+}
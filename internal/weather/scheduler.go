@@ -0,0 +1,199 @@
+package weather
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Schedule sets how often a source is updated. Each run is delayed by a
+// random extra of up to Jitter so sources don't all download together.
+// After a failure the next run comes after Backoff, doubling with each
+// further failure up to MaxBackoff, which defaults to Interval.
+type Schedule struct {
+	Interval   time.Duration
+	Jitter     time.Duration
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+// Status reports the last and next run of a scheduled source
+type Status struct {
+	Name        string    `json:"name"`
+	Interval    string    `json:"interval"`
+	LastRun     time.Time `json:"lastrun"`
+	LastSuccess time.Time `json:"lastsuccess"`
+	LastError   string    `json:"lasterror,omitempty"`
+	Failures    int       `json:"failures"`
+	NextRun     time.Time `json:"nextrun"`
+	Running     bool      `json:"running"`
+}
+
+// runTimeout bounds a single update, including the fetcher's retries
+const runTimeout = 5 * time.Minute
+
+// RunFunc is called after every scheduled or manual update of a source
+// with the result of Pipeline.Update and how long it took
+type RunFunc func(name string, err error, took time.Duration)
+
+// Scheduler updates each source of a pipeline on its own schedule. A
+// source never runs twice at once, but different sources run in parallel.
+type Scheduler struct {
+	pipeline *Pipeline
+	after    RunFunc
+
+	mu   sync.Mutex
+	rnd  *rand.Rand
+	jobs []*job
+}
+
+type job struct {
+	source   Source
+	schedule Schedule
+	trigger  chan struct{}
+	status   Status
+}
+
+// NewScheduler returns a Scheduler for the sources of pipeline. after,
+// which may be nil, is called when each update finishes.
+func NewScheduler(pipeline *Pipeline, after RunFunc) *Scheduler {
+	return &Scheduler{
+		pipeline: pipeline,
+		after:    after,
+		rnd:      rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// Add schedules a source. Sources must be added before Start.
+func (s *Scheduler) Add(src Source, schedule Schedule) {
+	if schedule.Interval <= 0 {
+		schedule.Interval = 6 * time.Minute
+	}
+	if schedule.Backoff <= 0 {
+		schedule.Backoff = 30 * time.Second
+	}
+	if schedule.MaxBackoff <= 0 {
+		schedule.MaxBackoff = schedule.Interval
+	}
+	if schedule.MaxBackoff < schedule.Backoff {
+		schedule.MaxBackoff = schedule.Backoff
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs = append(s.jobs, &job{
+		source:   src,
+		schedule: schedule,
+		trigger:  make(chan struct{}, 1),
+		status:   Status{Name: src.Name(), Interval: schedule.Interval.String()},
+	})
+}
+
+// Start runs every source straight away, then on its schedule until ctx
+// is cancelled
+func (s *Scheduler) Start(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, j := range s.jobs {
+		go s.loop(ctx, j)
+	}
+}
+
+// RefreshNow runs a source as soon as it is idle, or every source when
+// name is empty. It reports false if no source has that name.
+func (s *Scheduler) RefreshNow(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	found := false
+	for _, j := range s.jobs {
+		if name != "" && j.source.Name() != name {
+			continue
+		}
+		found = true
+		// a trigger that is already pending covers this one
+		select {
+		case j.trigger <- struct{}{}:
+		default:
+		}
+	}
+	return found
+}
+
+// Status returns the run times of every scheduled source
+func (s *Scheduler) Status() []Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]Status, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		out = append(out, j.status)
+	}
+	return out
+}
+
+func (s *Scheduler) loop(ctx context.Context, j *job) {
+	for {
+		s.run(ctx, j)
+		timer := time.NewTimer(s.delay(j))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		case <-j.trigger:
+			timer.Stop()
+		}
+	}
+}
+
+// run updates a source once and records the outcome. ErrNotModified is a
+// success; ErrEmpty and every other error count as a failure.
+func (s *Scheduler) run(ctx context.Context, j *job) {
+	s.mu.Lock()
+	j.status.Running = true
+	j.status.LastRun = time.Now().UTC()
+	s.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, runTimeout)
+	began := time.Now()
+	err := s.pipeline.Update(ctx, j.source)
+	took := time.Since(began)
+	cancel()
+
+	s.mu.Lock()
+	j.status.Running = false
+	if err == nil || err == ErrNotModified {
+		j.status.LastSuccess = j.status.LastRun
+		j.status.LastError = ""
+		j.status.Failures = 0
+	} else {
+		j.status.LastError = err.Error()
+		j.status.Failures++
+	}
+	s.mu.Unlock()
+
+	if s.after != nil {
+		s.after(j.source.Name(), err, took)
+	}
+}
+
+// delay returns the wait until the next run and records it as NextRun
+func (s *Scheduler) delay(j *job) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sched := j.schedule
+	wait := sched.Interval
+	if j.status.Failures > 0 {
+		wait = sched.Backoff
+		for i := 1; i < j.status.Failures && wait < sched.MaxBackoff; i++ {
+			wait *= 2
+		}
+		if wait > sched.MaxBackoff {
+			wait = sched.MaxBackoff
+		}
+	}
+	if sched.Jitter > 0 {
+		wait += time.Duration(s.rnd.Int63n(int64(sched.Jitter)))
+	}
+	j.status.NextRun = time.Now().UTC().Add(wait)
+	return wait
+}
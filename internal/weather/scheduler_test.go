@@ -0,0 +1,93 @@
+package weather
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSchedulerDelay(t *testing.T) {
+	tests := []struct {
+		name     string
+		schedule Schedule
+		failures int
+		want     time.Duration
+	}{
+		{"success waits the interval", Schedule{Interval: 5 * time.Minute}, 0, 5 * time.Minute},
+		{"default interval", Schedule{}, 0, 6 * time.Minute},
+		{"first failure waits the backoff", Schedule{Interval: 5 * time.Minute, Backoff: 20 * time.Second}, 1, 20 * time.Second},
+		{"default backoff", Schedule{Interval: 5 * time.Minute}, 1, 30 * time.Second},
+		{"second failure doubles", Schedule{Interval: 5 * time.Minute, Backoff: 20 * time.Second}, 2, 40 * time.Second},
+		{"third failure doubles again", Schedule{Interval: 5 * time.Minute, Backoff: 20 * time.Second}, 3, 80 * time.Second},
+		{"capped at MaxBackoff", Schedule{Interval: 5 * time.Minute, Backoff: 20 * time.Second, MaxBackoff: time.Minute}, 3, time.Minute},
+		{"MaxBackoff defaults to the interval", Schedule{Interval: 2 * time.Minute, Backoff: 20 * time.Second}, 5, 2 * time.Minute},
+		{"many failures stay capped", Schedule{Interval: 5 * time.Minute, Backoff: 20 * time.Second, MaxBackoff: time.Minute}, 100, time.Minute},
+		{"MaxBackoff below the backoff", Schedule{Interval: 5 * time.Minute, Backoff: time.Minute, MaxBackoff: 10 * time.Second}, 2, time.Minute},
+	}
+	for _, tt := range tests {
+		s := NewScheduler(NewPipeline(NewFetcher(), nil), nil)
+		s.Add(lineSource(""), tt.schedule)
+		j := s.jobs[0]
+		j.status.Failures = tt.failures
+		before := time.Now().UTC()
+		if got := s.delay(j); got != tt.want {
+			t.Errorf("%s: delay %v, want %v", tt.name, got, tt.want)
+		}
+		if next := j.status.NextRun; next.Before(before.Add(tt.want)) || next.After(time.Now().UTC().Add(tt.want)) {
+			t.Errorf("%s: next run %v, want %v from now", tt.name, next, tt.want)
+		}
+	}
+}
+
+func TestSchedulerJitter(t *testing.T) {
+	s := NewScheduler(NewPipeline(NewFetcher(), nil), nil)
+	s.Add(lineSource(""), Schedule{Interval: time.Minute, Jitter: 10 * time.Second})
+	for i := 0; i < 100; i++ {
+		if d := s.delay(s.jobs[0]); d < time.Minute || d >= time.Minute+10*time.Second {
+			t.Fatalf("delay %v outside the interval plus jitter", d)
+		}
+	}
+}
+
+func TestSchedulerRunCountsFailures(t *testing.T) {
+	lines := &lineServer{}
+	srv := httptest.NewServer(lines)
+	defer srv.Close()
+	var results []error
+	s := NewScheduler(NewPipeline(NewFetcher(), func(string, Product) error { return nil }),
+		func(name string, err error, took time.Duration) { results = append(results, err) })
+	s.Add(lineSource(srv.URL), Schedule{Interval: 5 * time.Minute, Backoff: 10 * time.Second})
+	j := s.jobs[0]
+
+	steps := []struct {
+		body     string
+		failures int
+		delay    time.Duration
+	}{
+		{"", 1, 10 * time.Second},
+		{"", 2, 20 * time.Second},
+		{"a\n", 0, 5 * time.Minute},
+		// unchanged data is a success
+		{"a\n", 0, 5 * time.Minute},
+		{"", 1, 10 * time.Second},
+	}
+	for i, st := range steps {
+		lines.mu.Lock()
+		lines.body = st.body
+		lines.mu.Unlock()
+		s.run(context.Background(), j)
+		if j.status.Failures != st.failures {
+			t.Errorf("run %d: %d failures, want %d", i, j.status.Failures, st.failures)
+		}
+		if d := s.delay(j); d != st.delay {
+			t.Errorf("run %d: delay %v, want %v", i, d, st.delay)
+		}
+	}
+	if len(results) != len(steps) {
+		t.Errorf("after called %d times, want %d", len(results), len(steps))
+	}
+	if st := s.Status()[0]; st.LastError == "" || st.LastSuccess.IsZero() || st.Running {
+		t.Errorf("status %+v after a failure following a success", st)
+	}
+}
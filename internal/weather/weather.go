@@ -0,0 +1,33 @@
+package weather
+
+// Product is a decoded weather product that is ready to be published.
+// FromJson reverses ToJson, so a saved product can be reloaded.
+type Product interface {
+	ToJson() (string, error)
+	FromJson(data []byte) error
+	Count() int
+	Records() []Record
+}
+
+// Record is one report of a product. Key identifies the report from one
+// download to the next, so consecutive snapshots can be compared.
+type Record struct {
+	Key  string
+	Data interface{}
+}
+
+// Source is a downloadable weather product. Name is used for the
+// websocket message type and the published file name. Empty returns a
+// product with no reports for FromJson to fill.
+type Source interface {
+	Name() string
+	URL() string
+	Decode(data []byte) (Product, error)
+	Empty() Product
+}
+
+// GeoJsonProduct is a Product that can also be encoded as a GeoJSON FeatureCollection
+type GeoJsonProduct interface {
+	Product
+	ToGeoJson() (string, error)
+}
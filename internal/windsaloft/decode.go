@@ -0,0 +1,198 @@
+package windsaloft
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	reBasedOn  = regexp.MustCompile(`DATA BASED ON (\d{2})(\d{2})(\d{2})Z`)
+	reValid    = regexp.MustCompile(`VALID (\d{2})(\d{2})(\d{2})Z\s+FOR USE (\d{2})(\d{2})-(\d{2})(\d{2})Z`)
+	reTempsNeg = regexp.MustCompile(`TEMPS NEG ABV (\d+)`)
+	reGroup    = regexp.MustCompile(`^(\d{2})(\d{2})([+-]?\d{2})?$`)
+	reStation  = regexp.MustCompile(`^[A-Z0-9]{3}\s`)
+)
+
+// column is a header altitude and the character range of its values
+type column struct {
+	altitudeFt int
+	start, end int
+}
+
+// Decode parses FB winds and temperatures aloft text. Several bulletins
+// may be concatenated, as the region=all product is. ref resolves the
+// day-of-month times. The stations are left unlocated; see Locate.
+func Decode(text string, ref time.Time) (*Forecast, error) {
+	f := &Forecast{}
+	var cols []column
+	negAbove := 24000
+	altitudes := make(map[int]bool)
+
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\r", ""), "\n") {
+		switch {
+		case reBasedOn.MatchString(line):
+			m := reBasedOn.FindStringSubmatch(line)
+			f.BasedOn = resolveDay(ref, atoi(m[1]), atoi(m[2]), atoi(m[3]))
+		case reValid.MatchString(line):
+			m := reValid.FindStringSubmatch(line)
+			f.ValidTime = resolveDay(ref, atoi(m[1]), atoi(m[2]), atoi(m[3]))
+			f.UseFrom = useTime(f.ValidTime, atoi(m[4]), atoi(m[5]), true)
+			f.UseTo = useTime(f.ValidTime, atoi(m[6]), atoi(m[7]), false)
+			if t := reTempsNeg.FindStringSubmatch(line); t != nil {
+				negAbove = atoi(t[1])
+			}
+		case strings.HasPrefix(line, "FT "):
+			cols = headerColumns(line)
+			for _, c := range cols {
+				altitudes[c.altitudeFt] = true
+			}
+		case cols != nil && reStation.MatchString(line):
+			s, err := decodeStation(line, cols, negAbove)
+			if err != nil {
+				return nil, err
+			}
+			s.ValidTime = f.ValidTime
+			f.Stations = append(f.Stations, s)
+		case strings.TrimSpace(line) == "":
+			cols = nil
+		}
+	}
+	if len(f.Stations) == 0 {
+		return nil, fmt.Errorf("no FB stations found")
+	}
+	for alt := range altitudes {
+		f.Altitudes = append(f.Altitudes, alt)
+	}
+	sort.Ints(f.Altitudes)
+	return f, nil
+}
+
+// headerColumns finds the altitudes of the FT line. Values are right
+// aligned with the altitude above them, so each column runs from the end
+// of the previous header to the end of its own.
+func headerColumns(line string) []column {
+	var cols []column
+	prevEnd := 3
+	i := 2
+	for i < len(line) {
+		if line[i] == ' ' {
+			i++
+			continue
+		}
+		start := i
+		for i < len(line) && line[i] != ' ' {
+			i++
+		}
+		alt, err := strconv.Atoi(line[start:i])
+		if err != nil {
+			continue
+		}
+		cols = append(cols, column{altitudeFt: alt, start: prevEnd, end: i})
+		prevEnd = i
+	}
+	return cols
+}
+
+func decodeStation(line string, cols []column, negAbove int) (Station, error) {
+	s := Station{Ident: line[:3]}
+	for _, c := range cols {
+		if c.start >= len(line) {
+			break
+		}
+		end := c.end
+		if end > len(line) {
+			end = len(line)
+		}
+		group := strings.TrimSpace(line[c.start:end])
+		if group == "" {
+			continue
+		}
+		l, err := decodeGroup(group, c.altitudeFt, negAbove)
+		if err != nil {
+			return s, fmt.Errorf("%s %d: %v", s.Ident, c.altitudeFt, err)
+		}
+		s.Levels = append(s.Levels, l)
+	}
+	return s, nil
+}
+
+// decodeGroup decodes DDff, DDff±TT or DDffTT. 9900 is light and
+// variable. A direction code of 51 to 86 adds 500 degrees to the
+// direction and 100 knots to the speed; 199 knots means 199 or more.
+// Temperatures without a sign above negAbove feet are negative.
+func decodeGroup(group string, altitudeFt, negAbove int) (Level, error) {
+	m := reGroup.FindStringSubmatch(group)
+	if m == nil {
+		return Level{}, fmt.Errorf("invalid group %q", group)
+	}
+	l := Level{AltitudeFt: altitudeFt}
+	dd, ff := atoi(m[1]), atoi(m[2])
+	switch {
+	case dd == 99 && ff == 0:
+		l.LightAndVariable = true
+	case dd >= 51 && dd <= 86:
+		l.WindDirDegrees = (dd - 50) * 10
+		l.WindSpeedKt = ff + 100
+	default:
+		l.WindDirDegrees = dd * 10
+		l.WindSpeedKt = ff
+	}
+	if m[3] != "" {
+		t := float64(atoi(strings.TrimPrefix(m[3], "+")))
+		if m[3][0] == '-' {
+			t = float64(atoi(m[3]))
+		} else if m[3][0] != '+' && altitudeFt > negAbove {
+			t = -t
+		}
+		l.TempC = &t
+	}
+	return l, nil
+}
+
+// resolveDay places a day/hour/minute in the month of ref, or the one
+// before or after when that is closer
+func resolveDay(ref time.Time, day, hour, minute int) time.Time {
+	best := time.Time{}
+	for _, offset := range []int{-1, 0, 1} {
+		t := time.Date(ref.Year(), ref.Month()+time.Month(offset), day, hour, minute, 0, 0, time.UTC)
+		if t.Day() != day {
+			continue
+		}
+		if best.IsZero() || absDuration(t.Sub(ref)) < absDuration(best.Sub(ref)) {
+			best = t
+		}
+	}
+	return best
+}
+
+// useTime resolves an hhmm of the FOR USE window, which may start the day
+// before the valid time or end the day after it
+func useTime(valid time.Time, hour, minute int, start bool) time.Time {
+	t := time.Date(valid.Year(), valid.Month(), valid.Day(), hour%24, minute, 0, 0, time.UTC)
+	if hour == 24 {
+		t = t.AddDate(0, 0, 1)
+	}
+	if start && t.After(valid) {
+		t = t.AddDate(0, 0, -1)
+	}
+	if !start && t.Before(valid) {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t
+}
+
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
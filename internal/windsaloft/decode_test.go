@@ -0,0 +1,138 @@
+package windsaloft
+
+import (
+	"testing"
+	"time"
+)
+
+const testBulletin = `000
+FBUS31 KWNO 181358
+FD1US1
+DATA BASED ON 181200Z
+VALID 181800Z   FOR USE 1400-2100Z. TEMPS NEG ABV 24000
+
+FT  3000    6000    9000   12000   18000   24000  30000  34000  39000
+ABI 9900 2214+14 2519+10 2627+05 2533-08 2547-20 255236 255746 256556
+DEN              9900+06 2729-01 2745-15 2762-27 279142 731960 799957
+`
+
+func TestDecodeBulletin(t *testing.T) {
+	ref := time.Date(2026, 10, 18, 14, 5, 0, 0, time.UTC)
+	f, err := Decode(testBulletin, ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC); !f.BasedOn.Equal(want) {
+		t.Errorf("based on %v, want %v", f.BasedOn, want)
+	}
+	if want := time.Date(2026, 10, 18, 18, 0, 0, 0, time.UTC); !f.ValidTime.Equal(want) {
+		t.Errorf("valid %v, want %v", f.ValidTime, want)
+	}
+	if !f.UseFrom.Equal(time.Date(2026, 10, 18, 14, 0, 0, 0, time.UTC)) || !f.UseTo.Equal(time.Date(2026, 10, 18, 21, 0, 0, 0, time.UTC)) {
+		t.Errorf("for use %v to %v", f.UseFrom, f.UseTo)
+	}
+	if len(f.Altitudes) != 9 || f.Altitudes[0] != 3000 || f.Altitudes[8] != 39000 {
+		t.Errorf("altitudes %v", f.Altitudes)
+	}
+	if len(f.Stations) != 2 || f.Stations[0].Ident != "ABI" || f.Stations[1].Ident != "DEN" {
+		t.Fatalf("stations %+v", f.Stations)
+	}
+	if n := len(f.Stations[0].Levels); n != 9 {
+		t.Errorf("ABI has %d levels, want 9", n)
+	}
+	// DEN is too high for a 3000 or 6000 foot forecast
+	if n := len(f.Stations[1].Levels); n != 7 || f.Stations[1].Levels[0].AltitudeFt != 9000 {
+		t.Errorf("DEN has %d levels starting at %d, want 7 from 9000", n, f.Stations[1].Levels[0].AltitudeFt)
+	}
+}
+
+func TestDecodeGroup(t *testing.T) {
+	tests := []struct {
+		group      string
+		altitudeFt int
+		dir, speed int
+		lightVar   bool
+		temp       *float64
+	}{
+		{"9900", 3000, 0, 0, true, nil},
+		{"9900+06", 9000, 0, 0, true, float64p(6)},
+		{"2214+14", 6000, 220, 14, false, float64p(14)},
+		{"2533-08", 18000, 250, 33, false, float64p(-8)},
+		{"2547-20", 24000, 250, 47, false, float64p(-20)},
+		// no sign above 24000 feet is a negative temperature
+		{"255236", 30000, 250, 52, false, float64p(-36)},
+		{"256556", 39000, 250, 65, false, float64p(-56)},
+		// direction codes of 51 to 86 carry 100 knots more
+		{"731960", 34000, 230, 119, false, float64p(-60)},
+		{"5100", 12000, 10, 100, false, nil},
+		{"860542", 30000, 360, 105, false, float64p(-42)},
+		// 199 knots or more
+		{"799957", 39000, 290, 199, false, float64p(-57)},
+	}
+	for _, tt := range tests {
+		l, err := decodeGroup(tt.group, tt.altitudeFt, 24000)
+		if err != nil {
+			t.Errorf("%s: %v", tt.group, err)
+			continue
+		}
+		if l.AltitudeFt != tt.altitudeFt || l.WindDirDegrees != tt.dir || l.WindSpeedKt != tt.speed || l.LightAndVariable != tt.lightVar {
+			t.Errorf("%s at %d: %+v, want %d at %d kt, light and variable %v", tt.group, tt.altitudeFt, l, tt.dir, tt.speed, tt.lightVar)
+		}
+		switch {
+		case tt.temp == nil && l.TempC != nil:
+			t.Errorf("%s: temperature %v, want none", tt.group, *l.TempC)
+		case tt.temp != nil && (l.TempC == nil || *l.TempC != *tt.temp):
+			t.Errorf("%s: temperature %v, want %v", tt.group, l.TempC, *tt.temp)
+		}
+	}
+
+	for _, group := range []string{"ABCD", "22", "2214+1"} {
+		if _, err := decodeGroup(group, 6000, 24000); err == nil {
+			t.Errorf("%s: decoded", group)
+		}
+	}
+}
+
+// testLocator places every identifier it knows at a fixed position
+type testLocator map[string][2]float64
+
+func (l testLocator) Locate(ident string) (float64, float64, bool) {
+	p, ok := l[ident]
+	return p[0], p[1], ok
+}
+
+func TestLocate(t *testing.T) {
+	f, err := Decode(testBulletin, time.Date(2026, 10, 18, 14, 5, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Stations[0].Located || f.Stations[1].Located {
+		t.Fatal("stations located while decoding")
+	}
+
+	// only ABI is known at first
+	first := f.Locate(testLocator{"ABI": {32.41, -99.68}})
+	if s := first.Stations[0]; !s.Located || s.Latitude != 32.41 || s.Longitude != -99.68 {
+		t.Errorf("ABI %+v", s)
+	}
+	if first.Stations[1].Located {
+		t.Error("unknown DEN located")
+	}
+	if f.Stations[0].Located {
+		t.Error("Locate changed the shared forecast")
+	}
+
+	// DEN is placed once it is known, and a forecast with nothing left to
+	// place is returned as is
+	second := first.Locate(testLocator{"DEN": {39.86, -104.67}})
+	if !second.Stations[0].Located || !second.Stations[1].Located {
+		t.Errorf("stations %+v", second.Stations)
+	}
+	if third := second.Locate(testLocator{}); third != second {
+		t.Error("located forecast copied")
+	}
+}
+
+func float64p(v float64) *float64 {
+	return &v
+}
@@ -0,0 +1,153 @@
+package windsaloft
+
+import (
+	"fmt"
+	"go-charts/internal/geo"
+	"math"
+	"sort"
+)
+
+// Wind is the wind and temperature interpolated for a point and altitude
+type Wind struct {
+	Latitude       float64
+	Longitude      float64
+	AltitudeFt     float64
+	WindDirDegrees float64
+	WindSpeedKt    float64
+	TempC          *float64
+	Stations       []string
+}
+
+const (
+	// idwStations is how many of the nearest stations are blended
+	idwStations = 4
+	// idwMaxDistanceNm excludes stations too far away to be representative
+	idwMaxDistanceNm = 400
+	// lapseRateCPer1000 extends temperatures below the lowest forecast level
+	lapseRateCPer1000 = 1.98
+)
+
+// sample is one station's forecast interpolated to an altitude
+type sample struct {
+	u, v    float64
+	temp    float64
+	hasTemp bool
+}
+
+// At interpolates the wind and temperature at a position and altitude.
+// Each station column is interpolated linearly in altitude, then the
+// nearest stations are blended by inverse distance squared. Winds are
+// blended as vectors so opposing directions do not average to a third.
+func (f *Forecast) At(lat, lon, altitudeFt float64) (Wind, error) {
+	w := Wind{Latitude: lat, Longitude: lon, AltitudeFt: altitudeFt}
+
+	type candidate struct {
+		station *Station
+		dist    float64
+	}
+	var near []candidate
+	for i := range f.Stations {
+		s := &f.Stations[i]
+		if !s.Located || len(s.Levels) == 0 {
+			continue
+		}
+		d := geo.DistanceNm(lat, lon, s.Latitude, s.Longitude)
+		if d <= idwMaxDistanceNm {
+			near = append(near, candidate{s, d})
+		}
+	}
+	if len(near) == 0 {
+		return w, fmt.Errorf("no winds aloft station within %d nm", idwMaxDistanceNm)
+	}
+	sort.Slice(near, func(i, j int) bool { return near[i].dist < near[j].dist })
+	if near[0].dist < 1 {
+		near = near[:1]
+	} else if len(near) > idwStations {
+		near = near[:idwStations]
+	}
+
+	var u, v, wsum, temp, tsum float64
+	for _, c := range near {
+		smp := c.station.at(altitudeFt)
+		weight := 1.0
+		if len(near) > 1 {
+			weight = 1 / (c.dist * c.dist)
+		}
+		u += smp.u * weight
+		v += smp.v * weight
+		wsum += weight
+		if smp.hasTemp {
+			temp += smp.temp * weight
+			tsum += weight
+		}
+		w.Stations = append(w.Stations, c.station.Ident)
+	}
+	u, v = u/wsum, v/wsum
+	w.WindSpeedKt = math.Round(math.Hypot(u, v)*10) / 10
+	if w.WindSpeedKt >= 0.5 {
+		w.WindDirDegrees = math.Round(math.Mod(math.Atan2(-u, -v)*180/math.Pi+360, 360))
+	}
+	if tsum > 0 {
+		t := math.Round(temp/tsum*10) / 10
+		w.TempC = &t
+	}
+	return w, nil
+}
+
+// at interpolates a station column to an altitude, holding the wind
+// constant outside the forecast levels
+func (s *Station) at(altitudeFt float64) sample {
+	var smp sample
+	lo, hi, frac := bracket(s.Levels, altitudeFt)
+	u0, v0 := components(s.Levels[lo])
+	u1, v1 := components(s.Levels[hi])
+	smp.u = u0 + (u1-u0)*frac
+	smp.v = v0 + (v1-v0)*frac
+
+	var withTemp []Level
+	for _, l := range s.Levels {
+		if l.TempC != nil {
+			withTemp = append(withTemp, l)
+		}
+	}
+	if len(withTemp) > 0 {
+		lo, hi, frac = bracket(withTemp, altitudeFt)
+		t0, t1 := *withTemp[lo].TempC, *withTemp[hi].TempC
+		smp.temp = t0 + (t1-t0)*frac
+		smp.hasTemp = true
+		if lowest := float64(withTemp[0].AltitudeFt); altitudeFt < lowest {
+			smp.temp = t0 + lapseRateCPer1000*(lowest-altitudeFt)/1000
+		}
+	}
+	return smp
+}
+
+// bracket returns the indexes of the levels either side of an altitude
+// and the fraction of the way from the lower to the upper. Outside the
+// levels both indexes are the nearest end.
+func bracket(levels []Level, altitudeFt float64) (lo, hi int, frac float64) {
+	last := len(levels) - 1
+	if altitudeFt <= float64(levels[0].AltitudeFt) {
+		return 0, 0, 0
+	}
+	if altitudeFt >= float64(levels[last].AltitudeFt) {
+		return last, last, 0
+	}
+	for i := 1; i <= last; i++ {
+		if altitudeFt <= float64(levels[i].AltitudeFt) {
+			a0, a1 := float64(levels[i-1].AltitudeFt), float64(levels[i].AltitudeFt)
+			return i - 1, i, (altitudeFt - a0) / (a1 - a0)
+		}
+	}
+	return last, last, 0
+}
+
+// components converts a wind from a direction into east and north vector
+// components. Light and variable is no wind.
+func components(l Level) (u, v float64) {
+	if l.LightAndVariable {
+		return 0, 0
+	}
+	rad := float64(l.WindDirDegrees) * math.Pi / 180
+	return -float64(l.WindSpeedKt) * math.Sin(rad), -float64(l.WindSpeedKt) * math.Cos(rad)
+}
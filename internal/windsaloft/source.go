@@ -0,0 +1,54 @@
+package windsaloft
+
+import (
+	"go-charts/internal/geojson"
+	"go-charts/internal/weather"
+	"time"
+)
+
+// Source downloads and decodes the FB winds and temperatures aloft text product
+type Source struct {
+	url string
+}
+
+// NewSource returns a winds aloft source reading from url
+func NewSource(url string) *Source {
+	return &Source{url: url}
+}
+
+// Name returns the product name used for messages and files
+func (s *Source) Name() string {
+	return "windsaloft"
+}
+
+// URL returns the download location
+func (s *Source) URL() string {
+	return s.url
+}
+
+// Empty returns a Forecast with no reports
+func (s *Source) Empty() weather.Product {
+	return &Forecast{}
+}
+
+// Decode converts the downloaded text into a Forecast
+func (s *Source) Decode(data []byte) (weather.Product, error) {
+	return Decode(string(data), time.Now().UTC())
+}
+
+// ToGeoJson encodes the located stations as a GeoJSON FeatureCollection
+// of points keyed by station, with the forecast levels as properties
+func (f *Forecast) ToGeoJson() (string, error) {
+	fc := geojson.NewFeatureCollection()
+	for _, s := range f.Stations {
+		if !s.Located {
+			continue
+		}
+		feat, err := geojson.NewFeature(s.Ident, geojson.NewPoint(s.Longitude, s.Latitude), s)
+		if err != nil {
+			return "", err
+		}
+		fc.Features = append(fc.Features, feat)
+	}
+	return fc.Encode()
+}
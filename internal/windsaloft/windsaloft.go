@@ -0,0 +1,118 @@
+package windsaloft
+
+import (
+	"encoding/json"
+	"go-charts/internal/weather"
+	"time"
+)
+
+// Level is the forecast wind and temperature at one altitude. Directions
+// are true. TempC is nil where the product omits the temperature, which
+// it does at 3000 feet and within 2500 feet of the station elevation.
+type Level struct {
+	AltitudeFt       int
+	WindDirDegrees   int
+	WindSpeedKt      int
+	LightAndVariable bool `json:",omitempty"`
+	TempC            *float64
+}
+
+// Station is the column of forecast levels for one FB reporting point.
+// Levels within 1500 feet of the station elevation are not forecast, so
+// the lowest levels may be missing.
+type Station struct {
+	Ident     string
+	Latitude  float64
+	Longitude float64
+	Located   bool
+	ValidTime time.Time
+	Levels    []Level
+}
+
+// Forecast is one decoded FB winds and temperatures aloft product
+type Forecast struct {
+	BasedOn   time.Time
+	ValidTime time.Time
+	UseFrom   time.Time
+	UseTo     time.Time
+	Altitudes []int
+	Stations  []Station
+}
+
+// Locator resolves an FB station identifier to its position
+type Locator interface {
+	Locate(ident string) (lat, lon float64, ok bool)
+}
+
+// Locate positions the stations that are not yet located. It is called
+// when the forecast is published and again when it is queried, so a
+// station that loc could not find at first is placed once it can be. The
+// forecast is shared, so a copy is returned if any station was placed.
+func (f *Forecast) Locate(loc Locator) *Forecast {
+	var located []Station
+	for i, s := range f.Stations {
+		if s.Located {
+			continue
+		}
+		lat, lon, ok := loc.Locate(s.Ident)
+		if !ok {
+			continue
+		}
+		if located == nil {
+			located = append([]Station(nil), f.Stations...)
+		}
+		located[i].Latitude, located[i].Longitude, located[i].Located = lat, lon, true
+	}
+	if located == nil {
+		return f
+	}
+	out := *f
+	out.Stations = located
+	return &out
+}
+
+// Count returns the number of stations in the forecast
+func (f *Forecast) Count() int {
+	return len(f.Stations)
+}
+
+// ToJson returns the station columns, like the other products return their reports
+func (f *Forecast) ToJson() (string, error) {
+	bytes, err := json.Marshal(f.Stations)
+	if err != nil {
+		return "", err
+	}
+	return string(bytes), nil
+}
+
+// FromJson replaces the station columns with those encoded by ToJson.
+// Only the valid time of the product survives, taken from the stations.
+func (f *Forecast) FromJson(data []byte) error {
+	err := json.Unmarshal(data, &f.Stations)
+	if err != nil {
+		return err
+	}
+	if len(f.Stations) > 0 {
+		f.ValidTime = f.Stations[0].ValidTime
+	}
+	return nil
+}
+
+// Records returns the station columns keyed by station
+func (f *Forecast) Records() []weather.Record {
+	records := make([]weather.Record, 0, len(f.Stations))
+	for _, s := range f.Stations {
+		records = append(records, weather.Record{Key: s.Ident, Data: s})
+	}
+	return records
+}
+
+// Level returns the station's forecast at an altitude, if it has one
+func (s *Station) Level(altitudeFt int) (Level, bool) {
+	for _, l := range s.Levels {
+		if l.AltitudeFt == altitudeFt {
+			return l, true
+		}
+	}
+	return Level{}, false
+}
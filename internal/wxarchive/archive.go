@@ -0,0 +1,147 @@
+package wxarchive
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNoSnapshot is returned by Find when nothing was archived for a
+// product at or before the requested time
+var ErrNoSnapshot = errors.New("no archived snapshot")
+
+const (
+	dayLayout  = "2006-01-02"
+	timeLayout = "150405"
+	extension  = ".raw"
+)
+
+// Archive keeps the raw data of every download in one directory per UTC
+// day, as {dir}/2006-01-02/{name}-150405.raw
+type Archive struct {
+	dir       string
+	retention time.Duration
+	mu        sync.Mutex
+}
+
+// New returns an Archive rooted at dir. Days older than retention are
+// pruned on every Save; zero keeps them forever.
+func New(dir string, retention time.Duration) *Archive {
+	return &Archive{dir: dir, retention: retention}
+}
+
+// Save writes the raw data of a product downloaded at t. The file is
+// written to a temporary name and renamed, so a replay never reads a
+// partial snapshot.
+func (a *Archive) Save(name string, t time.Time, data []byte) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	t = t.UTC()
+	day := filepath.Join(a.dir, t.Format(dayLayout))
+	err := os.MkdirAll(day, 0755)
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(day, name+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp, 0644)
+	}
+	if err == nil {
+		err = os.Rename(tmp, filepath.Join(day, name+"-"+t.Format(timeLayout)+extension))
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return a.prune(t)
+}
+
+// prune removes day directories older than the retention. The caller holds a.mu.
+func (a *Archive) prune(now time.Time) error {
+	if a.retention <= 0 {
+		return nil
+	}
+	cutoff := now.Add(-a.retention).Format(dayLayout)
+	for _, day := range a.days() {
+		if day >= cutoff {
+			break
+		}
+		err := os.RemoveAll(filepath.Join(a.dir, day))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// days returns the archived days, oldest first
+func (a *Archive) days() []string {
+	entries, err := os.ReadDir(a.dir)
+	if err != nil {
+		return nil
+	}
+	days := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if _, err := time.Parse(dayLayout, e.Name()); e.IsDir() && err == nil {
+			days = append(days, e.Name())
+		}
+	}
+	sort.Strings(days)
+	return days
+}
+
+// Find returns the path and download time of the latest snapshot of a
+// product at or before t
+func (a *Archive) Find(name string, t time.Time) (string, time.Time, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	t = t.UTC()
+	days := a.days()
+	for i := len(days) - 1; i >= 0; i-- {
+		if days[i] > t.Format(dayLayout) {
+			continue
+		}
+		path, at, ok := a.latest(days[i], name, t)
+		if ok {
+			return path, at, nil
+		}
+	}
+	return "", time.Time{}, ErrNoSnapshot
+}
+
+// latest finds the newest snapshot of a product within one day at or before t
+func (a *Archive) latest(day, name string, t time.Time) (string, time.Time, bool) {
+	entries, err := os.ReadDir(filepath.Join(a.dir, day))
+	if err != nil {
+		return "", time.Time{}, false
+	}
+	var path string
+	var best time.Time
+	for _, e := range entries {
+		stamp := strings.TrimPrefix(e.Name(), name+"-")
+		if stamp == e.Name() || !strings.HasSuffix(stamp, extension) {
+			continue
+		}
+		at, err := time.Parse(dayLayout+timeLayout, day+strings.TrimSuffix(stamp, extension))
+		if err != nil || at.After(t) || !at.After(best) {
+			continue
+		}
+		path = filepath.Join(a.dir, day, e.Name())
+		best = at
+	}
+	return path, best, path != ""
+}
@@ -0,0 +1,90 @@
+package wxarchive
+
+import (
+	"context"
+	"go-charts/internal/weather"
+	"os"
+	"sync"
+	"time"
+)
+
+// Player is a weather.Getter that serves archived snapshots instead of
+// downloading. Its clock starts at the replay start time and runs at
+// speed times real time.
+type Player struct {
+	archive *Archive
+	start   time.Time
+	speed   float64
+	began   time.Time
+
+	mu     sync.Mutex
+	served map[string]string
+	read   map[string]time.Time
+}
+
+// NewPlayer returns a Player replaying archive from start. A speed of
+// zero or less plays in real time.
+func NewPlayer(archive *Archive, start time.Time, speed float64) *Player {
+	if speed <= 0 {
+		speed = 1
+	}
+	return &Player{
+		archive: archive,
+		start:   start.UTC(),
+		speed:   speed,
+		began:   time.Now(),
+		served:  make(map[string]string),
+		read:    make(map[string]time.Time),
+	}
+}
+
+// Now returns the current replay time
+func (p *Player) Now() time.Time {
+	elapsed := time.Since(p.began)
+	return p.start.Add(time.Duration(float64(elapsed) * p.speed))
+}
+
+// Speed returns how many times faster than real time the replay runs
+func (p *Player) Speed() float64 {
+	return p.speed
+}
+
+// Get implements weather.Getter by reading the latest snapshot of the
+// source at the replay time. It returns weather.ErrNotModified while the
+// replay clock has not reached a newer snapshot. The validator is the
+// snapshot path.
+func (p *Player) Get(ctx context.Context, s weather.Source) ([]byte, weather.Validator, error) {
+	path, at, err := p.archive.Find(s.Name(), p.Now())
+	if err != nil {
+		return nil, weather.Validator{}, err
+	}
+	p.mu.Lock()
+	served := p.served[s.Name()]
+	p.mu.Unlock()
+	if served == path {
+		return nil, weather.Validator{}, weather.ErrNotModified
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, weather.Validator{}, err
+	}
+	p.mu.Lock()
+	p.read[s.Name()] = at
+	p.mu.Unlock()
+	return data, weather.Validator{LastModified: path}, nil
+}
+
+// SnapshotTime returns when the snapshot of a source last returned by Get
+// was downloaded, or the zero time if none has been
+func (p *Player) SnapshotTime(name string) time.Time {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.read[name]
+}
+
+// Keep implements weather.Getter by recording the snapshot as served
+func (p *Player) Keep(s weather.Source, v weather.Validator) {
+	p.mu.Lock()
+	p.served[s.Name()] = v.LastModified
+	p.mu.Unlock()
+}
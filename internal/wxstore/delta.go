@@ -0,0 +1,90 @@
+package wxstore
+
+import (
+	"encoding/json"
+	"go-charts/internal/weather"
+	"time"
+)
+
+// Delta is the difference between two consecutive snapshots of a product.
+// Added and Changed hold whole records; Expired holds the keys of records
+// that are no longer in the snapshot. Keys are built the same way by the
+// client from the record fields, see the Records method of each product.
+type Delta struct {
+	Product   string            `json:"type"`
+	From      int64             `json:"-"`
+	To        int64             `json:"-"`
+	FetchTime time.Time         `json:"fetchtime"`
+	Added     []json.RawMessage `json:"added"`
+	Changed   []json.RawMessage `json:"changed"`
+	Expired   []string          `json:"expired"`
+}
+
+// Empty reports whether the snapshots were identical
+func (d *Delta) Empty() bool {
+	return len(d.Added) == 0 && len(d.Changed) == 0 && len(d.Expired) == 0
+}
+
+// ToJson returns the delta as a websocket payload
+func (d *Delta) ToJson() (string, error) {
+	b, err := json.Marshal(d)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// keyedRecords encodes every record of a snapshot by its key
+func keyedRecords(records []weather.Record) (map[string]string, error) {
+	keyed := make(map[string]string, len(records))
+	for _, r := range records {
+		b, err := json.Marshal(r.Data)
+		if err != nil {
+			return nil, err
+		}
+		keyed[r.Key] = string(b)
+	}
+	return keyed, nil
+}
+
+// diff compares a new snapshot with the previous one
+func diff(name string, prev, next map[string]string) *Delta {
+	d := &Delta{Product: name, Added: []json.RawMessage{}, Changed: []json.RawMessage{}, Expired: []string{}}
+	for k, js := range next {
+		old, ok := prev[k]
+		if !ok {
+			d.Added = append(d.Added, json.RawMessage(js))
+		} else if old != js {
+			d.Changed = append(d.Changed, json.RawMessage(js))
+		}
+	}
+	for k := range prev {
+		if _, ok := next[k]; !ok {
+			d.Expired = append(d.Expired, k)
+		}
+	}
+	return d
+}
+
+// Snapshot returns the cached websocket payload for a product, tagged
+// with its fetch time and stale flag, and the version it corresponds to
+func (s *Store) Snapshot(name string) (string, int64, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	snap, ok := s.products[name]
+	if !ok {
+		return "", 0, false
+	}
+	return s.tagged(name, snap), snap.version, true
+}
+
+// Delta returns the change that produced the current version of a product
+func (s *Store) Delta(name string) (Delta, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	snap, ok := s.products[name]
+	if !ok {
+		return Delta{}, false
+	}
+	return *snap.delta, true
+}
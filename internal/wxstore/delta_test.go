@@ -0,0 +1,155 @@
+package wxstore
+
+import (
+	"encoding/json"
+	"go-charts/internal/weather"
+	"sort"
+	"testing"
+	"time"
+)
+
+// keyedProduct is a product whose records are its map entries
+type keyedProduct map[string]string
+
+func (p keyedProduct) ToJson() (string, error) {
+	b, err := json.Marshal(map[string]string(p))
+	return string(b), err
+}
+
+func (p keyedProduct) FromJson(data []byte) error {
+	return json.Unmarshal(data, &p)
+}
+
+func (p keyedProduct) Count() int {
+	return len(p)
+}
+
+func (p keyedProduct) Records() []weather.Record {
+	records := make([]weather.Record, 0, len(p))
+	for k, v := range p {
+		records = append(records, weather.Record{Key: k, Data: v})
+	}
+	return records
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name                    string
+		prev, next              map[string]string
+		added, changed, expired []string
+	}{
+		{
+			name:  "first snapshot",
+			next:  map[string]string{"a": `"1"`, "b": `"2"`},
+			added: []string{`"1"`, `"2"`},
+		},
+		{
+			name: "unchanged",
+			prev: map[string]string{"a": `"1"`},
+			next: map[string]string{"a": `"1"`},
+		},
+		{
+			name:    "changed",
+			prev:    map[string]string{"a": `"1"`, "b": `"2"`},
+			next:    map[string]string{"a": `"1"`, "b": `"3"`},
+			changed: []string{`"3"`},
+		},
+		{
+			name:    "added, changed and expired",
+			prev:    map[string]string{"a": `"1"`, "b": `"2"`, "c": `"3"`},
+			next:    map[string]string{"b": `"4"`, "c": `"3"`, "d": `"5"`},
+			added:   []string{`"5"`},
+			changed: []string{`"4"`},
+			expired: []string{"a"},
+		},
+		{
+			name:    "everything expired",
+			prev:    map[string]string{"a": `"1"`, "b": `"2"`},
+			next:    map[string]string{},
+			expired: []string{"a", "b"},
+		},
+	}
+	for _, tt := range tests {
+		d := diff("test", tt.prev, tt.next)
+		check := func(what string, got, want []string) {
+			sort.Strings(got)
+			if len(got) != len(want) {
+				t.Errorf("%s: %s %q, want %q", tt.name, what, got, want)
+				return
+			}
+			for i := range got {
+				if got[i] != want[i] {
+					t.Errorf("%s: %s %q, want %q", tt.name, what, got, want)
+					return
+				}
+			}
+		}
+		check("added", raw(d.Added), tt.added)
+		check("changed", raw(d.Changed), tt.changed)
+		check("expired", d.Expired, tt.expired)
+		if d.Empty() != (len(tt.added)+len(tt.changed)+len(tt.expired) == 0) {
+			t.Errorf("%s: Empty = %t", tt.name, d.Empty())
+		}
+	}
+}
+
+func raw(messages []json.RawMessage) []string {
+	s := make([]string, len(messages))
+	for i, m := range messages {
+		s[i] = string(m)
+	}
+	return s
+}
+
+func TestStoreVersions(t *testing.T) {
+	fetched := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	steps := []struct {
+		name                    string
+		product                 string
+		p                       keyedProduct
+		version                 int64
+		added, changed, expired int
+	}{
+		{"first metars", "metars", keyedProduct{"a": "1"}, 1, 1, 0, 0},
+		{"unchanged metars", "metars", keyedProduct{"a": "1"}, 2, 0, 0, 0},
+		{"first tafs", "tafs", keyedProduct{"x": "1"}, 1, 1, 0, 0},
+		{"changed metars", "metars", keyedProduct{"a": "2", "b": "1"}, 3, 1, 1, 0},
+		{"expired metars", "metars", keyedProduct{"b": "1"}, 4, 0, 0, 1},
+	}
+	s := New()
+	for i, step := range steps {
+		at := fetched.Add(time.Duration(i) * time.Minute)
+		err := s.SetAt(step.product, step.p, at)
+		if err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		_, version, ok := s.Snapshot(step.product)
+		if !ok || version != step.version {
+			t.Errorf("%s: version %d, want %d", step.name, version, step.version)
+		}
+		d, ok := s.Delta(step.product)
+		if !ok {
+			t.Errorf("%s: no delta", step.name)
+			continue
+		}
+		if d.From != step.version-1 || d.To != step.version {
+			t.Errorf("%s: delta from %d to %d, want %d to %d", step.name, d.From, d.To, step.version-1, step.version)
+		}
+		if !d.FetchTime.Equal(at) {
+			t.Errorf("%s: delta fetch time %v, want %v", step.name, d.FetchTime, at)
+		}
+		if len(d.Added) != step.added || len(d.Changed) != step.changed || len(d.Expired) != step.expired {
+			t.Errorf("%s: delta %d added %d changed %d expired, want %d %d %d", step.name,
+				len(d.Added), len(d.Changed), len(d.Expired), step.added, step.changed, step.expired)
+		}
+	}
+
+	// confirming a snapshot is current moves its fetch time, not its version
+	s.Touch("metars")
+	if _, version, _ := s.Snapshot("metars"); version != 4 {
+		t.Errorf("version %d after Touch, want 4", version)
+	}
+	if _, _, ok := s.Snapshot("pireps"); ok {
+		t.Error("snapshot of a product never set")
+	}
+}
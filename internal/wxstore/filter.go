@@ -0,0 +1,80 @@
+package wxstore
+
+import (
+	"go-charts/internal/geo"
+	"go-charts/internal/metars"
+	"go-charts/internal/pireps"
+	"go-charts/internal/tafs"
+	"strings"
+	"time"
+)
+
+// Filter selects reports by area, station and age. Zero values disable a criterion.
+type Filter struct {
+	BBox      *geo.BBox
+	IDs       map[string]bool
+	CenterLat float64
+	CenterLon float64
+	RadiusNm  float64
+	MaxAge    time.Duration
+	Now       time.Time
+}
+
+// Match reports whether a report with the given identifier, position and
+// time passes the filter. An empty id is never matched by an IDs filter.
+func (f Filter) Match(id string, lat, lon float64, t time.Time) bool {
+	if len(f.IDs) > 0 && !f.IDs[strings.ToUpper(id)] {
+		return false
+	}
+	if f.BBox != nil && !f.BBox.Contains(lat, lon) {
+		return false
+	}
+	if f.RadiusNm > 0 && geo.DistanceNm(f.CenterLat, f.CenterLon, lat, lon) > f.RadiusNm {
+		return false
+	}
+	if f.MaxAge > 0 {
+		now := f.Now
+		if now.IsZero() {
+			now = time.Now()
+		}
+		if now.Sub(t) > f.MaxAge {
+			return false
+		}
+	}
+	return true
+}
+
+// FilterMetars returns the METARs that pass the filter
+func (s *Store) FilterMetars(f Filter) []metars.Metar {
+	out := make([]metars.Metar, 0)
+	for _, m := range s.Metars() {
+		if f.Match(m.StationId, m.Latitude, m.Longitude, m.ObservationTime) {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// FilterTafs returns the TAFs that pass the filter, aged by issue time
+func (s *Store) FilterTafs(f Filter) []tafs.Taf {
+	out := make([]tafs.Taf, 0)
+	for _, t := range s.Tafs() {
+		if f.Match(t.StationId, t.Latitude, t.Longitude, t.IssueTime) {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// FilterPireps returns the PIREPs that pass the filter. PIREPs have no
+// station, so an IDs filter is ignored for them.
+func (s *Store) FilterPireps(f Filter) []pireps.Pirep {
+	f.IDs = nil
+	out := make([]pireps.Pirep, 0)
+	for _, p := range s.Pireps() {
+		if f.Match("", p.Latitude, p.Longitude, p.ObservationTime) {
+			out = append(out, p)
+		}
+	}
+	return out
+}
@@ -0,0 +1,44 @@
+package wxstore
+
+import (
+	"go-charts/internal/geo"
+	"testing"
+	"time"
+)
+
+func TestFilterMatch(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	colorado := &geo.BBox{MinLon: -109, MinLat: 37, MaxLon: -102, MaxLat: 41}
+	pacific := &geo.BBox{MinLon: 170, MinLat: -20, MaxLon: -170, MaxLat: 20}
+	tests := []struct {
+		name     string
+		filter   Filter
+		id       string
+		lat, lon float64
+		at       time.Time
+		want     bool
+	}{
+		{"no criteria", Filter{}, "", 0, 0, time.Time{}, true},
+		{"id listed", Filter{IDs: map[string]bool{"KDEN": true}}, "KDEN", 0, 0, now, true},
+		{"id case folded", Filter{IDs: map[string]bool{"KDEN": true}}, "kden", 0, 0, now, true},
+		{"id not listed", Filter{IDs: map[string]bool{"KDEN": true}}, "KCOS", 0, 0, now, false},
+		{"empty id", Filter{IDs: map[string]bool{"KDEN": true}}, "", 0, 0, now, false},
+		{"inside box", Filter{BBox: colorado}, "KDEN", 39.86, -104.67, now, true},
+		{"outside box", Filter{BBox: colorado}, "KSLC", 40.79, -111.98, now, false},
+		{"outside box across antimeridian", Filter{BBox: pacific}, "PHNL", 21.32, -157.92, now, false},
+		{"inside box across antimeridian", Filter{BBox: pacific}, "NFFN", -17.76, 177.44, now, true},
+		{"inside radius", Filter{CenterLat: 39.86, CenterLon: -104.67, RadiusNm: 70}, "KCOS", 38.81, -104.70, now, true},
+		{"outside radius", Filter{CenterLat: 39.86, CenterLon: -104.67, RadiusNm: 60}, "KCOS", 38.81, -104.70, now, false},
+		{"recent", Filter{MaxAge: time.Hour, Now: now}, "KDEN", 0, 0, now.Add(-59 * time.Minute), true},
+		{"too old", Filter{MaxAge: time.Hour, Now: now}, "KDEN", 0, 0, now.Add(-61 * time.Minute), false},
+		{"all criteria", Filter{IDs: map[string]bool{"KDEN": true}, BBox: colorado, MaxAge: time.Hour, Now: now},
+			"KDEN", 39.86, -104.67, now.Add(-10 * time.Minute), true},
+		{"all but one criterion", Filter{IDs: map[string]bool{"KDEN": true}, BBox: colorado, MaxAge: time.Hour, Now: now},
+			"KDEN", 39.86, -104.67, now.Add(-2 * time.Hour), false},
+	}
+	for _, tt := range tests {
+		if got := tt.filter.Match(tt.id, tt.lat, tt.lon, tt.at); got != tt.want {
+			t.Errorf("%s: Match = %t, want %t", tt.name, got, tt.want)
+		}
+	}
+}
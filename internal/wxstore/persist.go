@@ -0,0 +1,99 @@
+package wxstore
+
+import (
+	"encoding/json"
+	"go-charts/internal/weather"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Persister writes store payloads to disk and reloads them at startup so
+// a restart has weather to serve before the first download completes
+type Persister struct {
+	dir string
+}
+
+// NewPersister returns a Persister writing {name}.json files into dir
+func NewPersister(dir string) *Persister {
+	return &Persister{dir: dir}
+}
+
+// Save writes the cached payload for a product. The file is written to a
+// temporary name and renamed over the old one, so a reader never sees a
+// missing or truncated file.
+func (p *Persister) Save(s *Store, name string) error {
+	payload, ok := s.Payload(name)
+	if !ok {
+		return nil
+	}
+	err := os.MkdirAll(p.dir, 0755)
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(p.dir, name+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	_, err = f.WriteString(payload)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp, 0644)
+	}
+	if err == nil {
+		err = os.Rename(tmp, p.path(name))
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
+
+// Load fills the store from any previously saved payloads of the given
+// sources. A payload that can't be read is logged and skipped, so one
+// damaged file doesn't lose the others.
+func (p *Persister) Load(s *Store, sources []weather.Source) {
+	for _, src := range sources {
+		name := src.Name()
+		var saved map[string]json.RawMessage
+		modTime, err := p.read(name, &saved)
+		if err == nil && !modTime.IsZero() {
+			product := src.Empty()
+			err = product.FromJson(saved[name])
+			if err == nil {
+				err = s.SetAt(name, product, modTime)
+			}
+		}
+		if err != nil {
+			log.Println("Error loading saved", name, err)
+		}
+	}
+}
+
+// read decodes a saved payload and returns the time it was written, or a
+// zero time if there is no saved payload
+func (p *Persister) read(name string, v interface{}) (time.Time, error) {
+	fi, err := os.Stat(p.path(name))
+	if os.IsNotExist(err) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	data, err := os.ReadFile(p.path(name))
+	if err != nil {
+		return time.Time{}, err
+	}
+	return fi.ModTime().UTC(), json.Unmarshal(data, v)
+}
+
+func (p *Persister) path(name string) string {
+	return filepath.Join(p.dir, name+".json")
+}
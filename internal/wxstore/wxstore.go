@@ -0,0 +1,350 @@
+package wxstore
+
+import (
+	"go-charts/internal/airsigmets"
+	"go-charts/internal/metars"
+	"go-charts/internal/notams"
+	"go-charts/internal/pireps"
+	"go-charts/internal/tafs"
+	"go-charts/internal/tfrs"
+	"go-charts/internal/weather"
+	"go-charts/internal/windsaloft"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Store holds the most recent successful download of each weather product.
+// Each product is replaced whole, so readers always see a complete snapshot,
+// and a failed download leaves the previous one in place.
+type Store struct {
+	mu         sync.RWMutex
+	now        func() time.Time
+	staleAfter map[string]time.Duration
+	products   map[string]*snapshot
+}
+
+// snapshot is one download of a product with everything derived from it.
+// A snapshot is never modified once stored, apart from its fetch time.
+type snapshot struct {
+	product  weather.Product
+	payload  string
+	fetched  time.Time
+	records  map[string]string
+	version  int64
+	delta    *Delta
+	stations map[string][]int
+}
+
+// stationReports is implemented by products whose reports belong to a
+// station, so the store can index them by station
+type stationReports interface {
+	Count() int
+	StationReport(i int) (station string, t time.Time)
+}
+
+// New returns an empty Store
+func New() *Store {
+	return &Store{
+		now:        func() time.Time { return time.Now().UTC() },
+		staleAfter: make(map[string]time.Duration),
+		products:   make(map[string]*snapshot),
+	}
+}
+
+// SetClock replaces the clock that fetch times and staleness are measured
+// by, as a replay does with its own
+func (s *Store) SetClock(now func() time.Time) {
+	s.mu.Lock()
+	s.now = now
+	s.mu.Unlock()
+}
+
+// Set replaces the snapshot for the named product, fetched now. It has the
+// signature of weather.Publisher so it can be handed straight to the
+// download pipeline.
+func (s *Store) Set(name string, p weather.Product) error {
+	s.mu.RLock()
+	now := s.now()
+	s.mu.RUnlock()
+	return s.SetAt(name, p, now)
+}
+
+// SetAt replaces the snapshot for the named product, recording when it was
+// fetched. The payload, records and station index are built first and
+// swapped in together, so a reader never sees them out of step.
+func (s *Store) SetAt(name string, p weather.Product, fetched time.Time) error {
+	js, err := p.ToJson()
+	if err != nil {
+		return err
+	}
+	records, err := keyedRecords(p.Records())
+	if err != nil {
+		return err
+	}
+	next := &snapshot{
+		product:  p,
+		payload:  js,
+		fetched:  fetched.UTC(),
+		records:  records,
+		stations: stationIndex(p),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var prev map[string]string
+	if old, ok := s.products[name]; ok {
+		prev = old.records
+		next.version = old.version
+	}
+	next.delta = diff(name, prev, records)
+	next.delta.From = next.version
+	next.delta.To = next.version + 1
+	next.delta.FetchTime = next.fetched
+	next.version++
+	s.products[name] = next
+	return nil
+}
+
+// stationIndex lists the reports of each station, newest first, or returns
+// nil for a product without stations
+func stationIndex(p weather.Product) map[string][]int {
+	r, ok := p.(stationReports)
+	if !ok {
+		return nil
+	}
+	index := make(map[string][]int)
+	for i := 0; i < r.Count(); i++ {
+		station, _ := r.StationReport(i)
+		index[station] = append(index[station], i)
+	}
+	for _, reports := range index {
+		sort.SliceStable(reports, func(i, j int) bool {
+			_, ti := r.StationReport(reports[i])
+			_, tj := r.StationReport(reports[j])
+			return ti.After(tj)
+		})
+	}
+	return index
+}
+
+// Touch records that the server confirmed the current snapshot of a
+// product is still up to date, without replacing it
+func (s *Store) Touch(name string) {
+	s.mu.Lock()
+	if snap, ok := s.products[name]; ok {
+		snap.fetched = s.now()
+	}
+	s.mu.Unlock()
+}
+
+// SetStaleAfter sets how long after its fetch time a product is reported
+// as stale. Zero never reports it as stale.
+func (s *Store) SetStaleAfter(name string, d time.Duration) {
+	s.mu.Lock()
+	s.staleAfter[name] = d
+	s.mu.Unlock()
+}
+
+// Stale reports whether a product was last fetched longer ago than the
+// stale age
+func (s *Store) Stale(name string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.stale(name, s.now())
+}
+
+func (s *Store) stale(name string, now time.Time) bool {
+	snap, ok := s.products[name]
+	after := s.staleAfter[name]
+	return ok && after > 0 && now.Sub(snap.fetched) > after
+}
+
+// tagged wraps an encoded product as a websocket payload with its fetch
+// time and stale flag. The caller holds s.mu.
+func (s *Store) tagged(name string, snap *snapshot) string {
+	stale := "false"
+	if s.stale(name, s.now()) {
+		stale = "true"
+	}
+	return "{ \"" + name + "\": " + snap.payload + ", \"fetchtime\": \"" + snap.fetched.Format(time.RFC3339) + "\", \"stale\": " + stale + "}"
+}
+
+// Payload returns the cached product as it is saved to disk, without the
+// fetch time and stale tags
+func (s *Store) Payload(name string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	snap, ok := s.products[name]
+	if !ok {
+		return "", false
+	}
+	return "{ \"" + name + "\": " + snap.payload + "}", true
+}
+
+// FetchTime returns when the product was last downloaded or confirmed unchanged
+func (s *Store) FetchTime(name string) time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if snap, ok := s.products[name]; ok {
+		return snap.fetched
+	}
+	return time.Time{}
+}
+
+// Count returns the number of records in the current snapshot of a product
+func (s *Store) Count(name string) int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if snap, ok := s.products[name]; ok {
+		return len(snap.records)
+	}
+	return 0
+}
+
+// product returns the current snapshot of a product and its station
+// index, or nil before the first download
+func (s *Store) product(name string) (weather.Product, map[string][]int) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	snap, ok := s.products[name]
+	if !ok {
+		return nil, nil
+	}
+	return snap.product, snap.stations
+}
+
+// Metars returns every METAR in the current snapshot
+func (s *Store) Metars() []metars.Metar {
+	p, _ := s.product("metars")
+	if r, ok := p.(*metars.Response); ok {
+		return r.Data.Metars
+	}
+	return nil
+}
+
+// Metar returns the latest METAR for a station
+func (s *Store) Metar(station string) (metars.Metar, bool) {
+	obs := s.MetarsFor(station)
+	if len(obs) == 0 {
+		return metars.Metar{}, false
+	}
+	return obs[0], true
+}
+
+// MetarsFor returns every METAR for a station, newest first
+func (s *Store) MetarsFor(station string) []metars.Metar {
+	p, index := s.product("metars")
+	r, ok := p.(*metars.Response)
+	if !ok {
+		return nil
+	}
+	var obs []metars.Metar
+	for _, i := range index[station] {
+		obs = append(obs, r.Data.Metars[i])
+	}
+	return obs
+}
+
+// Tafs returns every TAF in the current snapshot
+func (s *Store) Tafs() []tafs.Taf {
+	p, _ := s.product("tafs")
+	if r, ok := p.(*tafs.Response); ok {
+		return r.Data.Tafs
+	}
+	return nil
+}
+
+// Taf returns the latest TAF for a station
+func (s *Store) Taf(station string) (tafs.Taf, bool) {
+	p, index := s.product("tafs")
+	r, ok := p.(*tafs.Response)
+	if !ok || len(index[station]) == 0 {
+		return tafs.Taf{}, false
+	}
+	return r.Data.Tafs[index[station][0]], true
+}
+
+// Pireps returns every PIREP in the current snapshot, newest first
+func (s *Store) Pireps() []pireps.Pirep {
+	p, _ := s.product("pireps")
+	if r, ok := p.(*pireps.Response); ok {
+		return r.Data.Pireps
+	}
+	return nil
+}
+
+// AirSigmets returns every AIRMET, SIGMET and Convective SIGMET in the current snapshot
+func (s *Store) AirSigmets() []airsigmets.AirSigmet {
+	p, _ := s.product("airsigmets")
+	if r, ok := p.(*airsigmets.Response); ok {
+		return r.Data.AirSigmets
+	}
+	return nil
+}
+
+// GAirmets returns every G-AIRMET snapshot in the current download
+func (s *Store) GAirmets() []airsigmets.GAirmet {
+	p, _ := s.product("gairmets")
+	if r, ok := p.(*airsigmets.GAirmetResponse); ok {
+		return r.Data.GAirmets
+	}
+	return nil
+}
+
+// WindsAloft returns the current winds aloft forecast, or nil before the first download
+func (s *Store) WindsAloft() *windsaloft.Forecast {
+	p, _ := s.product("windsaloft")
+	f, _ := p.(*windsaloft.Forecast)
+	return f
+}
+
+// Notams returns every NOTAM in the current snapshot, including those
+// that have not started yet
+func (s *Store) Notams() []notams.Notam {
+	p, _ := s.product("notams")
+	if r, ok := p.(*notams.Response); ok {
+		return r.Notams
+	}
+	return nil
+}
+
+// NotamsFor returns the NOTAMs for an airport that are in effect at t
+func (s *Store) NotamsFor(airport string, t time.Time) []notams.Notam {
+	out := make([]notams.Notam, 0)
+	for _, n := range s.Notams() {
+		if n.AppliesTo(airport) && n.Active(t) {
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+// Tfrs returns every TFR in the current snapshot, including those that
+// have not started yet
+func (s *Store) Tfrs() []tfrs.Tfr {
+	p, _ := s.product("tfrs")
+	if r, ok := p.(*tfrs.Response); ok {
+		return r.Tfrs
+	}
+	return nil
+}
+
+// Locate positions a station from the METAR and TAF snapshots. Three
+// letter identifiers, as used by FB and PIREP locations, are also tried
+// with the K and P ICAO prefixes.
+func (s *Store) Locate(ident string) (lat, lon float64, ok bool) {
+	candidates := []string{ident}
+	if len(ident) == 3 {
+		candidates = append(candidates, "K"+ident, "P"+ident)
+	}
+	for _, id := range candidates {
+		if m, ok := s.Metar(id); ok {
+			return m.Latitude, m.Longitude, true
+		}
+		if t, ok := s.Taf(id); ok {
+			return t.Latitude, t.Longitude, true
+		}
+	}
+	return 0, 0, false
+}
@@ -3,12 +3,27 @@ package main
 import (
 	"bytes"
 	"compress/gzip"
+	"context"
 	"database/sql"
 	"encoding/json"
+	"flag"
 	"fmt"
+	"go-charts/internal/airports"
+	"go-charts/internal/airsigmets"
+	"go-charts/internal/alerts"
+	"go-charts/internal/geo"
+	"go-charts/internal/metarhistory"
 	"go-charts/internal/metars"
+	"go-charts/internal/metrics"
+	"go-charts/internal/notams"
 	"go-charts/internal/pireps"
+	"go-charts/internal/stations"
 	"go-charts/internal/tafs"
+	"go-charts/internal/tfrs"
+	"go-charts/internal/weather"
+	"go-charts/internal/windsaloft"
+	"go-charts/internal/wxarchive"
+	"go-charts/internal/wxstore"
 	"io/ioutil"
 	"log"
 	"math"
@@ -53,7 +68,7 @@ func handleConfig(w http.ResponseWriter, r *http.Request) {
 
 // handlePositionHistory returns the last saved position information to the client
 func handlePositionHistory(w http.ResponseWriter, r *http.Request) {
-	path := "./static/positionhistory.db"
+	path := positionHistoryDb
 	db, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
 	if err != nil {
 		log.Fatal(err)
@@ -74,7 +89,16 @@ func handlePositionHistory(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// handleSaveHistory saves POST'd position data to the positionhistory.db sqlite database
+// positionHistoryDb is the sqlite database of saved ownship positions
+const positionHistoryDb = "./static/positionhistory.db"
+
+// lastPosition is the latest ownship position posted by a client
+var lastPosition positionHistory
+var lastPositionTime time.Time
+var lastPositionMutex = sync.Mutex{}
+
+// handleSaveHistory checks POST'd position data against active TFRs and, when
+// savepositionhistory is set, saves it to the positionhistory.db sqlite database
 func handleSaveHistory(w http.ResponseWriter, r *http.Request) {
 	var ph positionHistory
 	d := json.NewDecoder(r.Body)
@@ -82,7 +106,14 @@ func handleSaveHistory(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		log.Println(err)
 	} else {
-		path := "./static/positionhistory.db"
+		lastPositionMutex.Lock()
+		lastPosition = ph
+		lastPositionTime = time.Now().UTC()
+		lastPositionMutex.Unlock()
+		checkTfrAlerts(ph)
+	}
+	if err == nil && config.Savepositionhistory {
+		path := positionHistoryDb
 		db, err := sql.Open("sqlite3", "file:"+path+"?mode=rw")
 		if err != nil {
 			log.Println(err)
@@ -92,7 +123,10 @@ func handleSaveHistory(w http.ResponseWriter, r *http.Request) {
 
 		_, err = db.Exec(sql)
 		if err != nil {
+			historyInsertsTotal.Inc("error")
 			log.Println(err)
+		} else {
+			historyInsertsTotal.Inc("ok")
 		}
 	}
 	w.WriteHeader(200)
@@ -107,58 +141,196 @@ func handleAirports(w http.ResponseWriter, r *http.Request) {
 	return
 }
 
+// airportsFile lists the airports shown on the map and their runways
+const airportsFile = "./static/airports.json"
+
 // getAirports go routine to read the airports.json file and send to client via websocket
 func getAirports(cid string) {
-	filehandlerMutex.Lock()
-	data, err := os.ReadFile("./static/airports.json") // For read access.
+	data, err := os.ReadFile(airportsFile) // For read access.
 	if err != nil {
 		log.Fatal(err)
 	}
-	filehandlerMutex.Unlock()
 	var msg jsonMessage
 	msg.MessageType = "airports"
 	msg.Payload = string(data)
 	sendToClient(msg, cid)
 }
 
-// handleWeatherDataFiles calls the 3 weather download goroutines and sends data to client via websocket
+// handleWeatherDataFiles sends the current in-memory weather snapshots to the
+// client via websocket. A client that already holds the current version of a
+// product is kept up to date by deltas and is not sent it again.
 func handleWeatherDataFiles(w http.ResponseWriter, r *http.Request) {
-	filehandlerMutex.Lock()
 	parts := strings.Split(r.RequestURI, "/")
 	cid := parts[len(parts)-1]
-	// read and send METARS json
-	data, err := os.ReadFile("./workfiles/metars.json")
+	clientVersionsMutex.Lock()
+	defer clientVersionsMutex.Unlock()
+	for _, src := range wxPipeline.Sources() {
+		sendSnapshot(src.Name(), cid)
+	}
+}
+
+// clientVersions records, per client, the version of each weather product it holds
+var clientVersions = make(map[string]map[string]int64)
+var clientVersionsMutex = sync.Mutex{}
+
+// sendSnapshot sends a full product snapshot unless the client already has
+// it. The caller holds clientVersionsMutex.
+func sendSnapshot(name, cid string) {
+	payload, version, ok := wxStore.Snapshot(name)
+	if !ok {
+		log.Printf("No %s data available yet", name)
+		return
+	}
+	versions := clientVersions[cid]
+	if v, ok := versions[name]; ok && v == version {
+		return
+	}
+	var msg jsonMessage
+	msg.MessageType = name
+	msg.Payload = payload
+	// a client whose socket is not open yet, or just failed, holds nothing
+	if !sendToClient(msg, cid) {
+		return
+	}
+	if versions == nil {
+		versions = make(map[string]int64)
+		clientVersions[cid] = versions
+	}
+	versions[name] = version
+}
+
+// broadcastDelta pushes the latest change of a product to every client that
+// holds the previous version. Clients that fell further behind get a full
+// snapshot instead; clients that never asked for weather get nothing.
+func broadcastDelta(name string) {
+	d, ok := wxStore.Delta(name)
+	if !ok {
+		return
+	}
+	payload, err := d.ToJson()
 	if err != nil {
 		log.Println(err)
-	} else {
-		var msgmetars jsonMessage
-		msgmetars.MessageType = "metars"
-		msgmetars.Payload = string(data)
-		sendToClient(msgmetars, cid)
+		return
 	}
+	clientVersionsMutex.Lock()
+	defer clientVersionsMutex.Unlock()
+	for cid, versions := range clientVersions {
+		v, ok := versions[name]
+		if !ok || v == d.To {
+			continue
+		}
+		if v != d.From {
+			sendSnapshot(name, cid)
+			continue
+		}
+		if !d.Empty() {
+			var msg jsonMessage
+			msg.MessageType = config.Messagetypes.Delta.Type
+			msg.Payload = payload
+			if !sendToClient(msg, cid) {
+				// the socket is gone, a reconnect starts from a snapshot
+				delete(clientVersions, cid)
+				continue
+			}
+		}
+		versions[name] = d.To
+	}
+}
 
-	// read and send TAFS json
-	data, err = os.ReadFile("./workfiles/tafs.json")
+// broadcastFetchTime tells clients holding the current version of a product
+// that it was confirmed unchanged, so they don't mark it stale
+func broadcastFetchTime(name string) {
+	_, version, ok := wxStore.Snapshot(name)
+	if !ok {
+		return
+	}
+	d := wxstore.Delta{Product: name, FetchTime: wxStore.FetchTime(name),
+		Added: []json.RawMessage{}, Changed: []json.RawMessage{}, Expired: []string{}}
+	payload, err := d.ToJson()
 	if err != nil {
 		log.Println(err)
-	} else {
-		var msgtafs jsonMessage
-		msgtafs.MessageType = "tafs"
-		msgtafs.Payload = string(data)
-		sendToClient(msgtafs, cid)
+		return
+	}
+	clientVersionsMutex.Lock()
+	defer clientVersionsMutex.Unlock()
+	for cid, versions := range clientVersions {
+		if v, ok := versions[name]; ok && v == version {
+			var msg jsonMessage
+			msg.MessageType = config.Messagetypes.Delta.Type
+			msg.Payload = payload
+			sendToClient(msg, cid)
+		}
 	}
+}
+
+// forgetClient drops the weather versions and alert subscriptions of a
+// disconnected client
+func forgetClient(cid string) {
+	clientVersionsMutex.Lock()
+	delete(clientVersions, cid)
+	clientVersionsMutex.Unlock()
+	alertSubscriptionsMutex.Lock()
+	for _, subs := range alertSubscriptions {
+		delete(subs, cid)
+	}
+	alertSubscriptionsMutex.Unlock()
+}
 
-	// read and send PIREPS json
-	data, err = os.ReadFile("./workfiles/pireps.json")
+// lastFix is the previous ownship position, used to derive the track and
+// ground speed that are projected into TFRs
+var lastFix *tfrs.Track
+var alertedTfrs = make(map[string]bool)
+var tfrAlertMutex = sync.Mutex{}
+
+// checkTfrAlerts alerts every client when ownship is inside, or its track
+// projects into, an active TFR. Each TFR is alerted once when projected
+// and again on entering it, until the track no longer conflicts.
+func checkTfrAlerts(ph positionHistory) {
+	if config.TfrsURL == "" {
+		return
+	}
+	t, err := time.Parse(time.RFC3339, ph.ReportTime)
 	if err != nil {
-		log.Println(err)
-	} else {
-		var msgpireps jsonMessage
-		msgpireps.MessageType = "pireps"
-		msgpireps.Payload = string(data)
-		sendToClient(msgpireps, cid)
+		t = time.Now().UTC()
+	}
+	fix := tfrs.Track{Latitude: ph.Latitude, Longitude: ph.Longitude, AltitudeFt: float64(ph.Altitude), Time: t}
+
+	tfrAlertMutex.Lock()
+	defer tfrAlertMutex.Unlock()
+	if lastFix != nil {
+		// fixes more than a few minutes apart don't say where we are heading
+		elapsed := fix.Time.Sub(lastFix.Time)
+		if elapsed > 0 && elapsed < 5*time.Minute {
+			dist := geo.DistanceNm(lastFix.Latitude, lastFix.Longitude, fix.Latitude, fix.Longitude)
+			fix.GroundSpeedKt = dist / elapsed.Hours()
+			fix.TrackDeg = geo.BearingDeg(lastFix.Latitude, lastFix.Longitude, fix.Latitude, fix.Longitude)
+		}
+	}
+	lastFix = &fix
+
+	minutes := config.Tfralertminutes
+	if minutes <= 0 {
+		minutes = 10
+	}
+	alerted := make(map[string]bool)
+	for _, c := range tfrs.Conflicts(wxStore.Tfrs(), fix, time.Duration(minutes)*time.Minute) {
+		key := c.Id
+		if c.Inside {
+			key += " inside"
+		}
+		alerted[key] = true
+		if alertedTfrs[key] {
+			continue
+		}
+		payload, err := json.Marshal(c)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		log.Printf("TFR alert %s, inside %v, %.0f minutes ahead", c.Id, c.Inside, c.MinutesAhead)
+		broadcastToClients(jsonMessage{MessageType: config.Messagetypes.Tfralert.Type, Payload: string(payload)})
 	}
-	filehandlerMutex.Unlock()
+	alertedTfrs = alerted
 }
 
 type mbTileConnectionCacheEntry struct {
@@ -188,26 +360,46 @@ func newMbTileConnectionCacheEntry(path string, conn *sql.DB) *mbTileConnectionC
 var mbtileCacheLock = sync.Mutex{}
 var mbtileConnectionCache = make(map[string]mbTileConnectionCacheEntry)
 
-func connectMbTilesArchive(path string) (*sql.DB, map[string]string, error) {
+// connectMbTilesArchive returns the open connection to an MBTiles file and
+// its metadata, opening it if it isn't cached or has changed on disk.
+// cached reports whether the cached connection was used.
+func connectMbTilesArchive(path string) (db *sql.DB, meta map[string]string, cached bool, err error) {
 	mbtileCacheLock.Lock()
 	defer mbtileCacheLock.Unlock()
 	if conn, ok := mbtileConnectionCache[path]; ok {
 		if !conn.IsOutdated() {
-			return conn.Conn, conn.Metadata, nil
+			return conn.Conn, conn.Metadata, true, nil
 		}
 		log.Printf("Reloading MBTiles " + path)
 	}
 
 	conn, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, false, err
 	}
 	cacheEntry := newMbTileConnectionCacheEntry(path, conn)
+	if cacheEntry == nil {
+		conn.Close()
+		return nil, nil, false, fmt.Errorf("tileset %s not found", path)
+	}
 	cacheEntry.Metadata = readMbTilesMetadata(path, conn)
-	if cacheEntry != nil {
-		mbtileConnectionCache[path] = *cacheEntry
+	mbtileConnectionCache[path] = *cacheEntry
+	return conn, cacheEntry.Metadata, false, nil
+}
+
+// tilesetLabel returns the tileset name to label tile metrics with. Only
+// tilesets that have been opened from the data dir are named, so requests
+// for arbitrary paths can't add new series.
+func tilesetLabel(file string) string {
+	if strings.ContainsAny(file, "/\\") {
+		return "unknown"
+	}
+	mbtileCacheLock.Lock()
+	defer mbtileCacheLock.Unlock()
+	if _, ok := mbtileConnectionCache["./static/data/"+file]; ok {
+		return file
 	}
-	return conn, cacheEntry.Metadata, nil
+	return "unknown"
 }
 
 func tileToDegree(z, x, y int) (lon, lat float64) {
@@ -255,10 +447,21 @@ func readMbTilesMetadata(fname string, db *sql.DB) map[string]string {
 
 // handleTilesets scans data dir for all .db and .mbtiles files and returns json representation of all metadata values
 func handleTilesets(w http.ResponseWriter, r *http.Request) {
-	files, err := ioutil.ReadDir("./static/data/")
+	result, err := readTilesets()
 	if err != nil {
 		log.Printf("handleTilesets() error: %s\n", err.Error())
 		http.Error(w, err.Error(), 500)
+		return
+	}
+	resJSON, _ := json.Marshal(result)
+	w.Write(resJSON)
+}
+
+// readTilesets returns the metadata of every tileset in the data dir, keyed by file name
+func readTilesets() (map[string]map[string]string, error) {
+	files, err := ioutil.ReadDir("./static/data/")
+	if err != nil {
+		return nil, err
 	}
 	result := make(map[string]map[string]string, 0)
 	for _, f := range files {
@@ -266,7 +469,7 @@ func handleTilesets(w http.ResponseWriter, r *http.Request) {
 			continue
 		}
 		if strings.HasSuffix(f.Name(), ".mbtiles") || strings.HasSuffix(f.Name(), ".db") {
-			_, meta, err := connectMbTilesArchive("./static/data/" + f.Name())
+			_, meta, _, err := connectMbTilesArchive("./static/data/" + f.Name())
 			if err != nil {
 				log.Printf("SQLite open "+f.Name()+" failed: %s", err.Error())
 				continue
@@ -274,15 +477,19 @@ func handleTilesets(w http.ResponseWriter, r *http.Request) {
 			result[f.Name()] = meta
 		}
 	}
-	resJSON, _ := json.Marshal(result)
-	w.Write(resJSON)
+	return result, nil
 }
 
 func loadTile(fname string, z, x, y int) ([]byte, error) {
-	db, meta, err := connectMbTilesArchive("./static/data/" + fname)
+	db, meta, cached, err := connectMbTilesArchive("./static/data/" + fname)
 	if err != nil {
 		return nil, err
 	}
+	if cached {
+		tileCacheTotal.Inc("hit")
+	} else {
+		tileCacheTotal.Inc("miss")
+	}
 	rows, err := db.Query("SELECT tile_data FROM tiles WHERE zoom_level=? AND tile_column=? AND tile_row=?", z, x, y)
 	if err != nil {
 		log.Printf("Failed to query mbtiles: %s", err.Error())
@@ -327,12 +534,18 @@ func handleTile(w http.ResponseWriter, r *http.Request) {
 	idx--
 	file, _ := url.QueryUnescape(parts[idx])
 
+	began := time.Now()
 	tileData, err := loadTile(file, z, x, y)
+	label := tilesetLabel(file)
+	tileSeconds.Observe(time.Since(began).Seconds(), label)
 	if err != nil {
+		tileRequestsTotal.Inc(label, "error")
 		http.Error(w, err.Error(), 500)
 	} else if tileData == nil {
+		tileRequestsTotal.Inc(label, "notfound")
 		http.Error(w, "Tile not found", 404)
 	} else {
+		tileRequestsTotal.Inc(label, "ok")
 		w.Write(tileData)
 	}
 }
@@ -348,65 +561,521 @@ func setJSONHeaders(w http.ResponseWriter) {
 	w.Header().Set("Content-Type", "application/json")
 }
 
-var filehandlerMutex = sync.Mutex{}
+// startTime is when the server started, for the uptime in /api/status
+var startTime = time.Now().UTC()
 
-func downloadDataFiles() {
-	filehandlerMutex.Lock()
+var metricsRegistry = metrics.NewRegistry()
 
-	var wg sync.WaitGroup
-	wg.Add(3)
+var tileRequestsTotal = metricsRegistry.NewCounter("gocharts_tile_requests_total",
+	"Tile requests by tileset and result (ok, notfound or error). Tilesets that aren't in the data dir are labelled unknown.", "tileset", "result")
+var tileSeconds = metricsRegistry.NewHistogram("gocharts_tile_request_duration_seconds",
+	"Time to read a tile from its MBTiles file.",
+	[]float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1}, "tileset")
+var tileCacheTotal = metricsRegistry.NewCounter("gocharts_tile_cache_requests_total",
+	"MBTiles connection cache lookups by tile requests, by result (hit when the tileset was already open, miss when it had to be opened).", "result")
+var downloadsTotal = metricsRegistry.NewCounter("gocharts_weather_downloads_total",
+	"Weather downloads by source and result (ok, notmodified, empty or error).", "source", "result")
+var downloadSeconds = metricsRegistry.NewHistogram("gocharts_weather_download_duration_seconds",
+	"Time to download, decode and publish a weather source.",
+	[]float64{.1, .25, .5, 1, 2.5, 5, 10, 30, 60, 120, 300}, "source")
+var wsMessagesTotal = metricsRegistry.NewCounter("gocharts_websocket_messages_sent_total",
+	"Websocket messages sent to clients by message type.", "type")
+var wsSendErrorsTotal = metricsRegistry.NewCounter("gocharts_websocket_send_errors_total",
+	"Websocket messages that could not be sent.")
+var historyInsertsTotal = metricsRegistry.NewCounter("gocharts_position_history_inserts_total",
+	"Position history inserts by result (ok or error).", "result")
 
-	go func() {
-		var mr metars.Response
-		err := mr.SaveAsJSONFile(config.MetarsURL)
+// registerMetricGauges adds the metrics that are read from the server
+// state at each scrape
+func registerMetricGauges() {
+	metricsRegistry.NewGauge("gocharts_weather_records", "Records in the current snapshot of each weather source.",
+		func() []metrics.Sample {
+			var samples []metrics.Sample
+			for _, src := range wxPipeline.Sources() {
+				samples = append(samples, metrics.Sample{Labels: []string{src.Name()}, Value: float64(wxStore.Count(src.Name()))})
+			}
+			return samples
+		}, "source")
+	metricsRegistry.NewGauge("gocharts_weather_fetch_age_seconds", "Seconds since each weather source was last fetched.",
+		func() []metrics.Sample {
+			var samples []metrics.Sample
+			now := time.Now()
+			for _, src := range wxPipeline.Sources() {
+				if t := wxStore.FetchTime(src.Name()); !t.IsZero() {
+					samples = append(samples, metrics.Sample{Labels: []string{src.Name()}, Value: now.Sub(t).Seconds()})
+				}
+			}
+			return samples
+		}, "source")
+	metricsRegistry.NewGauge("gocharts_websocket_clients", "Connected websocket clients.",
+		func() []metrics.Sample {
+			clientsMutex.Lock()
+			defer clientsMutex.Unlock()
+			return []metrics.Sample{{Value: float64(len(clients))}}
+		})
+	metricsRegistry.NewGauge("gocharts_start_time_seconds", "Unix time the server started.",
+		func() []metrics.Sample {
+			return []metrics.Sample{{Value: float64(startTime.Unix())}}
+		})
+}
+
+var wxStore = wxstore.New()
+var wxPersister *wxstore.Persister
+var wxPipeline = weather.NewPipeline(weather.NewFetcher(), publishWeather)
+
+// registerWeatherSources adds the configured weather products to the download pipeline
+func registerWeatherSources() {
+	wxPipeline.Register(metars.NewSource(config.MetarsURL, config.MetarsFormat))
+	wxPipeline.Register(tafs.NewSource(config.TafsURL, config.TafsFormat))
+	wxPipeline.Register(pireps.NewSource(config.PirepsURL, config.PirepsFormat))
+	// the national METAR, TAF and PIREP files are never empty, so an empty
+	// download keeps the last good snapshot instead of clearing the map
+	wxPipeline.Require("metars", "tafs", "pireps")
+	if config.AirsigmetsURL != "" {
+		wxPipeline.Register(airsigmets.NewSource(config.AirsigmetsURL, config.AirsigmetsFormat))
+	}
+	if config.GairmetsURL != "" {
+		wxPipeline.Register(airsigmets.NewGAirmetSource(config.GairmetsURL, config.GairmetsFormat))
+	}
+	if config.WindsaloftURL != "" {
+		wxPipeline.Register(windsaloft.NewSource(config.WindsaloftURL))
+	}
+	if config.NotamsURL != "" {
+		wxPipeline.Register(notams.NewSource(config.NotamsURL, stationLocator{}, weatherNow))
+	}
+	if config.TfrsURL != "" {
+		wxPipeline.Register(tfrs.NewSource(config.TfrsURL, weatherNow))
+	}
+}
+
+// stationLocator positions an identifier from the station list, falling
+// back to the METAR and TAF snapshots
+type stationLocator struct{}
+
+// Locate implements notams.Locator and windsaloft.Locator
+func (stationLocator) Locate(ident string) (lat, lon float64, ok bool) {
+	candidates := []string{ident}
+	if len(ident) == 3 {
+		candidates = append(candidates, "K"+ident, "P"+ident)
+	}
+	for _, id := range candidates {
+		if s, ok := stationTable.Lookup(id); ok {
+			return s.Latitude, s.Longitude, true
+		}
+	}
+	return wxStore.Locate(ident)
+}
+
+// loadPersistedWeather enables the optional on-disk copy of the weather
+// store and preloads it so clients have data before the first download
+func loadPersistedWeather() {
+	// a replay must neither show nor overwrite the current weather
+	if !config.Persistweather || wxPlayer != nil {
+		return
+	}
+	dir := config.Weatherworkdir
+	if dir == "" {
+		dir = "./workfiles"
+	}
+	wxPersister = wxstore.NewPersister(dir)
+	wxPersister.Load(wxStore, wxPipeline.Sources())
+}
+
+var wxArchive *wxarchive.Archive
+var wxPlayer *wxarchive.Player
+
+// weatherNow returns the time the weather is current at: the replay clock
+// while replaying, otherwise the system clock
+func weatherNow() time.Time {
+	if wxPlayer != nil {
+		return wxPlayer.Now()
+	}
+	return time.Now().UTC()
+}
+
+var replayFlag = flag.String("replay", "", "replay archived weather from this RFC3339 time instead of downloading")
+var replaySpeedFlag = flag.Int("replayspeed", 0, "replay speed as a multiple of real time")
+
+// openWeatherArchive records every weather download into the archive
+// directory or, in replay mode, serves the archive in place of the network
+func openWeatherArchive() {
+	start := config.Replaystart
+	if *replayFlag != "" {
+		start = *replayFlag
+	}
+	speed := config.Replayspeed
+	if *replaySpeedFlag > 0 {
+		speed = *replaySpeedFlag
+	}
+	if config.Archivedir == "" {
+		if start != "" {
+			log.Println("Replay needs an archivedir, downloading live weather")
+		}
+		return
+	}
+	if start == "" {
+		retention := time.Duration(config.Archivedays) * 24 * time.Hour
+		wxArchive = wxarchive.New(config.Archivedir, retention)
+		wxPipeline.SetGetter(archivingGetter{wxPipeline.Getter()})
+		return
+	}
+	t, err := time.Parse(time.RFC3339, start)
+	if err != nil {
+		log.Fatal("Invalid replay start time ", err)
+	}
+	// never prune while replaying, the archive is all there is
+	wxArchive = wxarchive.New(config.Archivedir, 0)
+	wxPlayer = wxarchive.NewPlayer(wxArchive, t, float64(speed))
+	wxPipeline.SetGetter(wxPlayer)
+	wxStore.SetClock(wxPlayer.Now)
+	log.Printf("Replaying weather from %s at %.0fx speed", t.UTC().Format(time.RFC3339), wxPlayer.Speed())
+}
+
+// archivingGetter saves the raw data of every successful download to the
+// weather archive
+type archivingGetter struct {
+	weather.Getter
+}
+
+// Get implements weather.Getter
+func (g archivingGetter) Get(ctx context.Context, s weather.Source) ([]byte, weather.Validator, error) {
+	data, v, err := g.Getter.Get(ctx, s)
+	if err == nil {
+		if err := wxArchive.Save(s.Name(), time.Now().UTC(), data); err != nil {
+			log.Printf("Error archiving %s %v", s.Name(), err)
+		}
+	}
+	return data, v, err
+}
+
+var metarArchive *metarhistory.Archive
+
+// openMetarHistory opens the optional METAR archive database
+func openMetarHistory() {
+	if config.Metarhistorydb == "" {
+		return
+	}
+	retention := time.Duration(config.Metarhistoryhours) * time.Hour
+	archive, err := metarhistory.Open(config.Metarhistorydb, retention)
+	if err != nil {
+		log.Println("Error opening METAR history", err)
+		return
+	}
+	metarArchive = archive
+}
+
+var runwayIndex *airports.RunwayIndex
+
+// loadRunways reads the runways in the airport file, used for METAR wind components
+func loadRunways() {
+	idx, err := airports.LoadRunways(airportsFile)
+	if err != nil {
+		log.Println("Error loading runways, wind components disabled", err)
+		return
+	}
+	runwayIndex = idx
+}
+
+var stationTable *stations.Table
+var stationsPipeline = weather.NewPipeline(weather.NewFetcher(), publishStations)
+
+// openStations opens the optional station database and downloads the
+// station list when it is empty or older than the refresh interval
+func openStations() {
+	if config.Stationsdb == "" {
+		return
+	}
+	table, err := stations.Open(config.Stationsdb)
+	if err != nil {
+		log.Println("Error opening station database, station names disabled", err)
+		return
+	}
+	stationTable = table
+	if config.StationsURL == "" {
+		return
+	}
+	stationsPipeline.Register(stations.NewSource(config.StationsURL, config.StationsFormat))
+	stationsPipeline.Require("stations")
+	if time.Since(stationTable.Updated()) > stationsRefreshInterval() {
+		refreshStations()
+	}
+	go timedStationsRefresh()
+}
+
+// stationsRefreshInterval returns how often the station list is downloaded
+func stationsRefreshInterval() time.Duration {
+	hours := config.Stationsrefreshhours
+	if hours <= 0 {
+		hours = 24
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// refreshStations downloads the station list into the station table
+func refreshStations() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	for _, src := range stationsPipeline.Sources() {
+		err := stationsPipeline.Update(ctx, src)
+		if err == weather.ErrNotModified {
+			log.Printf("%s not modified since last download", src.Name())
+		} else if err == weather.ErrEmpty {
+			log.Printf("%s download was empty, keeping the current table", src.Name())
+		} else if err != nil {
+			log.Printf("Error downloading %s file %v", src.Name(), err)
+		}
+	}
+}
+
+func timedStationsRefresh() {
+	ticker := time.NewTicker(stationsRefreshInterval())
+	for range ticker.C {
+		refreshStations()
+	}
+}
+
+// publishStations replaces the station table with a freshly decoded list
+func publishStations(name string, p weather.Product) error {
+	r, ok := p.(*stations.Response)
+	if !ok {
+		return fmt.Errorf("unexpected %s product %T", name, p)
+	}
+	err := stationTable.Replace(r.Data.Stations)
+	if err != nil {
+		return err
+	}
+	log.Printf("Loaded %d stations", stationTable.Count())
+	return nil
+}
+
+// publishWeather swaps a freshly decoded product into the weather store
+func publishWeather(name string, p weather.Product) error {
+	switch r := p.(type) {
+	case *metars.Response:
+		r.FillDerived(runwayIndex)
+		r.FillStations(stationTable)
+	case *tafs.Response:
+		r.FillStations(stationTable)
+	case *windsaloft.Forecast:
+		p = r.Locate(stationLocator{})
+	}
+	// a replayed snapshot was fetched when it was archived
+	fetched := time.Now().UTC()
+	if wxPlayer != nil {
+		fetched = wxPlayer.SnapshotTime(name)
+	}
+	err := wxStore.SetAt(name, p, fetched)
+	if err != nil {
+		return err
+	}
+	if wxPersister != nil {
+		err = wxPersister.Save(wxStore, name)
 		if err != nil {
-			log.Println("Error downloading Metars file", err)
+			log.Printf("Error saving %s to disk %v", name, err)
 		}
-		wg.Done()
-	}()
+	}
+	// replayed METARs are already in the history
+	if r, ok := p.(*metars.Response); ok && metarArchive != nil && wxPlayer == nil {
+		n, err := metarArchive.Add(r.Data.Metars)
+		if err != nil {
+			log.Println("Error archiving METARs", err)
+		} else {
+			log.Printf("Archived %d new METARs", n)
+		}
+	}
+	broadcastDelta(name)
+	return nil
+}
+
+var wxScheduler *weather.Scheduler
+
+// scheduleWeatherSources starts downloading every weather source on its own schedule
+func scheduleWeatherSources() {
+	wxScheduler = weather.NewScheduler(wxPipeline, weatherUpdated)
+	for _, src := range wxPipeline.Sources() {
+		sched := weatherSchedule(src.Name())
+		log.Printf("Downloading %s every %s", src.Name(), sched.Interval)
+		wxScheduler.Add(src, sched)
+		// a product is only stale once it has missed a download
+		staleAfter := time.Duration(config.Staleminutes) * time.Minute
+		if config.Staleminutes > 0 && staleAfter < 2*sched.Interval {
+			staleAfter = 2 * sched.Interval
+		}
+		wxStore.SetStaleAfter(src.Name(), staleAfter)
+	}
+	wxScheduler.Start(context.Background())
+}
+
+// weatherSchedule returns the configured download schedule of a product.
+// Products without one download every wxupdateintervalmsec. A replay
+// faster than real time runs proportionally more often, so it keeps up
+// with the replay clock.
+func weatherSchedule(name string) weather.Schedule {
+	sched := weather.Schedule{Interval: time.Duration(config.Wxupdateintervalmsec) * time.Millisecond}
+	sched.Jitter = sched.Interval / 10
+	if rs, ok := config.Refreshschedules[name]; ok && rs.Intervalseconds > 0 {
+		sched.Interval = time.Duration(rs.Intervalseconds) * time.Second
+		sched.Jitter = time.Duration(rs.Jitterseconds) * time.Second
+		sched.MaxBackoff = time.Duration(rs.Maxbackoffseconds) * time.Second
+	}
+	if wxPlayer != nil && sched.Interval > 0 {
+		sched.Interval = time.Duration(float64(sched.Interval) / wxPlayer.Speed())
+		if sched.Interval < 10*time.Second {
+			sched.Interval = 10 * time.Second
+		}
+		sched.Jitter = 0
+	}
+	return sched
+}
+
+// lastUpdateOK records, per source, whether its last update succeeded or
+// was confirmed unchanged after a success
+var lastUpdateOK = make(map[string]bool)
+var lastUpdateMutex = sync.Mutex{}
+
+// weatherUpdated logs the result of each weather download and checks the
+// alert rules against any new data
+func weatherUpdated(name string, err error, took time.Duration) {
+	downloadSeconds.Observe(took.Seconds(), name)
+	lastUpdateMutex.Lock()
+	wasOK := lastUpdateOK[name]
+	lastUpdateOK[name] = err == nil || (err == weather.ErrNotModified && wasOK)
+	lastUpdateMutex.Unlock()
+	switch err {
+	case nil:
+		downloadsTotal.Inc(name, "ok")
+		// replayed weather must not raise alerts about the present
+		if wxPlayer == nil {
+			evaluateAlertRules()
+		}
+	case weather.ErrNotModified:
+		downloadsTotal.Inc(name, "notmodified")
+		log.Printf("%s not modified since last download", name)
+		// only a snapshot the server just confirmed is as new as it was
+		// when published; after a failure it keeps ageing. A replayed
+		// snapshot keeps the time it was archived.
+		if wasOK && wxPlayer == nil {
+			wxStore.Touch(name)
+			broadcastFetchTime(name)
+		}
+	case weather.ErrEmpty:
+		downloadsTotal.Inc(name, "empty")
+		log.Printf("%s download was empty, keeping the last good data", name)
+	default:
+		downloadsTotal.Inc(name, "error")
+		log.Printf("Error downloading %s file %v", name, err)
+	}
+}
+
+var alertEngine = alerts.NewEngine()
 
-	go func() {
-		var tr tafs.Response
-		err := tr.SaveAsJSONFile(config.TafsURL)
+// alertSubscriptions records, per rule, the clients that receive its alerts
+var alertSubscriptions = make(map[string]map[string]bool)
+var alertSubscriptionsMutex = sync.Mutex{}
+
+// loadAlertRules adds the rules from the configuration file. No client
+// subscribes to them, so they always alert every client.
+func loadAlertRules() {
+	for _, rule := range config.Alertrules {
+		rule.Broadcast = true
+		_, err := alertEngine.Add(rule)
 		if err != nil {
-			log.Println("Error downloading Tafs file", err)
+			log.Println("Error in alert rule", err)
 		}
-		wg.Done()
-	}()
+	}
+}
 
-	go func() {
-		var pr pireps.Response
-		err := pr.SaveAsJSONFile(config.PirepsURL)
+// evaluateAlertRules checks the rules against the freshly downloaded
+// weather and sends each alert to the clients that want it
+func evaluateAlertRules() {
+	for _, a := range alertEngine.Evaluate(wxStore, time.Now().UTC()) {
+		rule, ok := alertEngine.Rule(a.RuleId)
+		if !ok {
+			continue
+		}
+		payload, err := json.Marshal(a)
 		if err != nil {
-			log.Println("Error downloading Pireps file", err)
+			log.Println(err)
+			continue
 		}
-		wg.Done()
-	}()
-	wg.Wait()
+		log.Printf("Alert %s: %s", a.RuleId, a.Message)
+		msg := jsonMessage{MessageType: config.Messagetypes.Alert.Type, Payload: string(payload)}
+		if rule.Broadcast {
+			broadcastToClients(msg)
+			continue
+		}
+		alertSubscriptionsMutex.Lock()
+		var cids []string
+		for cid := range alertSubscriptions[a.RuleId] {
+			cids = append(cids, cid)
+		}
+		alertSubscriptionsMutex.Unlock()
+		for _, cid := range cids {
+			sendToClient(msg, cid)
+		}
+	}
+}
 
-	filehandlerMutex.Unlock()
+// subscribeAlerts sends the alerts of a rule to a client
+func subscribeAlerts(ruleId, cid string) {
+	alertSubscriptionsMutex.Lock()
+	defer alertSubscriptionsMutex.Unlock()
+	subs := alertSubscriptions[ruleId]
+	if subs == nil {
+		subs = make(map[string]bool)
+		alertSubscriptions[ruleId] = subs
+	}
+	subs[cid] = true
 }
 
-func timedDataFileDownload() {
-	ticker := time.NewTicker(6 * time.Minute)
-	for range ticker.C {
-		downloadDataFiles()
+// unsubscribeAlerts stops sending the alerts of a rule to a client. An
+// empty client id drops every subscription to the rule.
+func unsubscribeAlerts(ruleId, cid string) {
+	alertSubscriptionsMutex.Lock()
+	defer alertSubscriptionsMutex.Unlock()
+	if cid == "" {
+		delete(alertSubscriptions, ruleId)
+		return
 	}
+	delete(alertSubscriptions[ruleId], cid)
 }
 
-func sendToClient(message jsonMessage, cid string) {
+// sendToClient writes a message to the client's websocket and reports
+// whether it was delivered
+func sendToClient(message jsonMessage, cid string) bool {
+	clientsMutex.Lock()
+	defer clientsMutex.Unlock()
+	delivered := false
 	for client := range clients {
 		if client.ID == cid {
 			log.Printf("Sending data to client %s", cid)
 			err := client.WriteJSON(message)
+			wsMessagesTotal.Inc(message.MessageType)
 			if err != nil {
+				wsSendErrorsTotal.Inc()
 				// the user left the page, or their connection dropped
 				log.Println(err)
 				_ = client.Close()
 				delete(clients, client)
+				continue
 			}
+			delivered = true
 		}
 	}
+	return delivered
+}
+
+// broadcastToClients sends a message to every connected client
+func broadcastToClients(message jsonMessage) {
+	clientsMutex.Lock()
+	cids := make([]string, 0, len(clients))
+	for client := range clients {
+		cids = append(cids, client.ID)
+	}
+	clientsMutex.Unlock()
+	for _, cid := range cids {
+		sendToClient(message, cid)
+	}
 }
 
 var upgradeConnection = websocket.Upgrader{
@@ -419,7 +1088,9 @@ type webSocketConnection struct {
 	ID string
 }
 
-var clients = make(map[webSocketConnection]string)
+// clients holds each open websocket with the time it connected
+var clients = make(map[webSocketConnection]time.Time)
+var clientsMutex = sync.Mutex{}
 
 func handleWsEndpoint(w http.ResponseWriter, r *http.Request) {
 	parts := strings.Split(r.RequestURI, "/")
@@ -429,7 +1100,9 @@ func handleWsEndpoint(w http.ResponseWriter, r *http.Request) {
 		log.Println(err)
 	}
 	conn := webSocketConnection{Conn: ws, ID: cid}
-	clients[conn] = ""
+	clientsMutex.Lock()
+	clients[conn] = time.Now().UTC()
+	clientsMutex.Unlock()
 	log.Printf("Websocket connected to client %s", cid)
 	go listenForWs(&conn)
 }
@@ -448,7 +1121,10 @@ func readLoop(conn *webSocketConnection) {
 	for {
 		if _, r, err := conn.NextReader(); err != nil {
 			conn.Close()
+			clientsMutex.Lock()
 			delete(clients, *conn)
+			clientsMutex.Unlock()
+			forgetClient(conn.ID)
 			log.Println("Client closed endpoint")
 			break
 		} else {
@@ -471,15 +1147,46 @@ func main() {
 	http.HandleFunc("/getdatafiles/", handleWeatherDataFiles)
 	http.HandleFunc("/getairports/", handleAirports)
 	http.HandleFunc("/savehistory", handleSaveHistory)
+	http.HandleFunc("/api/metars", handleApiMetars)
+	http.HandleFunc("/api/metars/", handleApiMetarStation)
+	http.HandleFunc("/api/tafs", handleApiTafs)
+	http.HandleFunc("/api/tafs/", handleApiTafStation)
+	http.HandleFunc("/api/pireps", handleApiPireps)
+	http.HandleFunc("/api/airsigmets", handleApiAirSigmets)
+	http.HandleFunc("/api/gairmets", handleApiGAirmets)
+	http.HandleFunc("/api/windsaloft", handleApiWindsAloft)
+	http.HandleFunc("/api/stations", handleApiStations)
+	http.HandleFunc("/api/notams", handleApiNotams)
+	http.HandleFunc("/api/notams/", handleApiAirportNotams)
+	http.HandleFunc("/api/tfrs", handleApiTfrs)
+	http.HandleFunc("/api/alerts", handleApiAlerts)
+	http.HandleFunc("/api/alerts/rules", handleApiAlertRules)
+	http.HandleFunc("/api/alerts/rules/", handleApiAlertRule)
+	http.HandleFunc("/api/stations/", handleApiStation)
+	http.HandleFunc("/api/status", handleApiStatus)
+	http.HandleFunc("/metrics", handleMetrics)
+	http.HandleFunc("/api/sources", handleApiSources)
+	http.HandleFunc("/api/sources/", handleApiSourceRefresh)
 	http.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir("./static/"))))
 
+	flag.Parse()
 	err := LoadConfig()
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	downloadDataFiles()
-	go timedDataFileDownload()
+	openMetarHistory()
+	loadRunways()
+	openStations()
+	loadAlertRules()
+	registerWeatherSources()
+	openWeatherArchive()
+	loadPersistedWeather()
+	scheduleWeatherSources()
+	registerMetricGauges()
+	if config.Getgpsfromstratux {
+		go timedStratuxProbe()
+	}
 
 	addr := fmt.Sprintf(":%d", config.Httpport)
 	log.Printf("Starting web server on port %s", addr)
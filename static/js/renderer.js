@@ -24,6 +24,7 @@ let URL_GET_CONFIG          = `${URL_SERVER}/getconfig`;
 let URL_PUT_HISTORY         = `${URL_SERVER}/savehistory`;
 let URL_GET_HELIPORTS       = `${URL_SERVER}/getheliports`;
 let URL_GET_DATAFILES       = `${URL_SERVER}/getdatafiles/${CID}`;
+let URL_ALERT_RULES         = `${URL_SERVER}/api/alerts/rules`;
 let URL_GET_AIRPORTS        = `${URL_SERVER}/getairports/${CID}`;
 
 
@@ -105,6 +106,11 @@ let metarMarkers = [];
 let airportFeatures = new ol.Collection();
 let tafFeatures = new ol.Collection();
 let pirepFeatures = new ol.Collection();
+let airsigmetFeatures = new ol.Collection();
+let gairmetFeatures = new ol.Collection();
+let windsaloftFeatures = new ol.Collection();
+let notamFeatures = new ol.Collection();
+let tfrFeatures = new ol.Collection();
 
 /**
  * Vector sources
@@ -113,6 +119,11 @@ let metarVectorSource;
 let airportVectorSource;
 let tafVectorSource;
 let pirepVectorSource;
+let airsigmetVectorSource;
+let gairmetVectorSource;
+let windsaloftVectorSource;
+let notamVectorSource;
+let tfrVectorSource;
 let animatedWxTileSource;
 
 /**
@@ -122,6 +133,16 @@ let airportVectorLayer;
 let metarVectorLayer;
 let tafVectorLayer;
 let pirepVectorLayer;
+let airsigmetVectorLayer;
+let gairmetVectorLayer;
+let windsaloftVectorLayer;
+let notamVectorLayer;
+let tfrVectorLayer;
+
+/**
+ * The winds aloft layer shows the forecast at this altitude in feet
+ */
+let windsAloftDisplayAltitude = 9000;
 
 /**
  * Tile layers
@@ -226,13 +247,45 @@ let regionmap = new Map();
                     processAirports(payload);
                     break;
                 case MessageTypes.metars.type:
-                    processMetars(payload);
+                    storeWeatherSnapshot(message.MessageType, payload);
+                    processMetars();
                     break;
                 case MessageTypes.tafs.type:
-                    processTafs(payload);
+                    storeWeatherSnapshot(message.MessageType, payload);
+                    processTafs();
                     break;
                 case MessageTypes.pireps.type:
-                    processPireps(payload);
+                    storeWeatherSnapshot(message.MessageType, payload);
+                    processPireps();
+                    break;
+                case MessageTypes.airsigmets.type:
+                    storeWeatherSnapshot(message.MessageType, payload);
+                    processAirSigmets(payload);
+                    break;
+                case MessageTypes.gairmets.type:
+                    storeWeatherSnapshot(message.MessageType, payload);
+                    processGAirmets(payload);
+                    break;
+                case MessageTypes.tfrs.type:
+                    storeWeatherSnapshot(message.MessageType, payload);
+                    processTfrs(payload);
+                    break;
+                case MessageTypes.tfralert.type:
+                    displayTfrAlert(payload);
+                    break;
+                case MessageTypes.alert.type:
+                    displayWeatherAlert(payload);
+                    break;
+                case MessageTypes.notams.type:
+                    storeWeatherSnapshot(message.MessageType, payload);
+                    processNotams(payload);
+                    break;
+                case MessageTypes.windsaloft.type:
+                    storeWeatherSnapshot(message.MessageType, payload);
+                    processWindsAloft(payload);
+                    break;
+                case MessageTypes.delta.type:
+                    processWeatherDelta(payload);
                     break;
             }
         }
@@ -245,6 +298,10 @@ let regionmap = new Map();
             console.log(`Websocket CONNECTED ${evt}`);
             wsOpen = true;
             keepAlive();
+            // the server sends airports and weather over this socket, so
+            // only ask for them once it is open
+            getAirports();
+            runTimedDataRequest();
         }
         
         websock.onclose = function(evt) {
@@ -252,9 +309,6 @@ let regionmap = new Map();
             wsOpen = false;
             console.log("Websocket CLOSED.");
         }
-
-        getAirports();
-        runTimedDataRequest();
     }
     catch (error) {
         console.log(error);
@@ -322,6 +376,14 @@ let heliportMarker = new ol.style.Icon({
 const tafStyle = new ol.style.Style({
     image: tafMarker
 })
+const notamStyle = new ol.style.Style({
+    image: new ol.style.RegularShape({
+        fill: new ol.style.Fill({ color: "rgba(255, 140, 0, 0.8)" }),
+        stroke: new ol.style.Stroke({ color: "#000000", width: 1 }),
+        points: 3,
+        radius: 8
+    })
+});
 const airportStyle = new ol.style.Style({
     image: airportMarker
 });
@@ -569,6 +631,18 @@ map.on('click', (evt) => {
             else if (datatype === "pirep") {
                 displayPirepPopup(feature);
             }
+            else if (datatype === "advisory") {
+                displayAdvisoryPopup(feature);
+            }
+            else if (datatype === "tfr") {
+                displayTfrPopup(feature);
+            }
+            else if (datatype === "notam") {
+                displayNotamPopup(feature);
+            }
+            else if (datatype === "windsaloft") {
+                displayWindsAloftPopup(feature);
+            }
             else { // simple airport marker
                 displayAirportPopup(feature);
             }
@@ -661,9 +735,10 @@ map.on('click', (evt) => {
             break;
     }
     if (ident != "undefined") {
-        let name = getFormattedAirportName(ident);
+        let name = getFormattedAirportName(ident, metar.Station);
         let html = `<div id="#featurepopup"><pre><code><p>`
         html +=    `${css}${name}\n${ident} - ${cat}</label><p></p>`;
+        html += getStaleWarningHtml("metars");
         html +=   (time != "" && time != "undefined") ? `Time:&nbsp<b>${time}</b><br/>` : "";
         html +=   (temp != "" && temp != "undefined") ? `Temp:&nbsp<b>${tempC} °C</b> (${temp})<br/>` : "";
         html +=   (dewp != "" && dewp != "undefined") ?`Dewpoint:&nbsp<b>${dewpC} °C</b> (${dewp})<br/>` : "";
@@ -675,6 +750,7 @@ map.on('click', (evt) => {
         html += (wxcode != "" && wxcode != "undefined") ? `Weather:&nbsp<b>${wxcode}</b><br/>`: "";
         html += (skyconditions != undefined && skyconditions != "") ? `${skyconditions}` : "";
         html += (icingconditions != undefined && icingconditions != "") ? `${icingconditions}` : "";
+        html += decodeDerivedValues(metar.Derived);
         html += `</p></code></pre><span class="windsvg">${svg}</span>`;
         html += `<textarea class="rawdata">${rawmetar}</textarea><br />`; 
         html += `<p><button class="ol-popup-closer" onclick="closePopup()">close</button></p></div>`;
@@ -682,6 +758,41 @@ map.on('click', (evt) => {
     }
 }
 
+/**
+ * Create the html for the server computed performance values of a metar
+ * @param {object} derived: the Derived object of the metar, may be undefined
+ * @returns html string
+ */
+function decodeDerivedValues(derived) {
+    if (derived === null || derived === undefined) {
+        return "";
+    }
+    let html = "";
+    if (derived.PressureAltitudeFt !== undefined) {
+        html += `Pressure Altitude:&nbsp<b>${derived.PressureAltitudeFt}&nbspft</b><br/>`;
+    }
+    if (derived.DensityAltitudeFt !== undefined) {
+        html += `Density Altitude:&nbsp<b>${derived.DensityAltitudeFt}&nbspft</b><br/>`;
+    }
+    if (derived.RelativeHumidityPct !== undefined) {
+        html += `Relative Humidity:&nbsp<b>${derived.RelativeHumidityPct}%</b><br/>`;
+    }
+    if (derived.TempDewpointSpreadC !== undefined) {
+        html += `Temp/Dewpoint Spread:&nbsp<b>${derived.TempDewpointSpreadC} °C</b><br/>`;
+    }
+    if (derived.EstCloudBaseFtAGL !== undefined) {
+        html += `Est. Cloud Base:&nbsp<b>${derived.EstCloudBaseFtAGL}&nbspft AGL</b><br/>`;
+    }
+    if (derived.RunwayWinds !== undefined) {
+        derived.RunwayWinds.forEach((rw) => {
+            let head = rw.HeadwindKt >= 0 ? `${rw.HeadwindKt} head` : `${-rw.HeadwindKt} tail`;
+            let cross = `${Math.abs(rw.CrosswindKt)} ${rw.CrosswindKt >= 0 ? "R" : "L"}`;
+            html += `Rwy ${rw.Runway}:&nbsp<b>${head}, ${cross} xwind</b><br/>`;
+        });
+    }
+    return html;
+}
+
 /**
  * Create the html for a TAF popup element
  * @param {feature} ol.Feature: the taf feature the user clicked on
@@ -690,8 +801,10 @@ function displayTafPopup(feature) {
     let taf = feature.get("taf");
     let rawtaf = taf["RawText"];
     let forecasts = taf.Forecast;
+    let name = getFormattedAirportName(taf.StationId, taf.Station).replace(/\n/g, ", ");
+    let title = name !== "" ? `${name} - ${feature.get("ident")}` : feature.get("ident");
     let outerhtml = `<div class="taftitle">` + 
-                        `<label class="taftitlelabel">Terminal Area Forecast - ${feature.get("ident")}</label>` +
+                        `<label class="taftitlelabel">Terminal Area Forecast - ${title}</label>` +
                     `</div>` +
                     `<div class="taf">` + 
                         `<pre><code>` +
@@ -743,6 +856,7 @@ function displayTafPopup(feature) {
                     case "WxString":
                     case "NotDecoded":
                     case "ValidTime":
+                    case "FlightCategory":
                         html += parseForecastField(field, value);
                         break;
                     case "Temperature":
@@ -769,7 +883,7 @@ function displayTafPopup(feature) {
         html += "</p><hr>";
     });
     
-    html += `</p></div><textarea class="rawdata">${rawtaf}</textarea><br />`;
+    html += `</p></div>${getStaleWarningHtml("tafs")}<textarea class="rawdata">${rawtaf}</textarea><br />`;
     html += `<p><button class="ol-popup-closer" onclick="closePopup()">close</button></p></div>`;
     let innerhtml = outerhtml.replace("###", html);
     popupcontent.innerHTML = innerhtml;
@@ -817,6 +931,7 @@ function parseForecastField(rawfieldname, fieldvalue) {
         case "WxString":
         case "Weather":
         case "VisibilityStatuteMi":
+        case "FlightCategory":
             if (fieldname === "WxString" || fieldname === "Weather") {
                 formattedvalue = decodeWxDescriptions(fieldvalue);
                 html = `<label class="tafwxlabel">${fieldname}: <b>${formattedvalue}</b></label><br />`;
@@ -927,6 +1042,9 @@ function parseForecastField(rawfieldname, fieldvalue) {
                     let lineval = decodeWxDescriptions(pirepvalue);
                     html += `<label class="pirepitem">${fieldname}: <b>${lineval}</b></label><br />`;
                     break;
+                case "Remarks":
+                    html += `<label class="pirepitem">${fieldname}: <b>${pirepvalue}</b></label><br />`;
+                    break;
                 case "ChangeIndicator":
                     let change = getSkyConditionDescription(pirepvalue);
                     html += `<label class="pirepitem">${fieldname}: <b>${change}</b></label><br />`;
@@ -943,7 +1061,7 @@ function parseForecastField(rawfieldname, fieldvalue) {
             }
         }
     }
-    html += `</p></div><textarea class="rawdata">${rawpirep}</textarea>`;
+    html += `</p></div>${getStaleWarningHtml("pireps")}<textarea class="rawdata">${rawpirep}</textarea>`;
     html += `<p><button class="ol-popup-closer" onclick="closePopup()">close</button></p></div>`;
     let innerhtml = outerhtml.replace("###", html);
     popupcontent.innerHTML = innerhtml;
@@ -1158,23 +1276,187 @@ function displayAirportPopup(feature) {
     let name = getFormattedAirportName(ident)
     let html = `<div id="#featurepopup"><pre><code><p>`;
         html += `<label class="airportpopuplabel">${name} - ${ident}</label><p></p>`;
+        html += getAirportNotamsHtml(ident);
         html += `</p></code></pre></div>`;
         html += `<p><button class="ol-airport-closer" onclick="closePopup()">close</button></p>`;
     popupcontent.innerHTML = html; 
 }
 
+/**
+ * The records of the last weather snapshot for each product, keyed the
+ * same way the server keys its deltas
+ */
+let weatherRecords = {
+    metars: new Map(),
+    tafs: new Map(),
+    pireps: new Map(),
+    airsigmets: new Map(),
+    gairmets: new Map(),
+    windsaloft: new Map(),
+    notams: new Map(),
+    tfrs: new Map()
+};
+
+/**
+ * The time the server last fetched each weather product
+ */
+let weatherFetchTimes = {};
+
+/**
+ * Build the key the server uses to identify a weather record
+ * @param {string} type: metars, tafs or pireps
+ * @param {object} record: the weather record
+ * @returns {string} the record key
+ */
+function weatherRecordKey(type, record) {
+    switch (type) {
+        case "metars":
+            return `${record.StationId} ${record.ObservationTime}`;
+        case "tafs":
+            return `${record.StationId} ${record.IssueTime}`;
+        case "pireps":
+            return `${record.ObservationTime} ${record.RawText}`;
+        case "airsigmets":
+            return `${record.ValidTimeFrom} ${record.RawText}`;
+        case "gairmets":
+            return `${record.Product} ${record.Tag} ${record.Hazard.Type} ${record.ValidTime}`;
+        case "windsaloft":
+            return record.Ident;
+        case "notams":
+        case "tfrs":
+            return record.Id;
+    }
+    return "";
+}
+
+/**
+ * Replace the stored records of a product with a full snapshot
+ * @param {string} type: metars, tafs or pireps
+ * @param {object} payload: JSON object holding the record array under type
+ */
+function storeWeatherSnapshot(type, payload) {
+    let records = payload[type];
+    let stored = new Map();
+    if (records !== undefined && records !== null) {
+        records.forEach((record) => {
+            stored.set(weatherRecordKey(type, record), record);
+        });
+    }
+    weatherRecords[type] = stored;
+    if (payload.fetchtime !== undefined) {
+        weatherFetchTimes[type] = new Date(payload.fetchtime);
+    }
+}
+
+/**
+ * Create the html warning that a product has not been refreshed within
+ * config.staleminutes, or twice its refresh interval if that is longer.
+ * Age is measured here rather than taken from the server's stale flag,
+ * so the warning appears even when the connection to the server is lost.
+ * @param {string} type: metars, tafs or pireps
+ * @returns {string} html, or an empty string while the product is fresh
+ */
+function getStaleWarningHtml(type) {
+    let fetched = weatherFetchTimes[type];
+    if (fetched === undefined || !config.staleminutes) {
+        return "";
+    }
+    let staleminutes = config.staleminutes;
+    let schedule = config.refreshschedules ? config.refreshschedules[type] : undefined;
+    if (schedule !== undefined && schedule.intervalseconds / 30 > staleminutes) {
+        staleminutes = schedule.intervalseconds / 30;
+    }
+    let minutes = Math.floor((Date.now() - fetched.getTime()) / 60000);
+    if (minutes <= staleminutes) {
+        return "";
+    }
+    return `<label class="stalewarning">STALE - ${type.toUpperCase()} fetched ${minutes} minutes ago</label><br/>`;
+}
+
+/**
+ * Apply the added, changed and expired records of a delta to the stored
+ * snapshot and redraw that product
+ * @param {object} delta: JSON object with type, added, changed and expired
+ */
+function processWeatherDelta(delta) {
+    let stored = weatherRecords[delta.type];
+    if (stored === undefined) {
+        return;
+    }
+    if (delta.fetchtime !== undefined) {
+        weatherFetchTimes[delta.type] = new Date(delta.fetchtime);
+    }
+    if (delta.added.length == 0 && delta.changed.length == 0 && delta.expired.length == 0) {
+        return;
+    }
+    delta.expired.forEach((key) => {
+        stored.delete(key);
+    });
+    delta.added.concat(delta.changed).forEach((record) => {
+        stored.set(weatherRecordKey(delta.type, record), record);
+    });
+    let payload = {};
+    payload[delta.type] = Array.from(stored.values());
+    switch (delta.type) {
+        case "metars":
+            processMetars();
+            break;
+        case "tafs":
+            processTafs();
+            break;
+        case "pireps":
+            processPireps();
+            break;
+        case "airsigmets":
+            processAirSigmets(payload);
+            break;
+        case "gairmets":
+            processGAirmets(payload);
+            break;
+        case "windsaloft":
+            processWindsAloft(payload);
+            break;
+        case "notams":
+            processNotams(payload);
+            break;
+        case "tfrs":
+            processTfrs(payload);
+            break;
+    }
+}
+
+/**
+ * Read the current features of a weather product from the server's GeoJSON
+ * endpoint. Each feature keeps its decoded report under the given name, as
+ * the popups expect.
+ * @param {string} type: metars, tafs or pireps
+ * @param {string} name: the feature property to hold the report
+ * @param {function} callback: called with the features
+ */
+function loadWeatherFeatures(type, name, callback) {
+    $.getJSON(`${URL_SERVER}/api/${type}?format=geojson`, (geojson) => {
+        let features = new ol.format.GeoJSON().readFeatures(geojson, { featureProjection: "EPSG:3857" });
+        features.forEach((feature) => {
+            let report = feature.getProperties();
+            delete report.geometry;
+            feature.setProperties({ datatype: name }, true);
+            feature.set(name, report, true);
+        });
+        callback(features);
+    });
+}
+
 /**
  * Place metar features on the map. color-coded to the conditions
- * @param {object} metarsobject: JSON object with LOTS of metars
  */
- function processMetars(metarsobject) {
-    let newmetars = metarsobject.metars;
-    if (newmetars !== undefined) {
+ function processMetars() {
+    loadWeatherFeatures("metars", "metar", (features) => {
         metarFeatures.clear();
         metarMarkers = [];
         let scale = getScaleSize();
         try {
-            newmetars.forEach((metar) => {
+            features.forEach((metarFeature) => {
+                let metar = metarFeature.get("metar");
                 let popupsvg = "";
                 let windbarbsvg = "";
                 try { 
@@ -1190,78 +1472,58 @@ function displayAirportPopup(feature) {
                     opacity: 1,
                     scale: scale
                 });
-                let metarFeature = new ol.Feature({
-                    metar: metar,
-                    datatype: "metar",
-                    geometry: new ol.geom.Point(ol.proj.fromLonLat([metar.Longitude, metar.Latitude])),
-                    svgimage: popupsvg 
-                });
+                metarFeature.set("svgimage", popupsvg, true);
                 metarFeature.setStyle(new ol.style.Style({
                     image: metarmarker
                 }));
                 metarMarkers.push(metarmarker);
-                metarFeature.setId(metar.StationId);
                 metarFeatures.push(metarFeature);
-                metarFeature.changed();
             });
         }
         catch(error) {
             console.log(error.message);
         }
-    }
+    });
 }
 
 /**
  * Place taf feature objects on the map
- * @param {object} tafsobject: JSON object with LOTS of tafs 
  */
-function processTafs(tafsobject) {
-    let newtafs = tafsobject.tafs;
-    if (newtafs !== undefined) {
+function processTafs() {
+    loadWeatherFeatures("tafs", "taf", (features) => {
         tafFeatures.clear();
         try {
-            newtafs.forEach((taf) => {
-                let taffeature = new ol.Feature({
-                    ident: taf.StationId,
-                    taf: taf,
-                    datatype: "taf",
-                    geometry: new ol.geom.Point(ol.proj.fromLonLat([taf.Longitude, taf.Latitude]))
-                });
-                taffeature.setId(taf.StationId);
+            features.forEach((taffeature) => {
+                taffeature.set("ident", taffeature.get("taf").StationId, true);
                 taffeature.setStyle(tafStyle);
                 tafFeatures.push(taffeature);
-                taffeature.changed();
             });
         }
         catch (error){
             console.log(error.message);
         }
-    }
+    });
 }
 
 /**
- * Place pirep features on the map
- * @param {object} pirepsobject: JSON object with LOTS of pireps 
+ * Place pirep features on the map. Reports the server could not locate
+ * have no geometry and are left off.
  */
- function processPireps(pirepsobject) {
-    let newpireps = pirepsobject.pireps;
-    if (newpireps !== undefined) {
+ function processPireps() {
+    loadWeatherFeatures("pireps", "pirep", (features) => {
         pirepFeatures.clear();
         try {
-            newpireps.forEach((pirep) => {
+            features.forEach((pirepfeature) => {
+                if (!pirepfeature.getGeometry()) {
+                    return;
+                }
+                let pirep = pirepfeature.get("pirep");
                 // generate a "pseudo-heading" to use if wind dir is absent
                 let heading = Math.random()*Math.PI*2;
                 if (pirep.WindDirDegrees) {
                     heading = (pirep.WindDirDegrees * 0.0174533);
                 }
-                let pirepfeature = new ol.Feature({
-                    ident: pirep.AircraftRef,
-                    pirep: pirep,
-                    datatype: "pirep",
-                    geometry: new ol.geom.Point(ol.proj.fromLonLat([pirep.Longitude, pirep.Latitude])),
-                });
-                
-                pirepfeature.setId(pirep.AircraftRef);
+                pirepfeature.set("ident", pirep.AircraftRef, true);
                 pirepfeature.setStyle(new ol.style.Style({
                                         image: new ol.style.Icon({
                                             crossOrigin: 'anonymous',
@@ -1280,9 +1542,444 @@ function processTafs(tafsobject) {
         catch (error){
             console.log(error.message);
         }
+    });
+}
+
+/**
+ * Outline colors for advisory hazard types
+ */
+function getAdvisoryColor(hazardtype) {
+    switch (hazardtype) {
+        case "CONVECTIVE":
+            return "rgba(255, 0, 0, 1)";
+        case "IFR":
+            return "rgba(128, 0, 255, 1)";
+        case "MTN OBSCN":
+        case "MT_OBSC":
+            return "rgba(140, 90, 40, 1)";
+        case "TURB":
+        case "TURB-HI":
+        case "TURB-LO":
+        case "LLWS":
+        case "SFC_WND":
+            return "rgba(255, 140, 0, 1)";
+        case "ICE":
+        case "FZLVL":
+        case "M_FZLVL":
+            return "rgba(0, 120, 255, 1)";
+        default:
+            return "rgba(80, 80, 80, 1)";
     }
 }
 
+/**
+ * Build a polygon or line feature for an advisory area
+ * @param {object} advisory: the AIRMET, SIGMET or G-AIRMET
+ * @param {boolean} isline: true to draw the points as a line
+ * @returns {ol.Feature} or undefined if the area has too few points
+ */
+function createAdvisoryFeature(advisory, isline) {
+    let points = advisory.Area.Points;
+    if (points === null || points === undefined || points.length < 2) {
+        return undefined;
+    }
+    let coords = points.map((point) => ol.proj.fromLonLat([point.Longitude, point.Latitude]));
+    let geometry;
+    if (isline) {
+        geometry = new ol.geom.LineString(coords);
+    }
+    else {
+        geometry = new ol.geom.Polygon([coords]);
+    }
+    let color = getAdvisoryColor(advisory.Hazard.Type);
+    let feature = new ol.Feature({
+        advisory: advisory,
+        datatype: "advisory",
+        geometry: geometry
+    });
+    feature.setStyle(new ol.style.Style({
+        stroke: new ol.style.Stroke({
+            color: color,
+            width: 2
+        }),
+        fill: new ol.style.Fill({
+            color: color.replace(", 1)", ", 0.1)")
+        })
+    }));
+    return feature;
+}
+
+/**
+ * Place AIRMET, SIGMET and Convective SIGMET areas on the map
+ * @param {object} airsigmetsobject: JSON object with the advisories
+ */
+function processAirSigmets(airsigmetsobject) {
+    let advisories = airsigmetsobject.airsigmets;
+    if (advisories !== undefined && advisories !== null) {
+        airsigmetFeatures.clear();
+        advisories.forEach((advisory) => {
+            let feature = createAdvisoryFeature(advisory, false);
+            if (feature !== undefined) {
+                airsigmetFeatures.push(feature);
+            }
+        });
+    }
+}
+
+/**
+ * Place G-AIRMET areas and freezing level lines on the map
+ * @param {object} gairmetsobject: JSON object with the G-AIRMET snapshots
+ */
+function processGAirmets(gairmetsobject) {
+    let advisories = gairmetsobject.gairmets;
+    if (advisories !== undefined && advisories !== null) {
+        gairmetFeatures.clear();
+        advisories.forEach((advisory) => {
+            let feature = createAdvisoryFeature(advisory, advisory.GeometryType === "LINE");
+            if (feature !== undefined) {
+                gairmetFeatures.push(feature);
+            }
+        });
+    }
+}
+
+/**
+ * Create the html for an AIRMET, SIGMET or G-AIRMET popup element
+ * @param {object} feature: the advisory the user clicked on
+ */
+function displayAdvisoryPopup(feature) {
+    let advisory = feature.get("advisory");
+    let title = advisory.AirSigmetType !== undefined ? advisory.AirSigmetType : `G-AIRMET ${advisory.Product} ${advisory.Tag}`;
+    let from = advisory.ValidTimeFrom !== undefined ? advisory.ValidTimeFrom : advisory.ValidTime;
+    let to = advisory.ValidTimeTo !== undefined ? advisory.ValidTimeTo : advisory.ExpireTime;
+    if (config.uselocaltime) {
+        from = getLocalTime(from);
+        to = getLocalTime(to);
+    }
+    let html = `<div id="#featurepopup"><pre><code><p>`;
+    html += `<label class="taftitlelabel">${title}</label><p></p>`;
+    html += `Hazard:&nbsp<b>${advisory.Hazard.Type}</b><br/>`;
+    html += advisory.Hazard.Severity ? `Severity:&nbsp<b>${advisory.Hazard.Severity}</b><br/>` : "";
+    if (advisory.Altitude.LevelFtMsl) {
+        html += `Level:&nbsp<b>${advisory.Altitude.LevelFtMsl}&nbspft</b><br/>`;
+    }
+    else if (advisory.Altitude.MinFtMsl || advisory.Altitude.MaxFtMsl) {
+        html += `Altitude:&nbsp<b>${advisory.Altitude.MinFtMsl} - ${advisory.Altitude.MaxFtMsl}&nbspft</b><br/>`;
+    }
+    html += `Valid:&nbsp<b>${from}</b><br/>to&nbsp<b>${to}</b><br/>`;
+    html += advisory.DueTo ? `Due to:&nbsp<b>${advisory.DueTo}</b><br/>` : "";
+    html += `</p></code></pre>`;
+    html += advisory.RawText ? `<textarea class="rawdata">${advisory.RawText}</textarea><br />` : "";
+    html += `<p><button class="ol-popup-closer" onclick="closePopup()">close</button></p></div>`;
+    popupcontent.innerHTML = html;
+}
+
+/**
+ * Show an alert from one of the server weather alert rules
+ * @param {object} alert: JSON object with the rule id, message and raw report
+ */
+function displayWeatherAlert(alert) {
+    let title = alert.Cleared ? "ALERT CLEARED" : "WEATHER ALERT";
+    let time = config.uselocaltime ? getLocalTime(alert.Time) : alert.Time;
+    let html = `<div id="#featurepopup"><pre><code><p>`;
+    html += `<label class="taftitlelabel">${title} - ${alert.RuleId}</label><p></p>`;
+    html += `<b>${alert.Message}</b><br/>`;
+    html += `Time:&nbsp<b>${time}</b><br/>`;
+    html += `</p></code></pre>`;
+    html += alert.RawText ? `<textarea class="rawdata">${alert.RawText}</textarea><br />` : "";
+    html += `<p><button class="ol-popup-closer" onclick="closePopup()">close</button></p></div>`;
+    popupcontent.innerHTML = html;
+    popupoverlay.setPosition(viewposition);
+}
+
+/**
+ * Add a server alert rule and subscribe this client to it, for example
+ * { kind: "gust", station: "KDEN", threshold: 25 }
+ * @param {object} rule: the alert rule
+ */
+function addAlertRule(rule) {
+    let xhr = new XMLHttpRequest();
+    xhr.open("POST", `${URL_ALERT_RULES}?cid=${CID}`);
+    xhr.setRequestHeader("Content-Type", "application/json");
+    xhr.onload = () => {
+        if (xhr.status !== 201) {
+            console.log(`Alert rule not added: ${xhr.responseText}`);
+        }
+    };
+    xhr.send(JSON.stringify(rule));
+}
+
+/**
+ * Place TFR areas on the map, active ones in red and those yet to start in orange
+ * @param {object} tfrsobject: JSON object with the TFR list
+ */
+function processTfrs(tfrsobject) {
+    let tfrs = tfrsobject.tfrs;
+    if (tfrs !== undefined && tfrs !== null) {
+        tfrFeatures.clear();
+        let now = Date.now();
+        tfrs.forEach((tfr) => {
+            if (tfr.Areas === null) {
+                return;
+            }
+            let color = Date.parse(tfr.EffectiveFrom) > now ? "rgba(255, 140, 0, 1)" : "rgba(255, 0, 0, 1)";
+            tfr.Areas.forEach((area, index) => {
+                let ring = area.RadiusNm > 0 ? getCircleRing(area.CenterLat, area.CenterLon, area.RadiusNm) : area.Points;
+                if (ring === null || ring === undefined || ring.length < 3) {
+                    return;
+                }
+                let feature = new ol.Feature({
+                    tfr: tfr,
+                    area: area,
+                    datatype: "tfr",
+                    geometry: new ol.geom.Polygon([ring.map((point) => ol.proj.fromLonLat(point))])
+                });
+                feature.setId(`${tfr.Id}-${index}`);
+                feature.setStyle(new ol.style.Style({
+                    stroke: new ol.style.Stroke({
+                        color: color,
+                        width: 3
+                    }),
+                    fill: new ol.style.Fill({
+                        color: color.replace(", 1)", ", 0.15)")
+                    })
+                }));
+                tfrFeatures.push(feature);
+            });
+        });
+    }
+}
+
+/**
+ * Approximate a circle with a ring of [longitude, latitude] points
+ * @param {number} lat: center latitude
+ * @param {number} lon: center longitude
+ * @param {number} radiusnm: radius in nautical miles
+ * @returns array of [longitude, latitude]
+ */
+function getCircleRing(lat, lon, radiusnm) {
+    let ring = [];
+    let phi1 = lat * Math.PI / 180;
+    let lambda1 = lon * Math.PI / 180;
+    let delta = radiusnm / 3440.065;
+    for (let brg = 0; brg <= 360; brg += 10) {
+        let theta = brg * Math.PI / 180;
+        let phi2 = Math.asin(Math.sin(phi1) * Math.cos(delta) + Math.cos(phi1) * Math.sin(delta) * Math.cos(theta));
+        let lambda2 = lambda1 + Math.atan2(Math.sin(theta) * Math.sin(delta) * Math.cos(phi1),
+                                           Math.cos(delta) - Math.sin(phi1) * Math.sin(phi2));
+        ring.push([lambda2 * 180 / Math.PI, phi2 * 180 / Math.PI]);
+    }
+    return ring;
+}
+
+/**
+ * Format a TFR altitude limit
+ * @param {number} feet: the limit in feet
+ * @param {string} ref: MSL or AGL
+ * @returns string
+ */
+function getTfrLimit(feet, ref) {
+    if (feet === 0 && ref === "AGL") {
+        return "SFC";
+    }
+    return `${feet} ft ${ref}`;
+}
+
+/**
+ * Create the html for a TFR popup element
+ * @param {object} feature: the TFR area the user clicked on
+ */
+function displayTfrPopup(feature) {
+    let tfr = feature.get("tfr");
+    let area = feature.get("area");
+    let from = tfr.EffectiveFrom;
+    let to = tfr.EffectiveTo;
+    if (config.uselocaltime) {
+        from = getLocalTime(from);
+        to = getLocalTime(to);
+    }
+    if (tfr.EffectiveTo.startsWith("0001")) {
+        to = "further notice";
+    }
+    let html = `<div id="#featurepopup"><pre><code><p>`;
+    html += `<label class="taftitlelabel">TFR ${tfr.Id} - ${tfr.Type}</label><p></p>`;
+    html += area.Name ? `Area:&nbsp<b>${area.Name}</b><br/>` : "";
+    html += `Altitude:&nbsp<b>${getTfrLimit(area.LowerFt, area.LowerRef)} - ${getTfrLimit(area.UpperFt, area.UpperRef)}</b><br/>`;
+    html += `Valid:&nbsp<b>${from}</b><br/>to&nbsp<b>${to}</b><br/>`;
+    html += tfr.Facility ? `Facility:&nbsp<b>${tfr.Facility}</b><br/>` : "";
+    html += `</p></code></pre>`;
+    html += tfr.Description ? `<textarea class="rawdata">${tfr.Description}</textarea><br />` : "";
+    html += `<p><button class="ol-popup-closer" onclick="closePopup()">close</button></p></div>`;
+    popupcontent.innerHTML = html;
+}
+
+/**
+ * Show the server alert that ownship is inside, or projected to enter, an active TFR
+ * @param {object} conflict: JSON object with the TFR id, name and minutes ahead
+ */
+function displayTfrAlert(conflict) {
+    let when = conflict.Inside ? "Ownship is INSIDE" : `Track enters in ${conflict.MinutesAhead} min`;
+    let html = `<div id="#featurepopup"><pre><code><p>`;
+    html += `<label class="taftitlelabel">TFR ALERT - ${conflict.Id}</label><p></p>`;
+    html += `<b>${when}</b><br/>`;
+    html += conflict.Name ? `${conflict.Name}<br/>` : "";
+    html += conflict.Area && conflict.Area !== conflict.Name ? `${conflict.Area}<br/>` : "";
+    html += `</p></code></pre>`;
+    html += `<p><button class="ol-popup-closer" onclick="closePopup()">close</button></p></div>`;
+    popupcontent.innerHTML = html;
+    popupoverlay.setPosition(viewposition);
+}
+
+/**
+ * Place the positioned NOTAMs that are in effect on the map
+ * @param {object} notamsobject: JSON object with the NOTAM list
+ */
+function processNotams(notamsobject) {
+    let notams = notamsobject.notams;
+    if (notams !== undefined && notams !== null) {
+        notamFeatures.clear();
+        notams.forEach((notam) => {
+            if (!notam.Located || !isNotamActive(notam)) {
+                return;
+            }
+            let feature = new ol.Feature({
+                notam: notam,
+                datatype: "notam",
+                geometry: new ol.geom.Point(ol.proj.fromLonLat([notam.Longitude, notam.Latitude]))
+            });
+            feature.setId(notam.Id);
+            feature.setStyle(notamStyle);
+            notamFeatures.push(feature);
+        });
+    }
+}
+
+/**
+ * Determine whether a NOTAM is in effect now
+ * @param {object} notam: the NOTAM record
+ * @returns {boolean} true when the NOTAM has started and not ended
+ */
+function isNotamActive(notam) {
+    let now = Date.now();
+    let from = Date.parse(notam.EffectiveFrom);
+    let to = Date.parse(notam.EffectiveTo);
+    if (from > 0 && now < from) {
+        return false;
+    }
+    return notam.Permanent || !(to > 0) || now < to;
+}
+
+/**
+ * Build the html for the NOTAMs in effect at an airport. Domestic NOTAMs
+ * use the three letter FAA identifier, so KDEN also matches DEN.
+ * @param {string} ident: the airport identifier
+ * @returns html string, empty when there are none
+ */
+function getAirportNotamsHtml(ident) {
+    let short = (ident.length === 4 && (ident[0] === "K" || ident[0] === "P")) ? ident.substring(1) : "";
+    let html = "";
+    weatherRecords.notams.forEach((notam) => {
+        if (!isNotamActive(notam) || notam.Locations === null) {
+            return;
+        }
+        if (notam.Locations.includes(ident) || (short !== "" && notam.Locations.includes(short))) {
+            html += `<b>${notam.Id}</b>&nbsp${notam.Text}<br/>`;
+        }
+    });
+    return html !== "" ? `NOTAMs:<br/>${html}` : "";
+}
+
+/**
+ * Create the html for a NOTAM popup element
+ * @param {object} feature: the NOTAM the user clicked on
+ */
+function displayNotamPopup(feature) {
+    let notam = feature.get("notam");
+    let from = notam.EffectiveFrom;
+    let to = notam.Permanent ? "PERM" : notam.EffectiveTo;
+    if (config.uselocaltime) {
+        from = getLocalTime(from);
+        if (!notam.Permanent) {
+            to = getLocalTime(to);
+        }
+    }
+    let html = `<div id="#featurepopup"><pre><code><p>`;
+    html += `<label class="taftitlelabel">NOTAM ${notam.Id} - ${notam.Locations.join(" ")}</label><p></p>`;
+    html += notam.Code ? `Code:&nbsp<b>${notam.Code}</b><br/>` : "";
+    html += `Valid:&nbsp<b>${from}</b><br/>to&nbsp<b>${to}${notam.Estimated ? " EST" : ""}</b><br/>`;
+    html += notam.Schedule ? `Schedule:&nbsp<b>${notam.Schedule}</b><br/>` : "";
+    html += (notam.Lower || notam.Upper) ? `Limits:&nbsp<b>${notam.Lower} - ${notam.Upper}</b><br/>` : "";
+    html += `<b>${notam.Text}</b><br/>`;
+    html += `</p></code></pre>`;
+    html += `<textarea class="rawdata">${notam.RawText}</textarea><br />`;
+    html += `<p><button class="ol-popup-closer" onclick="closePopup()">close</button></p></div>`;
+    popupcontent.innerHTML = html;
+}
+
+/**
+ * Place winds aloft barbs on the map for the display altitude
+ * @param {object} windsaloftobject: JSON object with the FB station columns
+ */
+function processWindsAloft(windsaloftobject) {
+    let stations = windsaloftobject.windsaloft;
+    if (stations !== undefined && stations !== null) {
+        windsaloftFeatures.clear();
+        let scale = getScaleSize();
+        stations.forEach((station) => {
+            if (!station.Located || station.Levels === null) {
+                return;
+            }
+            let level = station.Levels.find((lvl) => lvl.AltitudeFt === windsAloftDisplayAltitude);
+            if (level === undefined) {
+                return;
+            }
+            let barbsvg = "";
+            try {
+                barbsvg = getWindBarbSvg(95, 95, {
+                    WindDirDegrees: level.WindDirDegrees,
+                    WindSpeedKt: level.WindSpeedKt,
+                    StationId: station.Ident
+                });
+            }
+            catch { }
+            let feature = new ol.Feature({
+                station: station,
+                datatype: "windsaloft",
+                geometry: new ol.geom.Point(ol.proj.fromLonLat([station.Longitude, station.Latitude]))
+            });
+            feature.setStyle(new ol.style.Style({
+                image: new ol.style.Icon({
+                    crossOrigin: "anonymous",
+                    src: `data:image/svg+xml;utf8,${encodeURIComponent(barbsvg)}`,
+                    offset: [0,0],
+                    opacity: 1,
+                    scale: scale
+                })
+            }));
+            feature.setId(`FB-${station.Ident}`);
+            windsaloftFeatures.push(feature);
+        });
+    }
+}
+
+/**
+ * Create the html for a winds aloft popup with every forecast level
+ * @param {object} feature: the FB station the user clicked on
+ */
+function displayWindsAloftPopup(feature) {
+    let station = feature.get("station");
+    let html = `<div id="#featurepopup"><pre><code><p>`;
+    html += `<label class="taftitlelabel">Winds Aloft - ${station.Ident}</label><p></p>`;
+    station.Levels.forEach((level) => {
+        let wind = level.LightAndVariable ? "Light and variable" : `${level.WindDirDegrees}° ${level.WindSpeedKt}&nbspkt`;
+        let temp = (level.TempC !== null && level.TempC !== undefined) ? `, ${level.TempC} °C` : "";
+        html += `${level.AltitudeFt}&nbspft:&nbsp<b>${wind}${temp}</b><br/>`;
+    });
+    html += `</p></code></pre>`;
+    html += `<p><button class="ol-popup-closer" onclick="closePopup()">close</button></p></div>`;
+    popupcontent.innerHTML = html;
+}
+
 /**
  * This routine adjusts feature "dot" image 
  * sizes, depending on current zoom level
@@ -1463,11 +2160,71 @@ $.get(`${URL_GET_TILESETS}`, (data) => {
         zIndex: 14
     });
 
+    airsigmetVectorSource = new ol.source.Vector({
+        features: airsigmetFeatures
+    });
+    airsigmetVectorLayer = new ol.layer.Vector({
+        title: "AIRMETs and SIGMETs",
+        source: airsigmetVectorSource,
+        visible: false,
+        extent: extent,
+        zIndex: 12
+    });
+
+    gairmetVectorSource = new ol.source.Vector({
+        features: gairmetFeatures
+    });
+    gairmetVectorLayer = new ol.layer.Vector({
+        title: "G-AIRMETs",
+        source: gairmetVectorSource,
+        visible: false,
+        extent: extent,
+        zIndex: 12
+    });
+
+    windsaloftVectorSource = new ol.source.Vector({
+        features: windsaloftFeatures
+    });
+    windsaloftVectorLayer = new ol.layer.Vector({
+        title: `Winds Aloft ${windsAloftDisplayAltitude} ft`,
+        source: windsaloftVectorSource,
+        visible: false,
+        extent: extent,
+        zIndex: 13
+    });
+
+    notamVectorSource = new ol.source.Vector({
+        features: notamFeatures
+    });
+    notamVectorLayer = new ol.layer.Vector({
+        title: "NOTAMs",
+        source: notamVectorSource,
+        visible: false,
+        extent: extent,
+        zIndex: 14
+    });
+
+    tfrVectorSource = new ol.source.Vector({
+        features: tfrFeatures
+    });
+    tfrVectorLayer = new ol.layer.Vector({
+        title: "TFRs",
+        source: tfrVectorSource,
+        visible: true,
+        extent: extent,
+        zIndex: 15
+    });
+
     map.addLayer(debugTileLayer);
     map.addLayer(airportVectorLayer);
     map.addLayer(metarVectorLayer); 
     map.addLayer(tafVectorLayer);
     map.addLayer(pirepVectorLayer);
+    map.addLayer(airsigmetVectorLayer);
+    map.addLayer(gairmetVectorLayer);
+    map.addLayer(windsaloftVectorLayer);
+    map.addLayer(notamVectorLayer);
+    map.addLayer(tfrVectorLayer);
     map.addLayer(animatedWxTileLayer);
     tilelayers.forEach((layer) => {
         map.addLayer(layer);
@@ -1512,10 +2269,10 @@ function selectStyle(feature) {
 }
 
 /**
- * If saving position history is enabled,  
- * save it at a specified time interval
+ * If saving position history or TFR alerts are enabled,  
+ * post the position at a specified time interval
  */
-if (config.savepositionhistory) {
+if (config.savepositionhistory || config.tfrsurl !== "") {
     setInterval(savePositionHistory, config.histintervalmsec);
 }
 
@@ -1793,10 +2550,17 @@ function getAltimeterSetting(altimeter) {
 /**
  * Get the formatted name of an airport
  * @param {string} ident, the airport identifier 
+ * @param {object} station, optional station details the server joined to a
+ *                 weather report, used for weather-only stations that are
+ *                 not in airports.json
  * @returns string, formatted name of the airport
  */
- function getFormattedAirportName(ident) {
+ function getFormattedAirportName(ident, station) {
     let retvalue = airportNameKeymap.get(ident);
+    if ((retvalue === undefined || retvalue === "undefined" || retvalue === "") &&
+        station !== undefined && station !== null && station.Name !== "") {
+        retvalue = station.State !== undefined ? `${station.Name}, ${station.State}` : station.Name;
+    }
     if (retvalue === undefined || 
         retvalue === "undefined" ||
         retvalue === "") {
@@ -1844,6 +2608,7 @@ function loadTafFieldKeymap() {
     tafFieldKeymap.set("ChangeIndicator", "Change indicator");
     tafFieldKeymap.set("TimeBecoming", "Time becoming");
     tafFieldKeymap.set("Probability", "Probability");
+    tafFieldKeymap.set("FlightCategory", "Flight category");
     tafFieldKeymap.set("WindDirDegrees", "Wind Direction");
     tafFieldKeymap.set("WindSpeedKt", "Wind Speed knots");
     tafFieldKeymap.set("WindGustKt", "Wind Gust knots");
@@ -1855,6 +2620,7 @@ function loadTafFieldKeymap() {
     tafFieldKeymap.set("VertVisFt", "Vertical visibility in feet");
     tafFieldKeymap.set("VisibilityStatuteMi", "Horizontal visibility in statute miles");
     tafFieldKeymap.set("WxString", "Weather");
+    tafFieldKeymap.set("Remarks", "Remarks");
     tafFieldKeymap.set("SkyCondition", "Sky condition");
     tafFieldKeymap.set("IcingCondition", "Icing condition");
     tafFieldKeymap.set("TurbulenceCondition", "Turbulence condition");